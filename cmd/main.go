@@ -1,28 +1,82 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"rest/config"
 	"rest/controllers"
+	"rest/models"
+	"rest/models/kafka"
+	"rest/models/memory"
 	"rest/models/mysql"
 	"rest/models/redis"
+	"rest/models/sqlite"
+	"syscall"
+	"time"
 
 	"github.com/buaazp/fasthttprouter"
 	"github.com/valyala/fasthttp"
 )
 
 func main() {
-	db, err := mysql.NewMySQL()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(migrate(os.Args[2:]))
+	}
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	printConfig := fs.Bool("print-config", false, "print resulting config with secrets redacted and exit")
+	cfg, err := config.Load(fs, os.Args[1:])
+	if err != nil {
+		log.Println(err)
+		os.Exit(2)
+	}
+	if *printConfig {
+		fmt.Print(cfg)
+		return
+	}
+	db, err := newUserStore(cfg)
 	if err != nil {
 		log.Println(err)
 		return
 	}
-	redis, err := redis.NewRedisCache(0)
+	redis, err := newCache(cfg)
 	if err != nil {
 		log.Println(err)
 		return
 	}
-	server := controllers.NewMyServer(db, redis)
+	jobs, err := newJobStore(cfg)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	queue, err := newJobQueue(cfg)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	events, err := newJobEvents(cfg)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	server := controllers.NewMyServer(db, redis, jobs, queue, events, cfg)
+	if err := server.ListenEvents(); err != nil {
+		log.Println(err)
+		return
+	}
 	go server.DispatchWorkers()
+	purge, stopPurge := context.WithCancel(context.Background())
+	defer stopPurge()
+	go server.PurgeUsers(purge)
+	// kafka keeps unfinished jobs itself and redelivers them, resuming would restart jobs of other instances
+	if cfg.Workers.Queue == "channel" {
+		if err := server.ResumeJobs(); err != nil {
+			log.Println("ERROR|Resume hash jobs:", err)
+		}
+	}
 	// r := routes.NewRouter(server)
 	r := fasthttprouter.New()
 	r.GET("/rest/substr", server.SubstringHandler)
@@ -30,17 +84,125 @@ func main() {
 	r.GET("/rest/email", server.EmailHandler)
 	r.POST("/rest/email/check", server.GetEmail)
 	r.POST("/rest/iin/check", server.GetIIN)
-	r.POST("/rest/counter/add/:add", server.AddCounter)
-	r.POST("/rest/counter/sub/:sub", server.SubCounter)
-	r.GET("/rest/counter/val", server.GetCounter)
-	r.POST("/rest/user", server.CreateUser)
-	r.GET("/rest/user/:id", server.GetUser)
+	r.GET("/rest/counter", server.ListCounters)
+	r.GET("/rest/counter/:name", server.GetCounter)
+	r.PUT("/rest/counter/:name", server.SetCounterBounds)
+	r.DELETE("/rest/counter/:name", server.DeleteCounter)
+	r.POST("/rest/counter/:name/:op", server.ChangeDefaultCounter)
+	r.POST("/rest/counter/:name/:op/:n", server.ChangeCounter)
+	r.GET("/rest/user", server.ListUsers)
+	r.POST("/rest/user", server.Idempotent(server.CreateUser))
+	r.GET("/rest/user/:id", controllers.WithStatic("export", server.ExportUsers, server.GetUser))
+	r.POST("/rest/user/:id", controllers.WithStatic("import", server.Idempotent(server.ImportUsers), nil))
 	r.PUT("/rest/user/:id", server.UpdateUser)
+	r.PATCH("/rest/user/:id", server.PatchUser)
 	r.DELETE("/rest/user/:id", server.DeleteUser)
-	r.POST("/rest/hash/calc", server.GenerateHash)
+	r.POST("/rest/user/:id/restore", server.RestoreUser)
+	r.POST("/rest/hash/calc", server.Idempotent(server.GenerateHash))
+	r.POST("/rest/hash/calc/batch", server.Idempotent(server.GenerateBatch))
+	r.GET("/rest/hash/batch/:id", server.GetBatch)
 	r.GET("/rest/hash/result/:id", server.GetHash)
+	r.DELETE("/rest/hash/result/:id", server.CancelHash)
+	r.POST("/rest/hash/result/:id/retry", server.RetryHash)
+	r.GET("/rest/hash/result/:id/deliveries", server.ListDeliveries)
+	r.GET("/rest/hash/result/:id/events", server.HashEvents)
 	r.GET("/rest/hash", server.HashHandler)
+	r.GET("/rest/hash/jobs", server.ListJobs)
 	r.GET("/rest/self/find/:str", server.GetIdentifiers)
-	fasthttp.ListenAndServe(":8080", r.Handler)
+	r.GET("/rest/admin/workers", server.GetWorkers)
+	r.PUT("/rest/admin/workers", server.ResizeWorkers)
+	srv := &fasthttp.Server{Handler: r.Handler}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe(cfg.Server.Addr)
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case s := <-sig:
+		log.Println("INFO|Received", s, "shutting down")
+	case err := <-serveErr:
+		log.Println("ERROR|Server stopped:", err)
+	}
+	shutdown(srv, server, cfg.Server.ShutdownTimeout)
+	if err := db.Close(); err != nil {
+		log.Println("ERROR|Close MySQL:", err)
+	}
+	if err := redis.Close(); err != nil {
+		log.Println("ERROR|Close redis:", err)
+	}
+	if err := jobs.Close(); err != nil {
+		log.Println("ERROR|Close job store:", err)
+	}
+	if events != nil {
+		if err := events.Close(); err != nil {
+			log.Println("ERROR|Close job events:", err)
+		}
+	}
+}
+
+// newUserStore returns user store selected by config
+func newUserStore(cfg *config.Config) (models.MySQLInterface, error) {
+	switch cfg.Users.Store {
+	case "sqlite":
+		return sqlite.NewSQLite(cfg.SQLite)
+	case "memory":
+		return memory.NewUsers(), nil
+	}
+	return mysql.NewMySQL(cfg.MySQL)
+}
+
+// newCache returns cache of counter and responses selected by config
+func newCache(cfg *config.Config) (models.RedisInterface, error) {
+	if cfg.Redis.Store == "memory" {
+		return memory.NewCache(cfg.Redis.Expiration), nil
+	}
+	return redis.NewRedisCache(cfg.Redis)
+}
 
+// newJobStore returns job store selected by config
+func newJobStore(cfg *config.Config) (models.JobStore, error) {
+	if cfg.Workers.Store == "mysql" {
+		return mysql.NewJobStore(cfg.MySQL)
+	}
+	return redis.NewJobStore(cfg.Redis)
+}
+
+// newJobQueue returns job queue selected by config
+func newJobQueue(cfg *config.Config) (models.JobQueue, error) {
+	if cfg.Workers.Queue == "kafka" {
+		return kafka.NewQueue(cfg.Kafka)
+	}
+	return memory.NewQueue(cfg.Workers.QueueSize), nil
+}
+
+// newJobEvents returns job events selected by config
+// Nil is returned for local events which need no shared transport
+func newJobEvents(cfg *config.Config) (models.JobEvents, error) {
+	if cfg.Workers.Events == "redis" {
+		return redis.NewJobEvents(cfg.Redis)
+	}
+	return nil, nil
+}
+
+// shutdown stops accepting connections and then waits for hash jobs until timeout
+func shutdown(srv *fasthttp.Server, server *controllers.MyServer, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- srv.Shutdown()
+	}()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			log.Println("ERROR|Shutdown server:", err)
+		}
+	case <-ctx.Done():
+		log.Println("ERROR|Open connections did not close in time")
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		log.Println("ERROR|Hash jobs did not finish in time and were cancelled:", err)
+	}
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"rest/config"
+	"rest/models/mysql"
+	"strconv"
+)
+
+const migrateUsage = `usage: %s migrate [flags] up|down [n]|status
+  up      apply pending migrations
+  down    revert last n applied migrations, 1 by default
+  status  list migrations and when they were applied
+`
+
+// migrate runs migrate subcommand with args following it and returns exit code
+// Database is selected with the same flags, environment and config file as the server
+func migrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), migrateUsage, os.Args[0])
+		fs.PrintDefaults()
+	}
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		log.Println(err)
+		return 2
+	}
+	cmd := fs.Args()
+	if len(cmd) == 0 || len(cmd) > 2 || len(cmd) == 2 && cmd[0] != "down" {
+		fs.Usage()
+		return 2
+	}
+	n := 1
+	if len(cmd) == 2 {
+		if n, err = strconv.Atoi(cmd[1]); err != nil || n < 1 {
+			log.Println("number of migrations to revert must be positive")
+			return 2
+		}
+	}
+	m, err := mysql.NewMigrator(cfg.MySQL)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	defer m.Close()
+	switch cmd[0] {
+	case "up":
+		done, err := m.Up()
+		fmt.Printf("applied %d migrations\n", len(done))
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+	case "down":
+		done, err := m.Down(n)
+		fmt.Printf("reverted %d migrations\n", len(done))
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+	case "status":
+		status, err := m.Status()
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Println(s.Migration, applied)
+		}
+	default:
+		fs.Usage()
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,575 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets when config is printed
+const redacted = "****"
+
+// Config holds settings of every component of the API
+type Config struct {
+	Server   Server   `yaml:"server"`
+	MySQL    MySQL    `yaml:"mysql"`
+	SQLite   SQLite   `yaml:"sqlite"`
+	Redis    Redis    `yaml:"redis"`
+	Kafka    Kafka    `yaml:"kafka"`
+	Workers  Workers  `yaml:"workers"`
+	Webhooks Webhooks `yaml:"webhooks"`
+	Users    Users    `yaml:"users"`
+}
+
+// Server holds settings of the HTTP server
+type Server struct {
+	Addr            string        `yaml:"addr"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// MySQL holds settings of MySQL connection pool
+type MySQL struct {
+	DSN             string        `yaml:"dsn"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+}
+
+// SQLite holds settings of user store kept in SQLite file
+type SQLite struct {
+	// Path is database file, ":memory:" keeps database in memory
+	Path string `yaml:"path"`
+}
+
+// Redis holds settings of redis client
+type Redis struct {
+	// Store is "redis" or "memory", the latter keeps counter and cached responses in this process
+	Store      string        `yaml:"store"`
+	Addr       string        `yaml:"addr"`
+	Password   string        `yaml:"password"`
+	DB         int           `yaml:"db"`
+	Expiration time.Duration `yaml:"expiration"`
+	// IdempotencyTTL is how long responses to requests with Idempotency-Key are kept
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl"`
+}
+
+// Kafka holds settings of hash job queue kept in kafka
+type Kafka struct {
+	Brokers []string `yaml:"brokers"`
+	Topic   string   `yaml:"topic"`
+	// Group is consumer group shared by all instances of the API
+	Group string `yaml:"group"`
+}
+
+// Workers holds settings of hash workers
+type Workers struct {
+	Count int `yaml:"count"`
+	// MaxCount limits size of worker pool set at runtime
+	MaxCount  int `yaml:"max_count"`
+	QueueSize int `yaml:"queue_size"`
+	// MaxBatch limits number of jobs submitted in one batch
+	MaxBatch int `yaml:"max_batch"`
+	// Store is either "redis" or "mysql"
+	Store string `yaml:"store"`
+	// Queue is either "channel" or "kafka", QueueSize only applies to the former
+	Queue string `yaml:"queue"`
+	// Events is either "local" or "redis", the latter is needed when several instances share job store
+	Events string `yaml:"events"`
+	// Duration and Interval are used for requests that do not set them
+	Duration    time.Duration `yaml:"duration"`
+	Interval    time.Duration `yaml:"interval"`
+	MinDuration time.Duration `yaml:"min_duration"`
+	MaxDuration time.Duration `yaml:"max_duration"`
+	MinInterval time.Duration `yaml:"min_interval"`
+	MaxInterval time.Duration `yaml:"max_interval"`
+}
+
+// Webhooks holds settings of callbacks sent when hash jobs finish
+type Webhooks struct {
+	// Secret signs payloads with HMAC-SHA256
+	Secret string `yaml:"secret"`
+	// Attempts is the maximum number of deliveries of a single callback
+	Attempts int           `yaml:"attempts"`
+	Timeout  time.Duration `yaml:"timeout"`
+	// Backoff is the delay before second attempt, it doubles up to MaxBackoff
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+}
+
+// Users holds settings of user store, of purging deleted users and of bulk import
+type Users struct {
+	// Store is "mysql", "sqlite" or "memory", the latter two let the API run without MySQL
+	Store string `yaml:"store"`
+	// Retention is how long deleted users can be restored before they are purged
+	Retention     time.Duration `yaml:"retention"`
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+	// MaxImport limits number of rows in one import, ImportChunk is number of rows saved in one transaction
+	MaxImport   int   `yaml:"max_import"`
+	ImportChunk int   `yaml:"import_chunk"`
+	Names       Names `yaml:"names"`
+}
+
+// Names is the policy first and last names of users must follow
+type Names struct {
+	// Scripts are alphabets letters of names belong to: latin, cyrillic or kazakh
+	Scripts []string `yaml:"scripts"`
+	// Separators may appear between letters: hyphen, apostrophe or space
+	Separators []string `yaml:"separators"`
+	// MinLength and MaxLength are counted in characters after NFC normalisation
+	MinLength int `yaml:"min_length"`
+	MaxLength int `yaml:"max_length"`
+}
+
+// Default returns config with the values used by docker-compose
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Addr:            ":8080",
+			ShutdownTimeout: time.Second * 30,
+		},
+		MySQL: MySQL{
+			DSN:             "tester:secret@tcp(db:3306)/db",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: time.Minute * 5,
+		},
+		SQLite: SQLite{
+			Path: "rest.db",
+		},
+		Redis: Redis{
+			Store:          "redis",
+			Addr:           "redis:6379",
+			IdempotencyTTL: time.Hour * 24,
+		},
+		Kafka: Kafka{
+			Brokers: []string{"kafka:9092"},
+			Topic:   "hash_jobs",
+			Group:   "hash_workers",
+		},
+		Workers: Workers{
+			Count:       2,
+			MaxCount:    64,
+			QueueSize:   2048,
+			MaxBatch:    1000,
+			Store:       "redis",
+			Queue:       "channel",
+			Events:      "local",
+			Duration:    time.Minute,
+			Interval:    time.Second * 5,
+			MinDuration: time.Second,
+			MaxDuration: time.Minute * 10,
+			MinInterval: time.Millisecond * 100,
+			MaxInterval: time.Minute,
+		},
+		Webhooks: Webhooks{
+			Attempts:   5,
+			Timeout:    time.Second * 10,
+			Backoff:    time.Second,
+			MaxBackoff: time.Minute,
+		},
+		Users: Users{
+			Store:         "mysql",
+			Retention:     time.Hour * 24 * 30,
+			PurgeInterval: time.Hour,
+			MaxImport:     10000,
+			ImportChunk:   500,
+			Names: Names{
+				Scripts:    []string{"latin", "kazakh"},
+				Separators: []string{"hyphen", "apostrophe", "space"},
+				MinLength:  1,
+				MaxLength:  100,
+			},
+		},
+	}
+}
+
+// option binds a single setting to its environment variable and flag
+type option struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+var options = []option{
+	{"REST_ADDR", "addr", "address to listen on", func(c *Config, v string) error {
+		c.Server.Addr = v
+		return nil
+	}},
+	{"REST_SHUTDOWN_TIMEOUT", "shutdown-timeout", "time to wait for running hash jobs on shutdown", func(c *Config, v string) error {
+		return setDuration(&c.Server.ShutdownTimeout, v)
+	}},
+	{"REST_MYSQL_DSN", "mysql-dsn", "MySQL data source name", func(c *Config, v string) error {
+		c.MySQL.DSN = v
+		return nil
+	}},
+	{"REST_MYSQL_MAX_OPEN_CONNS", "mysql-max-open-conns", "maximum number of open MySQL connections", func(c *Config, v string) error {
+		return setInt(&c.MySQL.MaxOpenConns, v)
+	}},
+	{"REST_MYSQL_MAX_IDLE_CONNS", "mysql-max-idle-conns", "maximum number of idle MySQL connections", func(c *Config, v string) error {
+		return setInt(&c.MySQL.MaxIdleConns, v)
+	}},
+	{"REST_MYSQL_CONN_MAX_LIFETIME", "mysql-conn-max-lifetime", "maximum lifetime of MySQL connection, e.g. 5m", func(c *Config, v string) error {
+		return setDuration(&c.MySQL.ConnMaxLifetime, v)
+	}},
+	{"REST_CACHE_STORE", "cache-store", "where counter and cached responses are kept: redis or memory", func(c *Config, v string) error {
+		c.Redis.Store = v
+		return nil
+	}},
+	{"REST_REDIS_ADDR", "redis-addr", "redis address", func(c *Config, v string) error {
+		c.Redis.Addr = v
+		return nil
+	}},
+	{"REST_REDIS_PASSWORD", "redis-password", "redis password", func(c *Config, v string) error {
+		c.Redis.Password = v
+		return nil
+	}},
+	{"REST_REDIS_DB", "redis-db", "redis database number", func(c *Config, v string) error {
+		return setInt(&c.Redis.DB, v)
+	}},
+	{"REST_REDIS_EXPIRATION", "redis-expiration", "expiration of redis keys, zero means no expiration", func(c *Config, v string) error {
+		return setDuration(&c.Redis.Expiration, v)
+	}},
+	{"REST_IDEMPOTENCY_TTL", "idempotency-ttl", "how long responses to requests with Idempotency-Key are kept", func(c *Config, v string) error {
+		return setDuration(&c.Redis.IdempotencyTTL, v)
+	}},
+	{"REST_KAFKA_BROKERS", "kafka-brokers", "comma-separated kafka broker addresses", func(c *Config, v string) error {
+		c.Kafka.Brokers = strings.Split(v, ",")
+		return nil
+	}},
+	{"REST_KAFKA_TOPIC", "kafka-topic", "kafka topic of hash jobs", func(c *Config, v string) error {
+		c.Kafka.Topic = v
+		return nil
+	}},
+	{"REST_KAFKA_GROUP", "kafka-group", "kafka consumer group of hash workers", func(c *Config, v string) error {
+		c.Kafka.Group = v
+		return nil
+	}},
+	{"REST_WORKERS", "workers", "number of hashes computed concurrently", func(c *Config, v string) error {
+		return setInt(&c.Workers.Count, v)
+	}},
+	{"REST_MAX_WORKERS", "max-workers", "maximum number of workers set at runtime", func(c *Config, v string) error {
+		return setInt(&c.Workers.MaxCount, v)
+	}},
+	{"REST_QUEUE_SIZE", "queue-size", "capacity of hash job queue", func(c *Config, v string) error {
+		return setInt(&c.Workers.QueueSize, v)
+	}},
+	{"REST_MAX_BATCH", "max-batch", "maximum number of hash jobs in one batch", func(c *Config, v string) error {
+		return setInt(&c.Workers.MaxBatch, v)
+	}},
+	{"REST_JOB_STORE", "job-store", "where hash jobs are kept: redis or mysql", func(c *Config, v string) error {
+		c.Workers.Store = v
+		return nil
+	}},
+	{"REST_JOB_QUEUE", "job-queue", "where queued hash jobs are kept: channel or kafka", func(c *Config, v string) error {
+		c.Workers.Queue = v
+		return nil
+	}},
+	{"REST_JOB_EVENTS", "job-events", "how hash job status transitions reach waiting clients: local or redis", func(c *Config, v string) error {
+		c.Workers.Events = v
+		return nil
+	}},
+	{"REST_HASH_DURATION", "hash-duration", "default duration of hash computation", func(c *Config, v string) error {
+		return setDuration(&c.Workers.Duration, v)
+	}},
+	{"REST_HASH_INTERVAL", "hash-interval", "default interval between hash computation steps", func(c *Config, v string) error {
+		return setDuration(&c.Workers.Interval, v)
+	}},
+	{"REST_HASH_MIN_DURATION", "hash-min-duration", "minimum duration of hash computation", func(c *Config, v string) error {
+		return setDuration(&c.Workers.MinDuration, v)
+	}},
+	{"REST_HASH_MAX_DURATION", "hash-max-duration", "maximum duration of hash computation", func(c *Config, v string) error {
+		return setDuration(&c.Workers.MaxDuration, v)
+	}},
+	{"REST_HASH_MIN_INTERVAL", "hash-min-interval", "minimum interval between hash computation steps", func(c *Config, v string) error {
+		return setDuration(&c.Workers.MinInterval, v)
+	}},
+	{"REST_HASH_MAX_INTERVAL", "hash-max-interval", "maximum interval between hash computation steps", func(c *Config, v string) error {
+		return setDuration(&c.Workers.MaxInterval, v)
+	}},
+	{"REST_WEBHOOK_SECRET", "webhook-secret", "secret signing callbacks of hash jobs", func(c *Config, v string) error {
+		c.Webhooks.Secret = v
+		return nil
+	}},
+	{"REST_WEBHOOK_ATTEMPTS", "webhook-attempts", "maximum number of callback delivery attempts", func(c *Config, v string) error {
+		return setInt(&c.Webhooks.Attempts, v)
+	}},
+	{"REST_WEBHOOK_TIMEOUT", "webhook-timeout", "timeout of single callback delivery", func(c *Config, v string) error {
+		return setDuration(&c.Webhooks.Timeout, v)
+	}},
+	{"REST_WEBHOOK_BACKOFF", "webhook-backoff", "delay before callback is delivered again, doubled on every attempt", func(c *Config, v string) error {
+		return setDuration(&c.Webhooks.Backoff, v)
+	}},
+	{"REST_WEBHOOK_MAX_BACKOFF", "webhook-max-backoff", "maximum delay between callback delivery attempts", func(c *Config, v string) error {
+		return setDuration(&c.Webhooks.MaxBackoff, v)
+	}},
+	{"REST_USER_STORE", "user-store", "where users are kept: mysql, sqlite or memory", func(c *Config, v string) error {
+		c.Users.Store = v
+		return nil
+	}},
+	{"REST_SQLITE_PATH", "sqlite-path", "SQLite database file of sqlite user store", func(c *Config, v string) error {
+		c.SQLite.Path = v
+		return nil
+	}},
+	{"REST_USER_RETENTION", "user-retention", "how long deleted users can be restored before they are purged", func(c *Config, v string) error {
+		return setDuration(&c.Users.Retention, v)
+	}},
+	{"REST_USER_PURGE_INTERVAL", "user-purge-interval", "how often deleted users are purged", func(c *Config, v string) error {
+		return setDuration(&c.Users.PurgeInterval, v)
+	}},
+	{"REST_MAX_IMPORT", "max-import", "maximum number of users in one import", func(c *Config, v string) error {
+		return setInt(&c.Users.MaxImport, v)
+	}},
+	{"REST_IMPORT_CHUNK", "import-chunk", "number of imported users saved in one transaction", func(c *Config, v string) error {
+		return setInt(&c.Users.ImportChunk, v)
+	}},
+	{"REST_NAME_SCRIPTS", "name-scripts", "comma-separated alphabets of user names: latin, cyrillic, kazakh", func(c *Config, v string) error {
+		c.Users.Names.Scripts = splitList(v)
+		return nil
+	}},
+	{"REST_NAME_SEPARATORS", "name-separators", "comma-separated separators allowed between letters of user names: hyphen, apostrophe, space", func(c *Config, v string) error {
+		c.Users.Names.Separators = splitList(v)
+		return nil
+	}},
+	{"REST_NAME_MIN_LENGTH", "name-min-length", "minimum number of characters in user name", func(c *Config, v string) error {
+		return setInt(&c.Users.Names.MinLength, v)
+	}},
+	{"REST_NAME_MAX_LENGTH", "name-max-length", "maximum number of characters in user name", func(c *Config, v string) error {
+		return setInt(&c.Users.Names.MaxLength, v)
+	}},
+}
+
+// Load builds config from defaults, optional config file, environment and flags.
+// Later sources take precedence: flags > environment > file > defaults.
+// File is set by -config flag or REST_CONFIG variable and may be either YAML or JSON.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	file := fs.String("config", os.Getenv("REST_CONFIG"), "path to YAML or JSON config file")
+	flags := make(map[string]*string, len(options))
+	for _, o := range options {
+		flags[o.flag] = fs.String(o.flag, "", fmt.Sprintf("%s (env %s)", o.usage, o.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if *file != "" {
+		if err := cfg.readFile(*file); err != nil {
+			return nil, err
+		}
+	}
+	for _, o := range options {
+		if v, ok := os.LookupEnv(o.env); ok {
+			if err := o.set(cfg, v); err != nil {
+				return nil, fmt.Errorf("%s: %w", o.env, err)
+			}
+		}
+	}
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, o := range options {
+			if o.flag == f.Name && err == nil {
+				if e := o.set(cfg, *flags[o.flag]); e != nil {
+					err = fmt.Errorf("-%s: %w", o.flag, e)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// readFile overrides config with values from file
+// JSON is a subset of YAML so both are decoded the same way
+func (c *Config) readFile(name string) error {
+	body, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(body, c); err != nil {
+		return fmt.Errorf("parse %s: %w", name, err)
+	}
+	return nil
+}
+
+// Validate checks that config values are usable
+func (c *Config) Validate() error {
+	var errs []string
+	if c.Server.Addr == "" {
+		errs = append(errs, "server address is empty")
+	}
+	if c.Server.ShutdownTimeout < 0 {
+		errs = append(errs, "shutdown timeout cannot be negative")
+	}
+	if c.MySQL.DSN == "" {
+		errs = append(errs, "mysql dsn is empty")
+	}
+	if c.MySQL.MaxOpenConns < 1 {
+		errs = append(errs, "mysql max open conns must be positive")
+	}
+	if c.MySQL.MaxIdleConns < 0 || c.MySQL.MaxIdleConns > c.MySQL.MaxOpenConns {
+		errs = append(errs, "mysql max idle conns must be between 0 and max open conns")
+	}
+	if c.MySQL.ConnMaxLifetime < 0 {
+		errs = append(errs, "mysql conn max lifetime cannot be negative")
+	}
+	if c.Redis.Store != "redis" && c.Redis.Store != "memory" {
+		errs = append(errs, "cache store must be redis or memory")
+	}
+	if c.Redis.Addr == "" {
+		errs = append(errs, "redis address is empty")
+	}
+	if c.Redis.DB < 0 {
+		errs = append(errs, "redis db cannot be negative")
+	}
+	if c.Redis.Expiration < 0 {
+		errs = append(errs, "redis expiration cannot be negative")
+	}
+	if c.Redis.IdempotencyTTL <= 0 {
+		errs = append(errs, "idempotency ttl must be positive")
+	}
+	if c.Workers.Count < 1 || c.Workers.Count > c.Workers.MaxCount {
+		errs = append(errs, "workers count must be positive and not exceed max workers")
+	}
+	if c.Workers.QueueSize < 1 {
+		errs = append(errs, "queue size must be positive")
+	}
+	if c.Workers.MaxBatch < 1 {
+		errs = append(errs, "max batch must be positive")
+	}
+	if c.Workers.Store != "redis" && c.Workers.Store != "mysql" {
+		errs = append(errs, "job store must be redis or mysql")
+	}
+	if c.Workers.Queue != "channel" && c.Workers.Queue != "kafka" {
+		errs = append(errs, "job queue must be channel or kafka")
+	}
+	if c.Workers.Queue == "kafka" && (len(c.Kafka.Brokers) == 0 || c.Kafka.Brokers[0] == "" || c.Kafka.Topic == "" || c.Kafka.Group == "") {
+		errs = append(errs, "kafka brokers, topic and group are required for kafka job queue")
+	}
+	if c.Workers.Events != "local" && c.Workers.Events != "redis" {
+		errs = append(errs, "job events must be local or redis")
+	}
+	if w := c.Workers; w.MinDuration <= 0 || w.Duration < w.MinDuration || w.Duration > w.MaxDuration {
+		errs = append(errs, "hash duration must be positive and between min and max")
+	}
+	if w := c.Workers; w.MinInterval <= 0 || w.Interval < w.MinInterval || w.Interval > w.MaxInterval {
+		errs = append(errs, "hash interval must be positive and between min and max")
+	}
+	if c.Webhooks.Attempts < 1 {
+		errs = append(errs, "webhook attempts must be positive")
+	}
+	if c.Webhooks.Timeout <= 0 {
+		errs = append(errs, "webhook timeout must be positive")
+	}
+	if w := c.Webhooks; w.Backoff <= 0 || w.MaxBackoff < w.Backoff {
+		errs = append(errs, "webhook backoff must be positive and not exceed max backoff")
+	}
+	if c.Users.Store != "mysql" && c.Users.Store != "sqlite" && c.Users.Store != "memory" {
+		errs = append(errs, "user store must be mysql, sqlite or memory")
+	}
+	if c.Users.Store == "sqlite" && c.SQLite.Path == "" {
+		errs = append(errs, "sqlite path is required for sqlite user store")
+	}
+	if c.Users.Retention <= 0 || c.Users.PurgeInterval <= 0 {
+		errs = append(errs, "user retention and purge interval must be positive")
+	}
+	if c.Users.MaxImport < 1 || c.Users.ImportChunk < 1 {
+		errs = append(errs, "max import and import chunk must be positive")
+	}
+	if n := c.Users.Names; len(n.Scripts) == 0 || !oneOf(n.Scripts, "latin", "cyrillic", "kazakh") {
+		errs = append(errs, "name scripts must be latin, cyrillic or kazakh")
+	}
+	if !oneOf(c.Users.Names.Separators, "hyphen", "apostrophe", "space") {
+		errs = append(errs, "name separators must be hyphen, apostrophe or space")
+	}
+	// names are kept in varchar(255)
+	if n := c.Users.Names; n.MinLength < 1 || n.MaxLength < n.MinLength || n.MaxLength > 255 {
+		errs = append(errs, "name min length must be positive and not exceed max length of at most 255")
+	}
+	if len(errs) != 0 {
+		return errors.New("invalid config: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// String returns config as YAML with secrets redacted
+func (c Config) String() string {
+	c.MySQL.DSN = redactDSN(c.MySQL.DSN)
+	if c.Redis.Password != "" {
+		c.Redis.Password = redacted
+	}
+	if c.Webhooks.Secret != "" {
+		c.Webhooks.Secret = redacted
+	}
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
+
+// redactDSN hides password in DSN of form user:password@protocol(address)/dbname
+func redactDSN(dsn string) string {
+	at := strings.LastIndex(dsn, "@")
+	if at < 0 {
+		return dsn
+	}
+	colon := strings.Index(dsn[:at], ":")
+	if colon < 0 {
+		return dsn
+	}
+	return dsn[:colon+1] + redacted + dsn[at:]
+}
+
+// splitList splits comma-separated v dropping empty items
+func splitList(v string) []string {
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// oneOf reports whether every value is allowed
+func oneOf(values []string, allowed ...string) bool {
+	for _, v := range values {
+		found := false
+		for _, a := range allowed {
+			found = found || v == a
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// setInt parses v into dst
+func setInt(dst *int, v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	*dst = n
+	return nil
+}
+
+// setDuration parses v into dst
+func setDuration(dst *time.Duration, v string) error {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	*dst = d
+	return nil
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLoadPrecedence tests that flags override environment which overrides file
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	body := "server:\n  addr: :9000\nredis:\n  addr: file:6379\n  expiration: 1m\nworkers:\n  count: 3\n"
+	if err := os.WriteFile(file, []byte(body), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("REST_REDIS_ADDR", "env:6379")
+	t.Setenv("REST_WORKERS", "5")
+
+	cfg, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", file, "-workers", "7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Addr != ":9000" {
+		t.Errorf("expected addr %q from file but got %q", ":9000", cfg.Server.Addr)
+	}
+	if cfg.Redis.Expiration != time.Minute {
+		t.Errorf("expected expiration %v from file but got %v", time.Minute, cfg.Redis.Expiration)
+	}
+	if cfg.Redis.Addr != "env:6379" {
+		t.Errorf("expected redis addr %q from env but got %q", "env:6379", cfg.Redis.Addr)
+	}
+	if cfg.Workers.Count != 7 {
+		t.Errorf("expected workers %d from flag but got %d", 7, cfg.Workers.Count)
+	}
+	if cfg.MySQL.DSN != Default().MySQL.DSN {
+		t.Errorf("expected default dsn but got %q", cfg.MySQL.DSN)
+	}
+}
+
+var invalidConfigTests = []struct {
+	number int
+	args   []string
+}{
+	{0, []string{"-workers", "0"}},
+	{1, []string{"-queue-size", "-1"}},
+	{2, []string{"-mysql-max-idle-conns", "30"}},
+	{3, []string{"-redis-expiration", "ten"}},
+	{4, []string{"-addr", ""}},
+	{5, []string{"-config", "does-not-exist.yaml"}},
+	{6, []string{"-webhook-attempts", "0"}},
+	{7, []string{"-webhook-backoff", "2m"}},
+	{8, []string{"-job-events", "kafka"}},
+	{9, []string{"-job-queue", "rabbitmq"}},
+	{10, []string{"-job-queue", "kafka", "-kafka-topic", ""}},
+	{11, []string{"-workers", "10", "-max-workers", "5"}},
+	{12, []string{"-max-batch", "0"}},
+	{13, []string{"-idempotency-ttl", "0s"}},
+	{14, []string{"-user-retention", "0s"}},
+	{15, []string{"-user-purge-interval", "-1m"}},
+	{16, []string{"-import-chunk", "0"}},
+	{17, []string{"-name-scripts", "greek"}},
+	{18, []string{"-name-scripts", ""}},
+	{19, []string{"-name-separators", "hyphen,dot"}},
+	{20, []string{"-name-min-length", "5", "-name-max-length", "4"}},
+	{21, []string{"-name-max-length", "256"}},
+	{22, []string{"-user-store", "postgres"}},
+	{23, []string{"-user-store", "sqlite", "-sqlite-path", ""}},
+	{24, []string{"-cache-store", "memcached"}},
+}
+
+// TestLoadInvalid tests that invalid values are rejected
+func TestLoadInvalid(t *testing.T) {
+	for _, testCase := range invalidConfigTests {
+		if _, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), testCase.args); err == nil {
+			t.Errorf("for test #%d, expected error but got nil", testCase.number)
+		}
+	}
+}
+
+// TestStringRedactsSecrets tests that printed config hides passwords
+func TestStringRedactsSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.MySQL.DSN = "user:topsecret@tcp(localhost:3306)/db"
+	cfg.Redis.Password = "alsosecret"
+	cfg.Webhooks.Secret = "hooksecret"
+	out := cfg.String()
+	if strings.Contains(out, "topsecret") || strings.Contains(out, "alsosecret") || strings.Contains(out, "hooksecret") {
+		t.Errorf("expected secrets to be redacted but got %q", out)
+	}
+	if !strings.Contains(out, "user:****@tcp(localhost:3306)/db") {
+		t.Errorf("expected redacted dsn in %q", out)
+	}
+}
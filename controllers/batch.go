@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"rest/models"
+	"rest/myerrors"
+	"rest/viewmodels"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+)
+
+// GenerateBatch handles POST /rest/hash/calc/batch.
+// Body is an array of hash requests validated like the body of /rest/hash/calc.
+// Either every job of the batch is queued or, if any of them is invalid or queue has no room, none is.
+func (s *MyServer) GenerateBatch(ctx *fasthttp.RequestCtx) {
+	var reqs []hashRequest
+	if err := json.Unmarshal(ctx.Request.Body(), &reqs); err != nil {
+		log.Println("GenerateBatch err:", err)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput)
+		return
+	}
+	if max := s.workers.cfg.MaxBatch; len(reqs) == 0 || len(reqs) > max {
+		log.Println("GenerateBatch: invalid number of jobs", len(reqs))
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail(fmt.Sprintf("batch must contain between 1 and %d jobs", max)))
+		return
+	}
+	b := &models.Batch{
+		ID:        uuid.New().String(),
+		JobIDs:    make([]string, len(reqs)),
+		CreatedAt: s.workers.clock.Now().UTC(),
+	}
+	jobs := make([]models.Job, len(reqs))
+	for i, req := range reqs {
+		j, err := s.parseJob(req)
+		if err != nil {
+			log.Printf("GenerateBatch: invalid job %d: %v", i, err)
+			viewmodels.Error(ctx, itemError(i, err))
+			return
+		}
+		jobs[i], b.JobIDs[i] = *j, j.ID
+	}
+	if err := s.jobs.CreateBatch(b, jobs); err != nil {
+		log.Println("GenerateBatch err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	for i := range jobs {
+		s.publish(&jobs[i])
+	}
+	if err := s.enqueue(ctx, b.JobIDs...); err != nil {
+		log.Println("GenerateBatch err:", err)
+		if errors.Is(err, myerrors.ErrQueueFull) {
+			ctx.Response.Header.Set(fasthttp.HeaderRetryAfter, "1")
+		}
+		// client never learns IDs of the batch, so it is removed rather than cancelled
+		s.discardJobs(b.JobIDs, func() error {
+			return s.jobs.DeleteBatch(b)
+		})
+		viewmodels.Error(ctx, err)
+		return
+	}
+	log.Printf("generated batch %s of %d jobs", b.ID, len(jobs))
+	viewmodels.Result(ctx, b, fmt.Sprintf("We have received your batch and assigned the ID %s\n%s\n", b.ID, strings.Join(b.JobIDs, "\n")))
+}
+
+// itemError points invalid input error to job at index i of batch
+func itemError(i int, err error) error {
+	if !errors.Is(err, myerrors.ErrInvalidInput) {
+		return err
+	}
+	detail := fmt.Sprintf("job %d", i)
+	if d := strings.TrimPrefix(myerrors.As(err).Message, myerrors.ErrInvalidInput.Message); d != "" {
+		detail += ":" + strings.TrimPrefix(d, ",")
+	}
+	return myerrors.ErrInvalidInput.WithDetail(detail)
+}
+
+// GetBatch handles GET /rest/hash/batch/:id reporting progress and results of jobs of batch
+func (s *MyServer) GetBatch(ctx *fasthttp.RequestCtx) {
+	ID, ok := ctx.UserValue("id").(string)
+	if !ok {
+		log.Println("GetBatch: couldn't get ID value from context")
+		viewmodels.Error(ctx, myerrors.ErrCtxValue)
+		return
+	}
+	b, jobs, err := s.jobs.GetBatch(ID)
+	if err != nil {
+		log.Println("GetBatch err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	progress := viewmodels.Batch{
+		ID:        b.ID,
+		CreatedAt: b.CreatedAt,
+		Total:     len(b.JobIDs),
+		Jobs:      jobs,
+	}
+	text := ""
+	for _, j := range jobs {
+		switch j.Status {
+		case models.JobQueued:
+			progress.Queued++
+		case models.JobRunning:
+			progress.Running++
+		case models.JobDone:
+			progress.Done++
+		case models.JobFailed:
+			progress.Failed++
+		case models.JobCancelled:
+			progress.Cancelled++
+		}
+		text += fmt.Sprintf("%s %s\n", j.ID, hashText(&j))
+	}
+	progress.Finished = progress.Queued+progress.Running == 0
+	text = fmt.Sprintf("Finished %d of %d jobs\n", progress.Done+progress.Failed+progress.Cancelled, progress.Total) + text
+	viewmodels.Result(ctx, progress, text)
+}
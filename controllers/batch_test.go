@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"rest/models"
+	"rest/viewmodels"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+var generateBatchTests = []struct {
+	number             int
+	body               string
+	expectedOutput     string
+	expectedStatusCode int
+	expectedQueued     int
+}{
+	{0, `["1", {"input": "2", "duration": "10s"}]`, "", fasthttp.StatusOK, 2},
+	{1, `[]`, "invalid input, batch must contain between 1 and 3 jobs", fasthttp.StatusBadRequest, 2},
+	{2, `["1", "2", "3", "4"]`, "invalid input, batch must contain between 1 and 3 jobs", fasthttp.StatusBadRequest, 2},
+	{3, `["1", "abc"]`, "invalid input, job 1", fasthttp.StatusBadRequest, 2},
+	{4, `["1", {"input": "2", "duration": "1h"}]`, "invalid input, job 1: duration must be between 1s and 10m0s", fasthttp.StatusBadRequest, 2},
+	{5, `{"input": "1"}`, "invalid input", fasthttp.StatusBadRequest, 2},
+	{6, `["1", "2", "3"]`, "job queue is full, try again later", fasthttp.StatusTooManyRequests, 2},
+	{7, `["3", "4"]`, "", fasthttp.StatusOK, 4},
+}
+
+// TestGenerateBatch tests that batch is validated and queued as a whole
+func TestGenerateBatch(t *testing.T) {
+	jobs := newTestJobs()
+	cfg := testConfig()
+	cfg.Workers.QueueSize, cfg.Workers.MaxBatch = 4, 3
+	server := newTestServer(jobs, cfg)
+	r := NewRouter(server)
+	ln := fasthttputil.NewInmemoryListener()
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	s := &fasthttp.Server{
+		Handler: r.Handler,
+	}
+	go s.Serve(ln) //nolint:errcheck
+	c := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.SetRequestURI("http://test.com/rest/hash/calc/batch")
+	for _, testCase := range generateBatchTests {
+		req.SetBodyString(testCase.body)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode() != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, res.StatusCode())
+		}
+		if queued(server) != testCase.expectedQueued {
+			t.Errorf("for test #%d, expected %d jobs in queue but got %d", testCase.number, testCase.expectedQueued, queued(server))
+		}
+		if testCase.expectedStatusCode != fasthttp.StatusOK {
+			var got struct {
+				Error struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(res.Body(), &got); err != nil || got.Error.Message != testCase.expectedOutput {
+				t.Errorf("for test #%d, expected %q but got %q", testCase.number, testCase.expectedOutput, res.Body())
+			}
+			continue
+		}
+		var got struct {
+			Data models.Batch `json:"data"`
+		}
+		if err := json.Unmarshal(res.Body(), &got); err != nil {
+			t.Fatalf("for test #%d, unexpected body %q", testCase.number, res.Body())
+		}
+		b, batchJobs, err := jobs.GetBatch(got.Data.ID)
+		if err != nil || len(b.JobIDs) != len(batchJobs) || strings.Join(b.JobIDs, ",") != strings.Join(got.Data.JobIDs, ",") {
+			t.Errorf("for test #%d, expected stored batch %+v but got %+v, %v", testCase.number, got.Data, b, err)
+		}
+	}
+
+	// jobs of rejected batch are not left stored
+	if list, total, _ := jobs.ListJobs(models.JobFilter{Limit: 10}); total != 4 {
+		t.Errorf("expected %d jobs but got %+v", 4, list)
+	}
+	if _, total, _ := jobs.ListJobs(models.JobFilter{Status: models.JobQueued, Limit: 10}); total != 4 {
+		t.Errorf("expected %d queued jobs but got %d", 4, total)
+	}
+	if len(jobs.batches) != 2 {
+		t.Errorf("expected %d batches but got %d", 2, len(jobs.batches))
+	}
+}
+
+// TestGenerateBatchPushFails tests that batch which could not be enqueued is removed without webhooks
+func TestGenerateBatchPushFails(t *testing.T) {
+	var hooks int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hooks, 1)
+	}))
+	defer receiver.Close()
+	cfg := testConfig()
+	cfg.Webhooks.Secret = "secret"
+	jobs := newTestJobs()
+	server := NewMyServer(newTestDB(), &testRedis{}, jobs, &failingQueue{pushErr: errors.New("broker is down")}, nil, cfg)
+	c, stop := listen(server)
+	defer stop()
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.SetRequestURI("http://test.com/rest/hash/calc/batch")
+	req.SetBodyString(`["1", {"input": "2", "callback_url": "` + receiver.URL + `"}]`)
+	if err := c.Do(req, res); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode() != fasthttp.StatusInternalServerError {
+		t.Errorf("expected %d but got %d %q", fasthttp.StatusInternalServerError, res.StatusCode(), res.Body())
+	}
+	server.hooks.running.Wait()
+	if list, total, _ := jobs.ListJobs(models.JobFilter{Limit: 10}); total != 0 || len(jobs.batches) != 0 {
+		t.Errorf("expected no jobs and batches but got %+v, %d batches", list, len(jobs.batches))
+	}
+	if n := atomic.LoadInt32(&hooks); n != 0 {
+		t.Errorf("expected no webhooks but got %d", n)
+	}
+}
+
+var getBatchTests = []struct {
+	number             int
+	uri                string
+	expectedOutput     string
+	expectedStatusCode int
+}{
+	{0, "/rest/hash/batch/a", "Finished 2 of 3 jobs\nx 13\ny PENDING\nz FAILED\n", fasthttp.StatusOK},
+	{1, "/rest/hash/batch/b", "Finished 0 of 1 jobs\n", fasthttp.StatusOK},
+	{2, "/rest/hash/batch/c", "batch not found", fasthttp.StatusNotFound},
+}
+
+// TestGetBatch tests GetBatch
+func TestGetBatch(t *testing.T) {
+	result := 13
+	now := time.Now()
+	jobs := newTestJobs()
+	jobs.CreateBatch(&models.Batch{ID: "a", JobIDs: []string{"x", "y", "z"}, CreatedAt: now}, []models.Job{
+		{ID: "x", Input: "1", Status: models.JobDone, Result: &result},
+		{ID: "y", Input: "2", Status: models.JobRunning},
+		{ID: "z", Input: "3", Status: models.JobFailed, Error: "boom"},
+	})
+	// job of batch b has expired
+	jobs.CreateBatch(&models.Batch{ID: "b", JobIDs: []string{"gone"}, CreatedAt: now}, nil)
+	server := newTestServer(jobs, testConfig())
+	r := NewRouter(server)
+	ln := fasthttputil.NewInmemoryListener()
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	s := &fasthttp.Server{
+		Handler: r.Handler,
+	}
+	go s.Serve(ln) //nolint:errcheck
+	c := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
+	for _, testCase := range getBatchTests {
+		req.SetRequestURI("http://test.com" + testCase.uri)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode() != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, res.StatusCode())
+		}
+		if body, exp := string(res.Body()), testCase.expectedOutput; body != exp {
+			t.Errorf("for test #%d, expected %q but got %q", testCase.number, exp, body)
+		}
+	}
+
+	req.Reset()
+	req.SetRequestURI("http://test.com/rest/hash/batch/a")
+	if err := c.Do(req, res); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Data viewmodels.Batch `json:"data"`
+	}
+	if err := json.Unmarshal(res.Body(), &got); err != nil {
+		t.Fatalf("unexpected body %q", res.Body())
+	}
+	if b := got.Data; b.Total != 3 || b.Done != 1 || b.Running != 1 || b.Failed != 1 || b.Finished || len(b.Jobs) != 3 || *b.Jobs[0].Result != 13 {
+		t.Errorf("unexpected progress %+v", b)
+	}
+}
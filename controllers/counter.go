@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"rest/models"
+	"rest/myerrors"
+	"rest/viewmodels"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// counterAdd and counterSub are operations in paths changing counters
+	counterAdd = "add"
+	counterSub = "sub"
+)
+
+// counterRequest is body of PUT /rest/counter/:name
+// Absent bound means there is none, absent TTL keeps counter forever
+type counterRequest struct {
+	Min *int64 `json:"min"`
+	Max *int64 `json:"max"`
+	TTL string `json:"ttl"`
+}
+
+// counterName returns name of counter in path
+func counterName(ctx *fasthttp.RequestCtx) (string, error) {
+	name, ok := ctx.UserValue("name").(string)
+	if !ok {
+		return "", myerrors.ErrCtxValue
+	}
+	if name == models.ReservedCounter {
+		return "", myerrors.ErrInvalidInput.WithDetail("counter name val is reserved")
+	}
+	if !models.ValidCounterName(name) {
+		return "", myerrors.ErrInvalidInput.WithDetail("counter name must have from 1 to 64 latin letters, digits, '_' or '-'")
+	}
+	return name, nil
+}
+
+// counterDelta returns number counter changes by for op "add" or "sub" and number val
+// The function accepts numbers with leading zeroes and negative numbers.
+func counterDelta(op, val string) (int, error) {
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		log.Println("Invalid counter value:", val)
+		return 0, myerrors.ErrInvalidInput
+	}
+	if op == counterAdd {
+		return n, nil
+	}
+	// check for overflow
+	if n < 0 && n*-1 < 0 || n > 0 && n*-1 > 0 {
+		log.Println("Provided sub value too large")
+		return 0, myerrors.ErrInvalidInput.WithDetail("number is too large")
+	}
+	return -n, nil
+}
+
+// namedCounter returns view of counter
+func namedCounter(c models.Counter) viewmodels.NamedCounter {
+	v := viewmodels.NamedCounter{Name: c.Name, Value: c.Value, Min: c.Min, Max: c.Max}
+	if c.TTL > 0 {
+		v.TTL = c.TTL.String()
+	}
+	return v
+}
+
+// notFound answers 404 like the router does for unknown paths
+func notFound(ctx *fasthttp.RequestCtx) {
+	ctx.Error(fasthttp.StatusMessage(fasthttp.StatusNotFound), fasthttp.StatusNotFound)
+}
+
+// legacyChange returns operation and number of POST /rest/counter/add/:n or /rest/counter/sub/:n.
+// fasthttprouter does not allow static segments next to :name, so these paths are registered as /rest/counter/:name/:op
+// and their segments come in :name and :op. Named counters are changed by /rest/counter/:name/:op/:n only,
+// so counters called add or sub are never mistaken for these paths.
+func legacyChange(ctx *fasthttp.RequestCtx) (op, val string, ok bool) {
+	op, _ = ctx.UserValue("name").(string)
+	val, _ = ctx.UserValue("op").(string)
+	return op, val, op == counterAdd || op == counterSub
+}
+
+// ChangeDefaultCounter handles POST /rest/counter/add/:n and /rest/counter/sub/:n which change default counter
+func (s *MyServer) ChangeDefaultCounter(ctx *fasthttp.RequestCtx) {
+	op, val, ok := legacyChange(ctx)
+	if !ok {
+		notFound(ctx)
+		return
+	}
+	n, err := counterDelta(op, val)
+	if err != nil {
+		viewmodels.Error(ctx, err)
+		return
+	}
+	cnt, err := s.redisConn.SetCounter(models.DefaultCounter, n)
+	if err != nil {
+		log.Println("ChangeDefaultCounter err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	viewmodels.Result(ctx, viewmodels.Counter{Counter: cnt.Value}, fmt.Sprintf("%s Counter is now %d", successMsg, cnt.Value))
+}
+
+// ChangeCounter handles POST /rest/counter/:name/add/:n and /rest/counter/:name/sub/:n
+// Missing counter is created at 0, counter cannot leave its bounds
+func (s *MyServer) ChangeCounter(ctx *fasthttp.RequestCtx) {
+	op, _ := ctx.UserValue("op").(string)
+	if op != counterAdd && op != counterSub {
+		notFound(ctx)
+		return
+	}
+	name, err := counterName(ctx)
+	if err != nil {
+		log.Println("ChangeCounter err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	val, _ := ctx.UserValue("n").(string)
+	n, err := counterDelta(op, val)
+	if err != nil {
+		viewmodels.Error(ctx, err)
+		return
+	}
+	cnt, err := s.redisConn.SetCounter(name, n)
+	if err != nil {
+		log.Println("ChangeCounter err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	viewmodels.Result(ctx, namedCounter(*cnt), fmt.Sprintf("%s Counter %s is now %d", successMsg, name, cnt.Value))
+}
+
+// GetCounter handles GET /rest/counter/:name, missing counter reads as 0
+// GET /rest/counter/val returns default counter in the form it always had
+func (s *MyServer) GetCounter(ctx *fasthttp.RequestCtx) {
+	if ctx.UserValue("name") == models.ReservedCounter {
+		cnt, err := s.redisConn.GetCounter(models.DefaultCounter)
+		if err != nil {
+			log.Println("GetCounter err:", err)
+			viewmodels.Error(ctx, err)
+			return
+		}
+		viewmodels.Result(ctx, viewmodels.Counter{Counter: cnt.Value}, fmt.Sprintf("counter value is %d", cnt.Value))
+		return
+	}
+	name, err := counterName(ctx)
+	if err != nil {
+		log.Println("GetCounter err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	cnt, err := s.redisConn.GetCounter(name)
+	if err != nil {
+		log.Println("GetCounter err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	viewmodels.Result(ctx, namedCounter(*cnt), fmt.Sprintf("counter %s is %d", name, cnt.Value))
+}
+
+// ListCounters handles GET /rest/counter returning existing counters ordered by name
+func (s *MyServer) ListCounters(ctx *fasthttp.RequestCtx) {
+	counters, err := s.redisConn.ListCounters()
+	if err != nil {
+		log.Println("ListCounters err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	res := viewmodels.Counters{Counters: []viewmodels.NamedCounter{}}
+	lines := make([]string, 0, len(counters))
+	for _, c := range counters {
+		res.Counters = append(res.Counters, namedCounter(c))
+		lines = append(lines, fmt.Sprintf("%s: %d", c.Name, c.Value))
+	}
+	viewmodels.Result(ctx, res, strings.Join(lines, "\n"))
+}
+
+// SetCounterBounds handles PUT /rest/counter/:name replacing min, max and TTL of counter
+// Body is JSON like {"min": 0, "max": 100, "ttl": "1h"}, every field is optional.
+// Counter keeps its value, which must be within new bounds; missing counter starts at value nearest to 0 within them.
+func (s *MyServer) SetCounterBounds(ctx *fasthttp.RequestCtx) {
+	name, err := counterName(ctx)
+	if err != nil {
+		log.Println("SetCounterBounds err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	body := ctx.Request.Body()
+	if len(body) == 0 {
+		log.Println("Couldn't get body")
+		viewmodels.Error(ctx, myerrors.ErrBodyNotFound)
+		return
+	}
+	var req counterRequest
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		log.Println("SetCounterBounds err:", err)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail("counter may only have integer min and max and duration ttl"))
+		return
+	}
+	if req.Min != nil && req.Max != nil && *req.Min > *req.Max {
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail("min cannot exceed max"))
+		return
+	}
+	cnt := models.Counter{Name: name, Min: req.Min, Max: req.Max}
+	if req.TTL != "" {
+		if cnt.TTL, err = time.ParseDuration(req.TTL); err != nil || cnt.TTL <= 0 {
+			viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail("ttl must be positive duration, e.g. 1h"))
+			return
+		}
+	}
+	updated, err := s.redisConn.SetCounterBounds(cnt)
+	if err != nil {
+		log.Println("SetCounterBounds err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	viewmodels.Result(ctx, namedCounter(*updated), fmt.Sprintf("%s Updated bounds of counter %s, it is now %d", successMsg, name, updated.Value))
+}
+
+// DeleteCounter handles DELETE /rest/counter/:name removing counter with its bounds
+func (s *MyServer) DeleteCounter(ctx *fasthttp.RequestCtx) {
+	name, err := counterName(ctx)
+	if err != nil {
+		log.Println("DeleteCounter err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	if err := s.redisConn.DelCounter(name); err != nil {
+		log.Println("DeleteCounter err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	viewmodels.Result(ctx, viewmodels.CounterName{Name: name}, fmt.Sprintf("%s Deleted counter %s", successMsg, name))
+}
@@ -1,9 +1,17 @@
 package controllers
 
 import (
+	"fmt"
 	"net"
+	"rest/config"
+	"rest/models/memory"
+	"rest/models/redis"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/alicebob/miniredis"
+
 	"github.com/valyala/fasthttp"
 	"github.com/valyala/fasthttp/fasthttputil"
 )
@@ -12,7 +20,7 @@ import (
 func TestGetCounter(t *testing.T) {
 	r := NewRouter(
 		&MyServer{
-			db:        &testDB{},
+			db:        newTestDB(),
 			redisConn: &testRedis{},
 		},
 	)
@@ -35,6 +43,7 @@ func TestGetCounter(t *testing.T) {
 		fasthttp.ReleaseRequest(req)
 		fasthttp.ReleaseResponse(res)
 	}()
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
 	req.SetRequestURI("http://test.com/rest/counter/val")
 	req.Header.SetMethod(fasthttp.MethodGet)
 
@@ -71,7 +80,7 @@ var addCounterTests = []struct {
 func TestAddCounter(t *testing.T) {
 	r := NewRouter(
 		&MyServer{
-			db:        &testDB{},
+			db:        newTestDB(),
 			redisConn: &testRedis{},
 		},
 	)
@@ -94,6 +103,7 @@ func TestAddCounter(t *testing.T) {
 		fasthttp.ReleaseRequest(req)
 		fasthttp.ReleaseResponse(res)
 	}()
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
 	req.SetRequestURI("http://test.com/rest/counter/add/")
 	for _, testCase := range addCounterTests {
 		switch testCase.method {
@@ -145,7 +155,7 @@ var subCounterTests = []struct {
 func TestSubCounter(t *testing.T) {
 	r := NewRouter(
 		&MyServer{
-			db:        &testDB{},
+			db:        newTestDB(),
 			redisConn: &testRedis{},
 		},
 	)
@@ -168,6 +178,7 @@ func TestSubCounter(t *testing.T) {
 		fasthttp.ReleaseRequest(req)
 		fasthttp.ReleaseResponse(res)
 	}()
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
 	req.SetRequestURI("http://test.com/rest/counter/sub/")
 	for _, testCase := range subCounterTests {
 		switch testCase.method {
@@ -195,3 +206,131 @@ func TestSubCounter(t *testing.T) {
 		}
 	}
 }
+
+var namedCounterTests = []struct {
+	number             int
+	method             string
+	uri                string
+	body               string
+	expectedOutput     string
+	expectedStatusCode int
+}{
+	{0, fasthttp.MethodPut, "/rest/counter/temp", `{"min": -5, "max": 10, "ttl": "1h"}`, `{"data":{"name":"temp","value":0,"min":-5,"max":10,"ttl":"1h0m0s"}}`, fasthttp.StatusOK},
+	{1, fasthttp.MethodPost, "/rest/counter/temp/sub/5", "", `{"data":{"name":"temp","value":-5,"min":-5,"max":10,"ttl":"1h0m0s"}}`, fasthttp.StatusOK},
+	{2, fasthttp.MethodPost, "/rest/counter/temp/sub/1", "", `{"error":{"code":"counter_out_of_bounds","message":"counter cannot go below its min or above its max"}}`, fasthttp.StatusBadRequest},
+	{3, fasthttp.MethodPost, "/rest/counter/temp/add/15", "", `{"data":{"name":"temp","value":10,"min":-5,"max":10,"ttl":"1h0m0s"}}`, fasthttp.StatusOK},
+	{4, fasthttp.MethodPost, "/rest/counter/temp/mul/2", "", "Not Found", fasthttp.StatusNotFound},
+	{5, fasthttp.MethodPost, "/rest/counter/temp/add/ten", "", `{"error":{"code":"invalid_input","message":"invalid input"}}`, fasthttp.StatusBadRequest},
+	{6, fasthttp.MethodGet, "/rest/counter/temp", "", `{"data":{"name":"temp","value":10,"min":-5,"max":10,"ttl":"1h0m0s"}}`, fasthttp.StatusOK},
+	// unnamed routes change default counter
+	{7, fasthttp.MethodPost, "/rest/counter/add/3", "", `{"data":{"counter":3}}`, fasthttp.StatusOK},
+	{8, fasthttp.MethodGet, "/rest/counter/default", "", `{"data":{"name":"default","value":3,"min":0,"max":null}}`, fasthttp.StatusOK},
+	{9, fasthttp.MethodGet, "/rest/counter", "", `{"data":{"counters":[{"name":"default","value":3,"min":0,"max":null},{"name":"temp","value":10,"min":-5,"max":10,"ttl":"1h0m0s"}]}}`, fasthttp.StatusOK},
+	{10, fasthttp.MethodPut, "/rest/counter/temp", `{"max": 5}`, `{"error":{"code":"counter_out_of_bounds","message":"counter cannot go below its min or above its max"}}`, fasthttp.StatusBadRequest},
+	{11, fasthttp.MethodPut, "/rest/counter/temp", `{"min": 3, "max": 1}`, `{"error":{"code":"invalid_input","message":"invalid input, min cannot exceed max"}}`, fasthttp.StatusBadRequest},
+	{12, fasthttp.MethodPut, "/rest/counter/temp", `{"ttl": "-1h"}`, `{"error":{"code":"invalid_input","message":"invalid input, ttl must be positive duration, e.g. 1h"}}`, fasthttp.StatusBadRequest},
+	{13, fasthttp.MethodPut, "/rest/counter/temp", `{"step": 1}`, `{"error":{"code":"invalid_input","message":"invalid input, counter may only have integer min and max and duration ttl"}}`, fasthttp.StatusBadRequest},
+	{14, fasthttp.MethodPut, "/rest/counter/temp", "", `{"error":{"code":"body_not_found","message":"couldn't get body"}}`, fasthttp.StatusBadRequest},
+	{15, fasthttp.MethodGet, "/rest/counter/bad.name", "", `{"error":{"code":"invalid_input","message":"invalid input, counter name must have from 1 to 64 latin letters, digits, '_' or '-'"}}`, fasthttp.StatusBadRequest},
+	// bounds are kept in place
+	{16, fasthttp.MethodPut, "/rest/counter/temp", `{"min": 10}`, `{"data":{"name":"temp","value":10,"min":10,"max":null}}`, fasthttp.StatusOK},
+	{17, fasthttp.MethodPost, "/rest/counter/temp/add/90", "", `{"data":{"name":"temp","value":100,"min":10,"max":null}}`, fasthttp.StatusOK},
+	{18, fasthttp.MethodDelete, "/rest/counter/temp", "", `{"data":{"name":"temp"}}`, fasthttp.StatusOK},
+	{19, fasthttp.MethodDelete, "/rest/counter/temp", "", `{"error":{"code":"counter_not_found","message":"counter not found"}}`, fasthttp.StatusNotFound},
+	{20, fasthttp.MethodGet, "/rest/counter/temp", "", `{"data":{"name":"temp","value":0,"min":0,"max":null}}`, fasthttp.StatusOK},
+	{21, fasthttp.MethodPost, "/rest/counter/mul/5", "", "Not Found", fasthttp.StatusNotFound},
+	{22, fasthttp.MethodDelete, "/rest/counter/default", "", `{"data":{"name":"default"}}`, fasthttp.StatusOK},
+	{23, fasthttp.MethodGet, "/rest/counter/val", "", `{"data":{"counter":0}}`, fasthttp.StatusOK},
+	{24, fasthttp.MethodGet, "/rest/counter", "", `{"data":{"counters":[]}}`, fasthttp.StatusOK},
+	// counters named like legacy paths are ordinary counters
+	{25, fasthttp.MethodPost, "/rest/counter/add/add/3", "", `{"data":{"name":"add","value":3,"min":0,"max":null}}`, fasthttp.StatusOK},
+	{26, fasthttp.MethodPost, "/rest/counter/sub/add/5", "", `{"data":{"name":"sub","value":5,"min":0,"max":null}}`, fasthttp.StatusOK},
+	{27, fasthttp.MethodPost, "/rest/counter/sub/sub/1", "", `{"data":{"name":"sub","value":4,"min":0,"max":null}}`, fasthttp.StatusOK},
+	{28, fasthttp.MethodGet, "/rest/counter/add", "", `{"data":{"name":"add","value":3,"min":0,"max":null}}`, fasthttp.StatusOK},
+	{29, fasthttp.MethodGet, "/rest/counter/sub", "", `{"data":{"name":"sub","value":4,"min":0,"max":null}}`, fasthttp.StatusOK},
+	// val is reserved for the legacy path reading default counter
+	{30, fasthttp.MethodPut, "/rest/counter/val", `{"max": 10}`, `{"error":{"code":"invalid_input","message":"invalid input, counter name val is reserved"}}`, fasthttp.StatusBadRequest},
+	{31, fasthttp.MethodPost, "/rest/counter/val/add/7", "", `{"error":{"code":"invalid_input","message":"invalid input, counter name val is reserved"}}`, fasthttp.StatusBadRequest},
+	{32, fasthttp.MethodDelete, "/rest/counter/val", "", `{"error":{"code":"invalid_input","message":"invalid input, counter name val is reserved"}}`, fasthttp.StatusBadRequest},
+	// legacy paths still change and read default counter only
+	{33, fasthttp.MethodPost, "/rest/counter/add/2", "", `{"data":{"counter":2}}`, fasthttp.StatusOK},
+	{34, fasthttp.MethodPost, "/rest/counter/sub/1", "", `{"data":{"counter":1}}`, fasthttp.StatusOK},
+	{35, fasthttp.MethodGet, "/rest/counter/val", "", `{"data":{"counter":1}}`, fasthttp.StatusOK},
+	{36, fasthttp.MethodGet, "/rest/counter", "", `{"data":{"counters":[{"name":"add","value":3,"min":0,"max":null},{"name":"default","value":1,"min":0,"max":null},{"name":"sub","value":4,"min":0,"max":null}]}}`, fasthttp.StatusOK},
+}
+
+// TestNamedCounters tests routes of named counters on in-memory cache
+func TestNamedCounters(t *testing.T) {
+	c, stop := listen(&MyServer{db: newTestDB(), redisConn: memory.NewCache(0)})
+	defer stop()
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	for _, testCase := range namedCounterTests {
+		req.Reset()
+		req.Header.SetMethod(testCase.method)
+		req.SetRequestURI("http://test.com" + testCase.uri)
+		req.SetBodyString(testCase.body)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode() != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, res.StatusCode())
+		}
+		// JSON bodies end with newline
+		if body := strings.TrimSuffix(string(res.Body()), "\n"); body != testCase.expectedOutput {
+			t.Errorf("for test #%d, expected %s but got %s", testCase.number, testCase.expectedOutput, body)
+		}
+	}
+}
+
+// TestCounterConcurrent tests that concurrent requests to two replicas sharing redis lose no updates
+func TestCounterConcurrent(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	var clients []*fasthttp.Client
+	for i := 0; i < 2; i++ {
+		cache, err := redis.NewRedisCache(config.Redis{Addr: mr.Addr()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cache.Close()
+		c, stop := listen(&MyServer{db: newTestDB(), redisConn: cache})
+		defer stop()
+		clients = append(clients, c)
+	}
+	const workers, rounds = 16, 20
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(c *fasthttp.Client) {
+			defer wg.Done()
+			req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+			defer func() {
+				fasthttp.ReleaseRequest(req)
+				fasthttp.ReleaseResponse(res)
+			}()
+			req.Header.SetMethod(fasthttp.MethodPost)
+			// every round adds 1, counter never goes below zero since adding comes first
+			for k := 0; k < rounds; k++ {
+				for _, uri := range []string{"/rest/counter/add/2", "/rest/counter/sub/1"} {
+					req.SetRequestURI("http://test.com" + uri)
+					if err := c.Do(req, res); err != nil || res.StatusCode() != fasthttp.StatusOK {
+						t.Errorf("%s failed with %d %s, %v", uri, res.StatusCode(), res.Body(), err)
+						return
+					}
+				}
+			}
+		}(clients[i%len(clients)])
+	}
+	wg.Wait()
+	val, err := mr.Get("counter")
+	if expected := fmt.Sprint(workers * rounds); err != nil || val != expected {
+		t.Errorf("expected counter %s but got %q, %v", expected, val, err)
+	}
+}
@@ -19,9 +19,9 @@ var emailTestTable = []struct {
 }{
 	{0, `"Email:__email@gmail.com\nEmail:__\n__\nram.osp98@gmail.com\n__dog$@krispie.hrEmail:__dog@krispie.hr Email:__________________ram.osp98@krispie.hr\n"`, "email@gmail.com, ram.osp98@gmail.com, dog@krispie.hr, ram.osp98@krispie.hr", fasthttp.StatusOK, fasthttp.MethodPost},
 	{1, `"Email:__valid@sss.com"`, "valid@sss.com", fasthttp.StatusOK, fasthttp.MethodPost},
-	{2, `"Email:__ывлыв@sss.com"`, "invalid input", fasthttp.StatusNotFound, fasthttp.MethodPost},
-	{3, `""`, "invalid input", fasthttp.StatusNotFound, fasthttp.MethodPost},
-	{4, `""`, "invalid input", fasthttp.StatusNotFound, fasthttp.MethodPost},
+	{2, `"Email:__ывлыв@sss.com"`, "no match found", fasthttp.StatusNotFound, fasthttp.MethodPost},
+	{3, `""`, "no match found", fasthttp.StatusNotFound, fasthttp.MethodPost},
+	{4, `""`, "no match found", fasthttp.StatusNotFound, fasthttp.MethodPost},
 	{5, `"вдаьц"`, "", fasthttp.StatusMethodNotAllowed, fasthttp.MethodGet},
 }
 
@@ -52,6 +52,7 @@ func TestEmailHandler(t *testing.T) {
 		fasthttp.ReleaseRequest(req)
 		fasthttp.ReleaseResponse(res)
 	}()
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
 	req.Header.SetMethod(fasthttp.MethodGet)
 	req.SetRequestURI("http://test.com/rest/email")
 	if err := c.Do(req, res); err != nil {
@@ -93,6 +94,7 @@ func TestGetEmail(t *testing.T) {
 		fasthttp.ReleaseRequest(req)
 		fasthttp.ReleaseResponse(res)
 	}()
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
 	req.SetRequestURI("http://test.com/rest/email/check")
 	for _, testCase := range emailTestTable {
 		switch testCase.method {
@@ -131,9 +133,9 @@ var IINTestTable = []struct {
 }{
 	{0, `"IIN:__980124450084\nIIN:__\n__\n980124450084\n__91891IIN:__111111111111 IIN:__________________98012445008444\n"`, "980124450084 980124450084 ", fasthttp.StatusOK, fasthttp.MethodPost},
 	{1, `"IIN:__980124450084"`, "980124450084", fasthttp.StatusOK, fasthttp.MethodPost},
-	{2, `"IIN:__ывлыв  IIN:___\n\n90813901824218947"`, "invalid input", fasthttp.StatusBadRequest, fasthttp.MethodPost},
+	{2, `"IIN:__ывлыв  IIN:___\n\n90813901824218947"`, "no match found", fasthttp.StatusNotFound, fasthttp.MethodPost},
 	{3, ``, "invalid input", fasthttp.StatusBadRequest, fasthttp.MethodPost},
-	{4, `""`, "invalid input", fasthttp.StatusBadRequest, fasthttp.MethodPost},
+	{4, `""`, "no match found", fasthttp.StatusNotFound, fasthttp.MethodPost},
 	{5, `"вдаьц"`, "", fasthttp.StatusMethodNotAllowed, fasthttp.MethodGet},
 }
 
@@ -164,6 +166,7 @@ func TestGetIIN(t *testing.T) {
 		fasthttp.ReleaseRequest(req)
 		fasthttp.ReleaseResponse(res)
 	}()
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
 	req.SetRequestURI("http://test.com/rest/iin/check")
 	for _, testCase := range IINTestTable {
 		switch testCase.method {
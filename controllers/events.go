@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"rest/models"
+	"rest/myerrors"
+	"rest/viewmodels"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// maxWait limits how long GetHash waits for job to finish
+	maxWait = time.Minute
+	// keepAlive is the interval of comments keeping idle event streams open
+	keepAlive = time.Second * 15
+	// subBuffer is the number of transitions kept for slow subscribers
+	subBuffer = 8
+)
+
+// hub wakes requests waiting for status transitions of jobs
+type hub struct {
+	mx   sync.Mutex
+	subs map[string]map[chan models.Job]struct{}
+}
+
+func newHub() *hub {
+	return &hub{subs: make(map[string]map[chan models.Job]struct{})}
+}
+
+// subscribe returns channel receiving transitions of job with ID and function cancelling subscription
+func (h *hub) subscribe(ID string) (<-chan models.Job, func()) {
+	ch := make(chan models.Job, subBuffer)
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if h.subs[ID] == nil {
+		h.subs[ID] = make(map[chan models.Job]struct{})
+	}
+	h.subs[ID][ch] = struct{}{}
+	return ch, func() {
+		h.mx.Lock()
+		defer h.mx.Unlock()
+		delete(h.subs[ID], ch)
+		if len(h.subs[ID]) == 0 {
+			delete(h.subs, ID)
+		}
+	}
+}
+
+// broadcast sends job to its subscribers without blocking.
+// Subscribers that fall behind lose their oldest transition so that the latest one is always received.
+func (h *hub) broadcast(j models.Job) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	for ch := range h.subs[j.ID] {
+		for sent := false; !sent; {
+			select {
+			case ch <- j:
+				sent = true
+			default:
+				// subscriber may drain the channel in the meantime, so dropping must not block either
+				select {
+				case <-ch:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// publish announces status transition of job to requests waiting on every instance
+// Without shared events or if they fail, only requests of this instance are woken
+func (s *MyServer) publish(j *models.Job) {
+	if s.events != nil {
+		err := s.events.Publish(j)
+		if err == nil {
+			return
+		}
+		log.Println("publish err:", err)
+	}
+	s.hub.broadcast(*j)
+}
+
+// ListenEvents relays transitions published by every instance to waiting requests
+// It is a no-op unless server was created with shared job events
+func (s *MyServer) ListenEvents() error {
+	if s.events == nil {
+		return nil
+	}
+	jobs, err := s.events.Subscribe()
+	if err != nil {
+		return err
+	}
+	go func() {
+		for j := range jobs {
+			s.hub.broadcast(j)
+		}
+	}()
+	return nil
+}
+
+// waitJob waits until job is finished or wait expires and returns its latest state
+func (s *MyServer) waitJob(j *models.Job, transitions <-chan models.Job, wait time.Duration) *models.Job {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for !j.Status.Finished() {
+		select {
+		case next := <-transitions:
+			j = &next
+		case <-timer.C:
+			return j
+		}
+	}
+	return j
+}
+
+// waitArg parses wait query argument of GetHash
+func waitArg(args *fasthttp.Args) (time.Duration, error) {
+	if !args.Has("wait") {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(string(args.Peek("wait")))
+	if err != nil || wait < 0 || wait > maxWait {
+		return 0, myerrors.ErrInvalidInput.WithDetail(fmt.Sprintf("wait must be duration between 0s and %v", maxWait))
+	}
+	return wait, nil
+}
+
+// HashEvents handles GET /rest/hash/result/:id/events streaming status transitions of job as server-sent events.
+// Current state is sent first, stream ends once job is finished.
+func (s *MyServer) HashEvents(ctx *fasthttp.RequestCtx) {
+	ID, ok := ctx.UserValue("id").(string)
+	if !ok {
+		log.Println("HashEvents: couldn't get ID value from context")
+		viewmodels.Error(ctx, myerrors.ErrCtxValue)
+		return
+	}
+	transitions, unsubscribe := s.hub.subscribe(ID)
+	j, err := s.jobs.GetJob(ID)
+	if err != nil {
+		unsubscribe()
+		log.Println("HashEvents err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	ctx.SetContentType("text/event-stream")
+	ctx.Response.Header.Set(fasthttp.HeaderCacheControl, "no-cache")
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+		if err := writeEvent(w, j); err != nil {
+			return
+		}
+		for !j.Status.Finished() {
+			select {
+			case next := <-transitions:
+				if next.Status == j.Status {
+					continue
+				}
+				j = &next
+				if err := writeEvent(w, j); err != nil {
+					return
+				}
+			case <-ticker.C:
+				if _, err := w.WriteString(": keep-alive\n\n"); err != nil {
+					return
+				}
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
+
+// writeEvent writes job as event named after its status and flushes it to client
+func writeEvent(w *bufio.Writer, j *models.Job) error {
+	body, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", j.Status, body); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"net"
+	"rest/models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// subscribed waits until someone subscribes to transitions of job with ID
+func subscribed(t *testing.T, h *hub, ID string) {
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		h.mx.Lock()
+		n := len(h.subs[ID])
+		h.mx.Unlock()
+		if n != 0 {
+			return
+		}
+	}
+	t.Errorf("expected subscriber of job %q", ID)
+}
+
+// TestHubLatest tests that slow subscriber receives latest transition
+func TestHubLatest(t *testing.T) {
+	h := newHub()
+	transitions, unsubscribe := h.subscribe("a")
+	for i := 0; i < subBuffer*2; i++ {
+		h.broadcast(models.Job{ID: "a", Input: strconv.Itoa(i)})
+	}
+	h.broadcast(models.Job{ID: "b"})
+	var last models.Job
+	for len(transitions) != 0 {
+		last = <-transitions
+	}
+	if exp := strconv.Itoa(subBuffer*2 - 1); last.Input != exp {
+		t.Errorf("expected latest transition %q but got %q", exp, last.Input)
+	}
+	unsubscribe()
+	if len(h.subs) != 0 {
+		t.Errorf("expected no subscriptions but got %d", len(h.subs))
+	}
+}
+
+// TestHubConcurrentDrain tests that broadcast to full channel does not block while subscriber drains it
+func TestHubConcurrentDrain(t *testing.T) {
+	h := newHub()
+	transitions, unsubscribe := h.subscribe("a")
+	defer unsubscribe()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-transitions:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10000; i++ {
+			h.broadcast(models.Job{ID: "a", Input: strconv.Itoa(i)})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("broadcast blocked on drained channel")
+	}
+}
+
+var waitHashTests = []struct {
+	number             int
+	uri                string
+	finish             bool
+	expectedOutput     string
+	expectedStatusCode int
+}{
+	{0, "/rest/hash/result/a?wait=5s", true, "Your hash is 5", fasthttp.StatusOK},
+	{1, "/rest/hash/result/b?wait=20ms", false, "Your hash is PENDING", fasthttp.StatusOK},
+	{2, "/rest/hash/result/c?wait=5s", false, "Your hash is 13", fasthttp.StatusOK},
+	{3, "/rest/hash/result/b?wait=2m", false, "invalid input, wait must be duration between 0s and 1m0s", fasthttp.StatusBadRequest},
+	{4, "/rest/hash/result/b?wait=soon", false, "invalid input, wait must be duration between 0s and 1m0s", fasthttp.StatusBadRequest},
+	{5, "/rest/hash/result/x?wait=5s", false, "job not found", fasthttp.StatusNotFound},
+}
+
+// TestWaitHash tests long-polling of GetHash
+func TestWaitHash(t *testing.T) {
+	result := 13
+	server := newTestServer(newTestJobs(
+		models.Job{ID: "a", Input: "1", Status: models.JobRunning},
+		models.Job{ID: "b", Input: "1", Status: models.JobQueued},
+		models.Job{ID: "c", Input: "1", Status: models.JobDone, Result: &result},
+	), testConfig())
+	r := NewRouter(server)
+	ln := fasthttputil.NewInmemoryListener()
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	s := &fasthttp.Server{
+		Handler: r.Handler,
+	}
+	go s.Serve(ln) //nolint:errcheck
+	c := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
+	for _, testCase := range waitHashTests {
+		if testCase.finish {
+			go func() {
+				subscribed(t, server.hub, "a")
+				server.finishJob("a", "5", nil)
+			}()
+		}
+		start := time.Now()
+		req.SetRequestURI("http://test.com" + testCase.uri)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if time.Since(start) > time.Second {
+			t.Errorf("for test #%d, expected response without waiting for whole wait", testCase.number)
+		}
+		if res.StatusCode() != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, res.StatusCode())
+		}
+		if body, exp := string(res.Body()), testCase.expectedOutput; body != exp {
+			t.Errorf("for test #%d, expected %q but got %q", testCase.number, exp, body)
+		}
+	}
+}
+
+// TestHashEvents tests that status transitions of job are streamed until it is finished
+func TestHashEvents(t *testing.T) {
+	jobs := newTestJobs(models.Job{ID: "a", Input: "1", Status: models.JobQueued})
+	server := newTestServer(jobs, testConfig())
+	r := NewRouter(server)
+	ln := fasthttputil.NewInmemoryListener()
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	s := &fasthttp.Server{
+		Handler: r.Handler,
+	}
+	go s.Serve(ln) //nolint:errcheck
+	c := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+
+	go func() {
+		subscribed(t, server.hub, "a")
+		server.startJob("a", func() {})
+		server.finishJob("a", "3", nil)
+	}()
+	req.SetRequestURI("http://test.com/rest/hash/result/a/events")
+	if err := c.DoTimeout(req, res, time.Second*5); err != nil {
+		t.Fatal(err)
+	}
+	if ct := string(res.Header.ContentType()); ct != "text/event-stream" {
+		t.Errorf("expected content type %q but got %q", "text/event-stream", ct)
+	}
+	var statuses []string
+	for _, event := range strings.Split(strings.TrimSpace(string(res.Body())), "\n\n") {
+		lines := strings.Split(event, "\n")
+		if len(lines) != 2 || !strings.HasPrefix(lines[0], "event: ") || !strings.HasPrefix(lines[1], "data: {") {
+			t.Fatalf("unexpected event %q", event)
+		}
+		statuses = append(statuses, strings.TrimPrefix(lines[0], "event: "))
+	}
+	if got, exp := strings.Join(statuses, ","), "queued,running,done"; got != exp {
+		t.Errorf("expected events %q but got %q", exp, got)
+	}
+
+	req.SetRequestURI("http://test.com/rest/hash/result/x/events")
+	if err := c.Do(req, res); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode() != fasthttp.StatusNotFound {
+		t.Errorf("expected %d but got %d", fasthttp.StatusNotFound, res.StatusCode())
+	}
+}
@@ -37,6 +37,7 @@ func TestSubstringHandler(t *testing.T) {
 		fasthttp.ReleaseRequest(req)
 		fasthttp.ReleaseResponse(res)
 	}()
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
 	req.Header.SetMethod(fasthttp.MethodGet)
 	req.SetRequestURI("http://test.com/rest/substr")
 	if err := c.Do(req, res); err != nil {
@@ -99,6 +100,7 @@ func TestGetSubstring(t *testing.T) {
 		fasthttp.ReleaseRequest(req)
 		fasthttp.ReleaseResponse(res)
 	}()
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
 	req.SetRequestURI("http://test.com/rest/substr/find")
 	for _, testCase := range testTable {
 		switch testCase.method {
@@ -6,47 +6,75 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"rest/config"
+	"rest/hashers"
 	"rest/models"
 	"rest/myerrors"
 	"rest/utils"
 	"rest/viewmodels"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/valyala/fasthttp"
-	"golang.org/x/sync/semaphore"
 )
 
 type MyServer struct {
 	db        models.MySQLInterface
 	redisConn models.RedisInterface
-	jobQueue  chan job
+	jobs      models.JobStore
+	events    models.JobEvents
+	hub       *hub
+	queue     models.JobQueue
 	workers   *workers
+	pool      *pool
+	hooks     *webhooks
+	hashers   *hashers.Registry
+	// idempotencyTTL is how long responses of idempotent requests are kept
+	idempotencyTTL time.Duration
+	users          config.Users
+	// qmx guards closing so that no job is pushed after Shutdown
+	qmx     sync.RWMutex
+	closing bool
 }
 
-type job struct {
-	ID   string
-	hash int64
-}
-
-type workers struct {
-	mx  *sync.Mutex
-	sem *semaphore.Weighted
-}
-
-// NewMyServer returns MyServer instance for given MySQK and RedisCache
-func NewMyServer(db models.MySQLInterface, r models.RedisInterface) *MyServer {
-	return &MyServer{
+// NewMyServer returns MyServer instance for given MySQL, RedisCache, job store, job queue, job events and config
+// Nil events relay status transitions of jobs only within this instance.
+func NewMyServer(db models.MySQLInterface, r models.RedisInterface, jobs models.JobStore, queue models.JobQueue, events models.JobEvents, cfg *config.Config) *MyServer {
+	ctx, cancel := context.WithCancel(context.Background())
+	dispatch, stopDispatch := context.WithCancel(context.Background())
+	s := &MyServer{
 		db:        db,
 		redisConn: r,
-		jobQueue:  make(chan job, 2048),
+		jobs:      jobs,
+		events:    events,
+		hub:       newHub(),
+		queue:     queue,
 		workers: &workers{
-			mx:  &sync.Mutex{},
-			sem: semaphore.NewWeighted(2),
+			mx:           &sync.Mutex{},
+			clock:        utils.RealClock{},
+			cfg:          cfg.Workers,
+			ctx:          ctx,
+			cancel:       cancel,
+			dispatch:     dispatch,
+			stopDispatch: stopDispatch,
+			cancels:      make(map[string]context.CancelFunc),
+			done:         make(chan struct{}),
+		},
+		pool: &pool{
+			workers: make(map[int]*worker),
+		},
+		hooks: &webhooks{
+			cfg:    cfg.Webhooks,
+			client: &fasthttp.Client{},
 		},
+		hashers:        hashers.New(),
+		idempotencyTTL: cfg.Redis.IdempotencyTTL,
+		users:          cfg.Users,
 	}
+	s.hashers.Register(defaultAlgorithm, bitcount{s})
+	return s
 }
 
 const (
@@ -70,17 +98,17 @@ func (s *MyServer) GetSubstring(ctx *fasthttp.RequestCtx) {
 	var str string
 	if err := json.Unmarshal(bodyBytes, &str); err != nil {
 		log.Println("GetSubstring err:", err)
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput)
 		return
 	}
 	log.Printf("GetSubstring: received string %q", str)
 	if str == "" || !utils.IsLatin(str) {
 		log.Println("Invalid or empty string input:", str)
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput)
 		return
 	}
 	substr := utils.LongestSubstring(str)
-	viewmodels.Message(ctx, substr)
+	viewmodels.Result(ctx, viewmodels.Substring{Substring: substr}, substr)
 }
 
 // EmailHandler handles /rest/email path
@@ -95,28 +123,31 @@ func (s *MyServer) GetEmail(ctx *fasthttp.RequestCtx) {
 	bodyBytes := ctx.Request.Body()
 	if err := json.Unmarshal(bodyBytes, &email); err != nil {
 		log.Println("GetEmail err:", err)
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput)
 		return
 	}
 	log.Println("received string:", email)
-	re := regexp.MustCompile(`Email:[_\r\n]+(?P<email>[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4})`)
+	re := regexp.MustCompile(`Email:[_\r\n]+(?P<email>` + utils.EmailPattern + `)`)
 
 	matches := re.FindAll([]byte(email), -1)
 	if len(matches) == 0 {
 		log.Println("GetEmail: match not found")
-		viewmodels.ClientError(ctx, fasthttp.StatusNotFound, myerrors.ErrInvalidInput)
+		viewmodels.Error(ctx, myerrors.ErrNoMatch)
 		return
 	}
 
 	// res stores comma-separated emails
 	var res string
+	emails := make([]string, 0, len(matches))
 	for i, v := range matches {
-		res += string(re.ReplaceAll(v, []byte("$email")))
+		email := string(re.ReplaceAll(v, []byte("$email")))
+		emails = append(emails, email)
+		res += email
 		if i != len(matches)-1 {
 			res += ", "
 		}
 	}
-	viewmodels.Message(ctx, res)
+	viewmodels.Result(ctx, viewmodels.Emails{Emails: emails}, res)
 }
 
 // GetIIN parses string input and outputs all valid IINs separated by space
@@ -126,7 +157,7 @@ func (s *MyServer) GetIIN(ctx *fasthttp.RequestCtx) {
 	bodyBytes := ctx.Request.Body()
 	if err := json.Unmarshal(bodyBytes, &IIN); err != nil {
 		log.Println("GetIIN err:", err)
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput)
 		return
 	}
 
@@ -137,15 +168,17 @@ func (s *MyServer) GetIIN(ctx *fasthttp.RequestCtx) {
 	matches := re.FindAll([]byte(IIN), -1)
 	if len(matches) == 0 {
 		log.Println("GetIIN: match not found")
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
+		viewmodels.Error(ctx, myerrors.ErrNoMatch)
 		return
 	}
 
 	// res stores space-separated IINs
 	var res string
+	IINs := []string{}
 	for i, v := range matches {
 		curr := string(re.ReplaceAll(v, []byte("$iin")))
 		if utils.ValidateIIN(curr) {
+			IINs = append(IINs, curr)
 			res += curr
 			if i != len(matches)-1 {
 				res += " "
@@ -153,296 +186,172 @@ func (s *MyServer) GetIIN(ctx *fasthttp.RequestCtx) {
 		}
 
 	}
-	viewmodels.Message(ctx, res)
-}
-
-// Add implements addition to counter.
-// The function accepts numbers with leading zeroes and negative numbers.
-func (s *MyServer) Add(ctx *fasthttp.RequestCtx, n int) {
-	res, err := s.redisConn.SetCounter(n)
-	if err != nil {
-		log.Println("Add err:", err)
-		if err == myerrors.ErrNegativeCounter {
-			viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, err)
-			return
-		}
-		viewmodels.ServerError(ctx)
-		return
-	}
-	viewmodels.Message(ctx, successMsg+" Counter is now "+res)
-}
-
-// AddCounter adds the number in path to counter
-func (s *MyServer) AddCounter(ctx *fasthttp.RequestCtx) {
-	addVal, ok := ctx.UserValue("add").(string)
-	if !ok {
-		log.Println("Couldn't get add value from context")
-		viewmodels.ServerError(ctx) // maybe wrap around more context
-		return
-	}
-	n, err := strconv.Atoi(addVal)
-	if err != nil {
-		log.Println("Invalid add value:", addVal)
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
-		return
-	}
-	s.Add(ctx, n)
-
-}
-
-// SubCounter substract the number in path from counter
-func (s *MyServer) SubCounter(ctx *fasthttp.RequestCtx) {
-	subVal, ok := ctx.UserValue("sub").(string)
-	if !ok {
-		log.Println("Couldn't get sub value from context")
-		viewmodels.ServerError(ctx) // maybe wrap around more context
-		return
-	}
-	n, err := strconv.Atoi(subVal)
-	if err != nil {
-		log.Println("Invalid subVal:", subVal)
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
-		return
-	}
-	// check for overflow
-	if n < 0 && n*-1 < 0 || n > 0 && n*-1 > 0 {
-		log.Println("Provided sub value too large")
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, fmt.Errorf("%w, number is too large", myerrors.ErrInvalidInput))
-		return
-	}
-	n *= -1
-	s.Add(ctx, n)
-}
-
-// GetCounter gets counter's current value
-func (s *MyServer) GetCounter(ctx *fasthttp.RequestCtx) {
-	counter, err := s.redisConn.GetCounter()
-	if err != nil {
-		log.Println("GetCounter err:", err)
-		viewmodels.ServerError(ctx)
-		return
-	}
-	viewmodels.Message(ctx, fmt.Sprintf("counter value is %s", counter))
+	viewmodels.Result(ctx, viewmodels.IINs{IINs: IINs}, res)
 }
 
 // CreateUser creates new user for provided first- and lastname
 // Request body should be structured as JSON with "first_name" and "last_name"
-// Body must contain both the first- and lastname
+// Body must contain both the first- and lastname following name policy, they are stored in NFC
 func (s *MyServer) CreateUser(ctx *fasthttp.RequestCtx) {
 	var user models.User
 	bodyBytes := ctx.Request.Body()
 	if len(bodyBytes) == 0 {
 		log.Println("Couldn't get body")
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrBodyNotFound)
+		viewmodels.Error(ctx, myerrors.ErrBodyNotFound)
 		return
 	}
-	if err := json.Unmarshal(bodyBytes, &user); err != nil || !utils.ValidateUser(user) {
+	if err := json.Unmarshal(bodyBytes, &user); err != nil {
 		log.Println("Invalid user input:", string(bodyBytes))
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, fmt.Errorf("%w Provide first_name and last_name", myerrors.ErrInvalidInput))
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail("provide first_name and last_name"))
+		return
+	}
+	if err := s.validateUser(&user); err != nil {
+		log.Println("Invalid user input:", string(bodyBytes))
+		viewmodels.Error(ctx, err)
 		return
 	}
 	id, err := s.db.CreateUser(&user)
 	if err != nil {
 		log.Println("Failed to create user:", err)
-		viewmodels.ServerError(ctx)
+		viewmodels.Error(ctx, err)
 		return
 	}
-	viewmodels.Message(ctx, fmt.Sprintf("%s Created new user under ID %d", successMsg, id))
+	viewmodels.Result(ctx, viewmodels.UserID{ID: id}, fmt.Sprintf("%s Created new user under ID %d", successMsg, id))
 
 }
 
 // GetUser retrieves information on user under the provided ID
+// Response carries ETag of user version, If-None-Match with that tag is answered with 304
 func (s *MyServer) GetUser(ctx *fasthttp.RequestCtx) {
 	ID, ok := ctx.UserValue("id").(string)
 	if !ok {
 		log.Println("Couldn't get ID from context")
-		viewmodels.ServerError(ctx)
+		viewmodels.Error(ctx, myerrors.ErrCtxValue)
 		return
 	}
 	if !utils.ValidateID(ID) {
 		log.Println("Invalid ID:", ID)
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput)
 		return
 	}
 	user, err := s.db.GetUser(ID)
 	if err != nil {
 		log.Println("GetUser err:", err)
-		if err == myerrors.ErrUserNotFound {
-			viewmodels.ClientError(ctx, fasthttp.StatusNotFound, err)
-			return
-		}
-		viewmodels.ServerError(ctx)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	etag := userETag(user.Version)
+	if inm := ctx.Request.Header.Peek(fasthttp.HeaderIfNoneMatch); len(inm) > 0 && matchETag(string(inm), etag, true) {
+		ctx.NotModified()
+		ctx.Response.Header.Set(fasthttp.HeaderETag, etag)
 		return
 	}
+	ctx.Response.Header.Set(fasthttp.HeaderETag, etag)
 	viewmodels.JSON(ctx, user)
 }
 
-// UpdateUser updates user by ID to provided data
-// Request body should be structured as JSON with "first_name" and "last_name"
-// Body should contain either first- or lastname, both cannot be empty
+// UpdateUser replaces user by ID with provided data
+// Request body should be structured as JSON with "first_name", "last_name", "email" and "iin"
+// Names are required like in CreateUser, omitted email and iin are cleared
+// If-Match makes update conditional on ETag returned by GetUser, 412 is returned if user has changed since
 func (s *MyServer) UpdateUser(ctx *fasthttp.RequestCtx) {
 	ID, ok := ctx.UserValue("id").(string)
 	if !ok {
 		log.Println("Couldn't get ID from context")
-		viewmodels.ServerError(ctx)
+		viewmodels.Error(ctx, myerrors.ErrCtxValue)
 		return
 	}
 	if !utils.ValidateID(ID) {
 		log.Println("Invalid ID:", ID)
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput)
 		return
 	}
 	bodyBytes := ctx.Request.Body()
 	if len(bodyBytes) == 0 {
 		log.Println("Couldn't get body")
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
+		viewmodels.Error(ctx, myerrors.ErrBodyNotFound)
 		return
 	}
-	var user models.User
-	if err := json.Unmarshal(bodyBytes, &user); err != nil {
+	var in models.User
+	if err := json.Unmarshal(bodyBytes, &in); err != nil {
 		log.Println("Invalid user input:", string(bodyBytes))
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput)
 		return
 	}
-	if firstName, lastName := user.FirstName, user.LastName; firstName != "" && !utils.IsLatin(firstName) || lastName != "" && !utils.IsLatin(lastName) || firstName == "" && lastName == "" {
-		log.Println("Invalid first- or lastname or both are empty")
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
+	// ID, version and timestamps belong to the store
+	user := models.User{FirstName: in.FirstName, LastName: in.LastName, Email: in.Email, IIN: in.IIN}
+	if err := s.validateUser(&user); err != nil {
+		log.Println("Invalid user input:", string(bodyBytes))
+		viewmodels.Error(ctx, err)
 		return
 	}
-	if err := s.db.UpdateUser(ID, user); err != nil {
+	if len(ctx.Request.Header.Peek(fasthttp.HeaderIfMatch)) > 0 {
+		current, err := s.db.GetUser(ID)
+		if err != nil {
+			log.Println("UpdateUser err:", err)
+			viewmodels.Error(ctx, err)
+			return
+		}
+		if err := ifMatch(ctx, current); err != nil {
+			log.Println("UpdateUser err:", err)
+			viewmodels.Error(ctx, err)
+			return
+		}
+		// store rejects update if user changes after the check
+		user.Version = current.Version
+	}
+	updated, err := s.db.UpdateUser(ID, user)
+	if err != nil {
 		log.Println(err)
-		viewmodels.ServerError(ctx)
+		viewmodels.Error(ctx, err)
 		return
 	}
-	viewmodels.Message(ctx, fmt.Sprintf("%s Updated user under ID %s. To view changes, go to /rest/user/%s.", successMsg, ID, ID))
+	userUpdated(ctx, updated)
 }
 
 // DeleteUser deletes user, if such exists, by ID
+// Deleted user can be restored with RestoreUser until they are purged
 func (s *MyServer) DeleteUser(ctx *fasthttp.RequestCtx) {
 	ID, ok := ctx.UserValue("id").(string)
 	if !ok {
 		log.Println("Couldn't get ID from context")
-		viewmodels.ServerError(ctx)
+		viewmodels.Error(ctx, myerrors.ErrCtxValue)
 		return
 	}
 	if !utils.ValidateID(ID) {
 		log.Println("User provided invalid ID")
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput)
 		return
 	}
 	if err := s.db.DeleteUser(ID); err != nil {
 		log.Println("DeleteUser err:", err)
-		if err == myerrors.ErrUserNotFound {
-			viewmodels.ClientError(ctx, fasthttp.StatusNotFound, err)
-			return
-		}
-		viewmodels.ServerError(ctx)
+		viewmodels.Error(ctx, err)
 		return
 	}
-	viewmodels.Message(ctx, fmt.Sprintf("%s Deleted user under ID %s", successMsg, ID))
+	id, _ := strconv.ParseInt(ID, 10, 64)
+	viewmodels.Result(ctx, viewmodels.UserID{ID: id}, fmt.Sprintf("%s Deleted user under ID %s", successMsg, ID))
 }
 
-// HashHandler hadnles /rest/hash
-func (s *MyServer) HashHandler(ctx *fasthttp.RequestCtx) {
-	viewmodels.Message(ctx, hashMsg)
-}
-
-// GenerateHash handles /rest/hash/calc
-func (s *MyServer) GenerateHash(ctx *fasthttp.RequestCtx) {
-
-	var strInput string
-	bodyBytes := ctx.Request.Body()
-	if len(bodyBytes) == 0 {
-		log.Println("Generate hash err: empty body")
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
-		return
-	}
-	if err := json.Unmarshal(bodyBytes, &strInput); err != nil || strInput == "" {
-		log.Println("Generate hash err:", err)
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
-		return
-	}
-	hash, err := strconv.ParseInt(strInput, 10, 64)
-	if err != nil {
-		log.Println("Generate hash err:", err)
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
-		return
-	}
-	ID := uuid.New().String()
-	//viewmodels.Message(ctx, fmt.Sprintf("Your id is %s", ID))
-	log.Println("generated uuid", ID)
-	s.redisConn.Set(ID, pendingMsg)
-	s.jobQueue <- job{ID, hash}
-	viewmodels.Message(ctx, fmt.Sprintf("We have received your request and assigned the ID %s", ID))
-}
-
-// MakeHash implements hash generation logic
-func (s *MyServer) MakeHash(ctx context.Context, hash int64, ID string) error {
-	ticker := time.NewTicker(time.Second * 5)
-	defer ticker.Stop()
-	defer s.workers.sem.Release(1)
-	for {
-		select {
-		case <-ticker.C:
-			fmt.Println(ID)
-			nsec := s.workers.GetTimestamp()
-			hash = hash & nsec
-		case <-ctx.Done():
-			res := strconv.Itoa(utils.CountBits(hash))
-			log.Println(fmt.Sprintf("Generated hash %s for ID %s", res, ID))
-			return s.redisConn.Set(ID, res)
-		}
-	}
-}
-
-// GetTimestamp gets current timestamp
-func (w *workers) GetTimestamp() int64 {
-	w.mx.Lock()
-	defer w.mx.Unlock()
-	now := time.Now()
-	return int64(now.UnixNano())
-}
-
-// GetHash retrieves hash for given ID
-// If hash is not yet generated, it returns "PENDING"
-func (s *MyServer) GetHash(ctx *fasthttp.RequestCtx) {
+// RestoreUser brings back user deleted by DeleteUser
+func (s *MyServer) RestoreUser(ctx *fasthttp.RequestCtx) {
 	ID, ok := ctx.UserValue("id").(string)
 	if !ok {
-		log.Println("GetHash: couldn't get ID value from context")
-		viewmodels.ServerError(ctx)
+		log.Println("Couldn't get ID from context")
+		viewmodels.Error(ctx, myerrors.ErrCtxValue)
 		return
 	}
-	if ID == "" {
-		log.Println("GetHash: invalid ID")
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
+	if !utils.ValidateID(ID) {
+		log.Println("User provided invalid ID")
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput)
 		return
 	}
-	hash, err := s.redisConn.Get(ID)
-	if err != nil {
-		if err == myerrors.ErrNotFound {
-			log.Println("GetHash: ID doesn't exist")
-			viewmodels.ClientError(ctx, fasthttp.StatusNotFound, myerrors.ErrInvalidInput)
-			return
-		}
-		log.Println("GetHash err:", err)
-		viewmodels.ServerError(ctx)
+	if err := s.db.RestoreUser(ID); err != nil {
+		log.Println("RestoreUser err:", err)
+		viewmodels.Error(ctx, err)
 		return
 	}
-	viewmodels.Message(ctx, fmt.Sprintf("Your hash is %s", hash))
-}
-
-//DOCKER_BUILDKIT=1 docker build .
-
-// DispatchWorkers runs workers upon server initialization waiting for tasks
-func (s *MyServer) DispatchWorkers() {
-	for job := range s.jobQueue {
-		// must defer cancel!
-		c, _ := context.WithTimeout(context.Background(), time.Minute)
-		if err := s.workers.sem.Acquire(c, 1); err != nil {
-			fmt.Println(fmt.Errorf("wait for resources: %w", err))
-		}
-		go s.MakeHash(c, job.hash, job.ID)
-	}
+	id, _ := strconv.ParseInt(ID, 10, 64)
+	viewmodels.Result(ctx, viewmodels.UserID{ID: id}, fmt.Sprintf("%s Restored user under ID %s", successMsg, ID))
 }
 
 // GetIdentifiers finds all identifiers with specified name
@@ -450,21 +359,27 @@ func (s *MyServer) GetIdentifiers(ctx *fasthttp.RequestCtx) {
 	str, ok := ctx.UserValue("str").(string)
 	if !ok {
 		log.Println("GetIdentifiers: couldn't get ID value from context")
-		viewmodels.ServerError(ctx)
+		viewmodels.Error(ctx, myerrors.ErrCtxValue)
 		return
 	}
 	if str == "" {
 		log.Println("GetIdentifiers: invalid ID")
-		viewmodels.ClientError(ctx, fasthttp.StatusBadRequest, myerrors.ErrInvalidInput)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput)
 		return
 	}
 	fmt.Println(str, dir)
 	res, err := utils.GetIdentifiers(str, dir)
 	if err != nil {
 		log.Println("GetIdentifiers err:", err)
-		viewmodels.ServerError(ctx)
+		viewmodels.Error(ctx, err)
 		return
 	}
-	viewmodels.Message(ctx, string(res))
+	identifiers := []string{}
+	for _, line := range strings.Split(string(res), "\n") {
+		if line != "" {
+			identifiers = append(identifiers, line)
+		}
+	}
+	viewmodels.Result(ctx, viewmodels.Identifiers{Identifiers: identifiers}, string(res))
 
 }
@@ -0,0 +1,651 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"rest/config"
+	"rest/hashers"
+	"rest/models"
+	"rest/myerrors"
+	"rest/utils"
+	"rest/viewmodels"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	defaultJobsLimit = 20
+	maxJobsLimit     = 100
+	// queueRetry is the interval of attempts to push job to full queue
+	queueRetry = time.Millisecond * 100
+	// defaultAlgorithm is used for requests that do not set algorithm
+	defaultAlgorithm = "bitcount"
+)
+
+type job struct {
+	ID     string
+	input  string
+	hasher hashers.Hasher
+	params hashers.Params
+}
+
+// hashRequest is the body of /rest/hash/calc
+// Plain JSON string is accepted as input computed with default algorithm, duration and interval
+type hashRequest struct {
+	Input       string          `json:"input"`
+	Algorithm   string          `json:"algorithm"`
+	Duration    models.Duration `json:"duration"`
+	Interval    models.Duration `json:"interval"`
+	CallbackURL string          `json:"callback_url"`
+}
+
+func (r *hashRequest) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &r.Input); err == nil {
+		return nil
+	}
+	type plain hashRequest
+	return json.Unmarshal(b, (*plain)(r))
+}
+
+type workers struct {
+	mx    *sync.Mutex
+	clock utils.Clock
+	cfg   config.Workers
+	// ctx is cancelled to abort running jobs on shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
+	// dispatch is cancelled to stop taking jobs from the queue on shutdown
+	dispatch     context.Context
+	stopDispatch context.CancelFunc
+	// jobsMx guards status transitions of jobs and cancels of running ones
+	jobsMx  sync.Mutex
+	cancels map[string]context.CancelFunc
+	// done is closed once DispatchWorkers returns
+	done chan struct{}
+}
+
+// HashHandler hadnles /rest/hash
+func (s *MyServer) HashHandler(ctx *fasthttp.RequestCtx) {
+	viewmodels.Message(ctx, hashMsg)
+}
+
+// GenerateHash handles /rest/hash/calc
+// Duration and interval of computation may be set in the body, otherwise defaults from config are used
+func (s *MyServer) GenerateHash(ctx *fasthttp.RequestCtx) {
+
+	var req hashRequest
+	bodyBytes := ctx.Request.Body()
+	if len(bodyBytes) == 0 {
+		log.Println("Generate hash err: empty body")
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput)
+		return
+	}
+	if err := json.Unmarshal(bodyBytes, &req); err != nil {
+		log.Println("Generate hash err:", err)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput)
+		return
+	}
+	j, err := s.parseJob(req)
+	if err != nil {
+		log.Println("Generate hash err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	log.Println("generated uuid", j.ID)
+	if err := s.jobs.CreateJob(j); err != nil {
+		log.Println("Generate hash err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	s.publish(j)
+	if err := s.enqueue(ctx, j.ID); err != nil {
+		log.Println("Generate hash err:", err)
+		if errors.Is(err, myerrors.ErrQueueFull) {
+			ctx.Response.Header.Set(fasthttp.HeaderRetryAfter, "1")
+		}
+		// client never learns ID of the job, so it is removed rather than cancelled
+		s.discardJobs([]string{j.ID}, func() error {
+			return s.jobs.DeleteJob(j.ID)
+		})
+		viewmodels.Error(ctx, err)
+		return
+	}
+	viewmodels.Result(ctx, j, fmt.Sprintf("We have received your request and assigned the ID %s", j.ID))
+}
+
+// parseJob validates hash request and returns new queued job
+func (s *MyServer) parseJob(req hashRequest) (*models.Job, error) {
+	if req.Input == "" {
+		return nil, myerrors.ErrInvalidInput
+	}
+	if req.CallbackURL != "" && !validCallback(req.CallbackURL) {
+		return nil, myerrors.ErrInvalidInput.WithDetail("callback_url must be absolute http or https URL")
+	}
+	// callbacks signed with empty key could be forged by anyone
+	if req.CallbackURL != "" && s.hooks.cfg.Secret == "" {
+		return nil, myerrors.ErrInvalidInput.WithDetail("callback_url is not accepted until webhook secret is configured")
+	}
+	j := &models.Job{
+		ID:          uuid.New().String(),
+		Input:       req.Input,
+		Algorithm:   req.Algorithm,
+		Duration:    req.Duration,
+		Interval:    req.Interval,
+		CallbackURL: req.CallbackURL,
+		Status:      models.JobQueued,
+		CreatedAt:   s.workers.clock.Now().UTC(),
+	}
+	if _, err := s.newJob(j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// newJob validates stored job and returns job to be computed
+// Empty algorithm, zero duration and interval are replaced with defaults
+func (s *MyServer) newJob(j *models.Job) (job, error) {
+	if j.Algorithm == "" {
+		j.Algorithm = defaultAlgorithm
+	}
+	hasher, err := s.hashers.Get(j.Algorithm)
+	if err != nil {
+		return job{}, err
+	}
+	if err := hasher.Validate(j.Input); err != nil {
+		return job{}, err
+	}
+	cfg := s.workers.cfg
+	if j.Duration == 0 {
+		j.Duration = models.Duration(cfg.Duration)
+	}
+	if j.Interval == 0 {
+		j.Interval = models.Duration(cfg.Interval)
+	}
+	duration, interval := time.Duration(j.Duration), time.Duration(j.Interval)
+	if duration < cfg.MinDuration || duration > cfg.MaxDuration {
+		return job{}, myerrors.ErrInvalidInput.WithDetail(fmt.Sprintf("duration must be between %v and %v", cfg.MinDuration, cfg.MaxDuration))
+	}
+	if interval < cfg.MinInterval || interval > cfg.MaxInterval || interval > duration {
+		return job{}, myerrors.ErrInvalidInput.WithDetail(fmt.Sprintf("interval must be between %v and %v and not exceed duration", cfg.MinInterval, cfg.MaxInterval))
+	}
+	return job{j.ID, j.Input, hasher, hashers.Params{Duration: duration, Interval: interval}}, nil
+}
+
+// bitcount is the default algorithm computing MakeHash of numeric input
+type bitcount struct {
+	s *MyServer
+}
+
+// Validate reports whether input is int64
+func (b bitcount) Validate(input string) error {
+	if _, err := strconv.ParseInt(input, 10, 64); err != nil {
+		return myerrors.ErrInvalidInput.Wrap(err)
+	}
+	return nil
+}
+
+// Hash returns number of bits left in input after MakeHash
+func (b bitcount) Hash(ctx context.Context, input string, p hashers.Params) (string, error) {
+	hash, err := strconv.ParseInt(input, 10, 64)
+	if err != nil {
+		return "", myerrors.ErrInvalidInput.Wrap(err)
+	}
+	res, err := b.s.MakeHash(ctx, hash, p.Duration, p.Interval)
+	return strconv.Itoa(res), err
+}
+
+// MakeHash implements hash generation logic
+// Timestamp is AND-ed with hash on every tick of interval during duration.
+// If ctx is cancelled earlier its error is returned.
+func (s *MyServer) MakeHash(ctx context.Context, hash int64, duration, interval time.Duration) (int, error) {
+	ticker := s.workers.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for i := 0; i < int(duration/interval); i++ {
+		select {
+		case <-ticker.C():
+			nsec := s.workers.GetTimestamp()
+			hash = hash & nsec
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	if rest := duration % interval; rest > 0 {
+		select {
+		case <-s.workers.clock.After(rest):
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	return utils.CountBits(hash), nil
+}
+
+// GetTimestamp gets current timestamp
+func (w *workers) GetTimestamp() int64 {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	now := w.clock.Now()
+	return int64(now.UnixNano())
+}
+
+// GetHash retrieves hash for given ID
+// If hash is not yet generated, it returns "PENDING".
+// With wait query parameter, e.g. ?wait=30s, it responds once job is finished or wait expires.
+func (s *MyServer) GetHash(ctx *fasthttp.RequestCtx) {
+	ID, ok := ctx.UserValue("id").(string)
+	if !ok {
+		log.Println("GetHash: couldn't get ID value from context")
+		viewmodels.Error(ctx, myerrors.ErrCtxValue)
+		return
+	}
+	if ID == "" {
+		log.Println("GetHash: invalid ID")
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput)
+		return
+	}
+	wait, err := waitArg(ctx.QueryArgs())
+	if err != nil {
+		log.Println("GetHash err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	var transitions <-chan models.Job
+	if wait > 0 {
+		// subscribe before reading job so that no transition is missed
+		ch, unsubscribe := s.hub.subscribe(ID)
+		defer unsubscribe()
+		transitions = ch
+	}
+	j, err := s.jobs.GetJob(ID)
+	if err != nil {
+		log.Println("GetHash err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	if wait > 0 {
+		j = s.waitJob(j, transitions, wait)
+	}
+	viewmodels.Result(ctx, j, fmt.Sprintf("Your hash is %s", hashText(j)))
+}
+
+// hashText returns result of job or its status in legacy format
+func hashText(j *models.Job) string {
+	switch j.Status {
+	case models.JobDone:
+		if j.Result != nil {
+			return strconv.Itoa(*j.Result)
+		}
+		if j.Digest != "" {
+			return j.Digest
+		}
+	case models.JobQueued, models.JobRunning:
+		return pendingMsg
+	}
+	return strings.ToUpper(string(j.Status))
+}
+
+// ListJobs handles /rest/hash/jobs returning jobs ordered by creation time
+// Jobs can be filtered by status and paginated with offset and limit query parameters
+func (s *MyServer) ListJobs(ctx *fasthttp.RequestCtx) {
+	args := ctx.QueryArgs()
+	f := models.JobFilter{
+		Status: models.JobStatus(args.Peek("status")),
+		Limit:  defaultJobsLimit,
+	}
+	if f.Status != "" && !f.Status.Valid() {
+		log.Println("ListJobs: invalid status", f.Status)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail("unknown status"))
+		return
+	}
+	var err error
+	if f.Offset, err = intArg(args, "offset", 0); err != nil || f.Offset < 0 {
+		log.Println("ListJobs: invalid offset")
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail("offset must be non-negative"))
+		return
+	}
+	if f.Limit, err = intArg(args, "limit", defaultJobsLimit); err != nil || f.Limit < 1 || f.Limit > maxJobsLimit {
+		log.Println("ListJobs: invalid limit")
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail(fmt.Sprintf("limit must be between 1 and %d", maxJobsLimit)))
+		return
+	}
+	jobs, total, err := s.jobs.ListJobs(f)
+	if err != nil {
+		log.Println("ListJobs err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	var text string
+	for _, j := range jobs {
+		text += fmt.Sprintf("%s %s\n", j.ID, hashText(&j))
+	}
+	viewmodels.Result(ctx, viewmodels.Jobs{Jobs: jobs, Total: total, Offset: f.Offset, Limit: f.Limit}, text)
+}
+
+// intArg parses query argument, def is returned if argument is absent
+func intArg(args *fasthttp.Args, name string, def int) (int, error) {
+	if !args.Has(name) {
+		return def, nil
+	}
+	return strconv.Atoi(string(args.Peek(name)))
+}
+
+//DOCKER_BUILDKIT=1 docker build .
+
+// ResumeJobs enqueues jobs left unfinished by previous run of the server
+// DispatchWorkers should be running so that queue has room for all of them
+func (s *MyServer) ResumeJobs() error {
+	jobs, err := s.jobs.UnfinishedJobs()
+	if err != nil {
+		return err
+	}
+	for _, j := range jobs {
+		if _, err := s.newJob(&j); err != nil {
+			s.workers.jobsMx.Lock()
+			s.storeResult(&j, "", err)
+			s.workers.jobsMx.Unlock()
+			continue
+		}
+		if j.Status == models.JobRunning {
+			j.Status, j.StartedAt = models.JobQueued, nil
+			if err := s.jobs.UpdateJob(&j); err != nil {
+				return err
+			}
+			s.publish(&j)
+		}
+		if err := s.enqueueWait(j.ID); err != nil {
+			return err
+		}
+	}
+	log.Printf("INFO|Resumed %d unfinished hash jobs", len(jobs))
+	return nil
+}
+
+// Shutdown stops accepting hash jobs and waits for workers and callbacks until ctx is done.
+// Jobs left in the queue and jobs still running at the deadline are marked as cancelled.
+// DispatchWorkers must be running for Shutdown to return. The job queue is closed by Shutdown.
+func (s *MyServer) Shutdown(ctx context.Context) error {
+	s.qmx.Lock()
+	if !s.closing {
+		s.closing = true
+		s.workers.stopDispatch()
+	}
+	s.qmx.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		<-s.workers.done
+		// queue is closed only now since workers acknowledge jobs when they finish
+		s.closeQueue()
+		s.hooks.running.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		s.workers.cancel()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+// closeQueue closes the job queue and cancels jobs left in it for this instance
+func (s *MyServer) closeQueue() {
+	if err := s.queue.Close(); err != nil {
+		log.Println("closeQueue err:", err)
+	}
+	for {
+		qj, err := s.queue.Pop(context.Background())
+		if err != nil {
+			return
+		}
+		s.dropJob(qj)
+	}
+}
+
+// enqueue pushes jobs to the queue unless server is shutting down
+func (s *MyServer) enqueue(ctx context.Context, IDs ...string) error {
+	s.qmx.RLock()
+	defer s.qmx.RUnlock()
+	if s.closing {
+		return myerrors.ErrShuttingDown
+	}
+	return s.queue.Push(ctx, IDs...)
+}
+
+// enqueueWait pushes job to the queue waiting for room while it is full
+func (s *MyServer) enqueueWait(ID string) error {
+	ticker := s.workers.clock.NewTicker(queueRetry)
+	defer ticker.Stop()
+	for {
+		err := s.enqueue(context.Background(), ID)
+		if !errors.Is(err, myerrors.ErrQueueFull) {
+			return err
+		}
+		<-ticker.C()
+	}
+}
+
+// isClosing reports whether Shutdown has been called
+func (s *MyServer) isClosing() bool {
+	s.qmx.RLock()
+	defer s.qmx.RUnlock()
+	return s.closing
+}
+
+// CancelHash handles DELETE /rest/hash/result/:id cancelling queued or running job
+func (s *MyServer) CancelHash(ctx *fasthttp.RequestCtx) {
+	ID, ok := ctx.UserValue("id").(string)
+	if !ok {
+		log.Println("CancelHash: couldn't get ID value from context")
+		viewmodels.Error(ctx, myerrors.ErrCtxValue)
+		return
+	}
+	j, err := s.cancelJob(ID)
+	if err != nil {
+		log.Println("CancelHash err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	viewmodels.Result(ctx, j, fmt.Sprintf("%s Cancelled hash request %s", successMsg, ID))
+}
+
+// RetryHash handles POST /rest/hash/result/:id/retry resubmitting failed or cancelled job
+func (s *MyServer) RetryHash(ctx *fasthttp.RequestCtx) {
+	ID, ok := ctx.UserValue("id").(string)
+	if !ok {
+		log.Println("RetryHash: couldn't get ID value from context")
+		viewmodels.Error(ctx, myerrors.ErrCtxValue)
+		return
+	}
+	j, err := s.requeueJob(ID)
+	if err != nil {
+		log.Println("RetryHash err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	if err := s.enqueue(ctx, ID); err != nil {
+		log.Println("RetryHash err:", err)
+		s.cancelJob(ID)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	viewmodels.Result(ctx, j, fmt.Sprintf("%s Resubmitted hash request %s", successMsg, ID))
+}
+
+// runJob computes hash for job keeping its status up to date
+// Job is acknowledged to the queue once its result is stored
+func (s *MyServer) runJob(qj models.QueuedJob) {
+	defer s.ackJob(qj)
+	c, cancel := context.WithCancel(s.workers.ctx)
+	defer cancel()
+	j, ok := s.startJob(qj.ID, cancel)
+	if !ok {
+		return
+	}
+	next, err := s.newJob(j)
+	if err != nil {
+		s.finishJob(j.ID, "", err)
+		return
+	}
+	res, err := next.hasher.Hash(c, next.input, next.params)
+	s.finishJob(j.ID, res, err)
+}
+
+// startJob marks queued job as running and remembers how to cancel it.
+// Jobs cancelled while waiting in the queue and jobs already running on this instance are not started.
+// Running jobs unknown to this instance were left by crashed one and are started again.
+func (s *MyServer) startJob(ID string, cancel context.CancelFunc) (*models.Job, bool) {
+	s.workers.jobsMx.Lock()
+	defer s.workers.jobsMx.Unlock()
+	j, err := s.jobs.GetJob(ID)
+	if err != nil {
+		log.Println("startJob err:", err)
+		return nil, false
+	}
+	if _, ok := s.workers.cancels[ID]; ok || (j.Status != models.JobQueued && j.Status != models.JobRunning) {
+		return nil, false
+	}
+	now := s.workers.clock.Now().UTC()
+	j.Status, j.StartedAt = models.JobRunning, &now
+	if err := s.jobs.UpdateJob(j); err != nil {
+		log.Println("startJob err:", err)
+		return nil, false
+	}
+	s.workers.cancels[ID] = cancel
+	s.publish(j)
+	return j, true
+}
+
+// finishJob stores result of job, cancelled and failed jobs are recognised by err
+// Jobs finished or resubmitted in the meantime, e.g. cancelled and retried by client, are left as they are
+func (s *MyServer) finishJob(ID string, res string, err error) {
+	s.workers.jobsMx.Lock()
+	defer s.workers.jobsMx.Unlock()
+	delete(s.workers.cancels, ID)
+	j, e := s.jobs.GetJob(ID)
+	if e != nil {
+		log.Println("finishJob err:", e)
+		return
+	}
+	if j.Status != models.JobRunning {
+		return
+	}
+	s.storeResult(j, res, err)
+}
+
+// storeResult marks job as finished with result or error, caller holds jobsMx
+// Result of bitcount is stored as number, results of other algorithms as digest
+func (s *MyServer) storeResult(j *models.Job, res string, err error) {
+	now := s.workers.clock.Now().UTC()
+	j.FinishedAt = &now
+	switch {
+	case err == nil:
+		j.Status = models.JobDone
+		// jobs stored before algorithms were introduced have none
+		if j.Algorithm == "" || j.Algorithm == defaultAlgorithm {
+			n, _ := strconv.Atoi(res)
+			j.Result = &n
+		} else {
+			j.Digest = res
+		}
+	case errors.Is(err, context.Canceled):
+		j.Status = models.JobCancelled
+	default:
+		j.Status = models.JobFailed
+		j.Error = err.Error()
+	}
+	if e := s.jobs.UpdateJob(j); e != nil {
+		log.Println("storeResult err:", e)
+		return
+	}
+	log.Printf("Finished hash job %s: result %s, err %v", j.ID, res, err)
+	s.publish(j)
+	s.notify(j)
+}
+
+// dropJob cancels job taken from the queue without running it
+func (s *MyServer) dropJob(qj models.QueuedJob) {
+	if _, err := s.cancelJob(qj.ID); err != nil && !errors.Is(err, myerrors.ErrJobFinished) {
+		log.Println("dropJob err:", err)
+	}
+	s.ackJob(qj)
+}
+
+// discardJobs removes jobs that could not be enqueued with remove and aborts those of them already running.
+// Workers skip removed jobs which still made it to the queue, nothing is published or delivered for them.
+func (s *MyServer) discardJobs(IDs []string, remove func() error) {
+	s.workers.jobsMx.Lock()
+	defer s.workers.jobsMx.Unlock()
+	if err := remove(); err != nil {
+		log.Println("discardJobs err:", err)
+	}
+	for _, ID := range IDs {
+		if cancel, ok := s.workers.cancels[ID]; ok {
+			cancel()
+			delete(s.workers.cancels, ID)
+		}
+	}
+}
+
+// ackJob acknowledges job to the queue
+func (s *MyServer) ackJob(qj models.QueuedJob) {
+	if err := qj.Ack(); err != nil {
+		log.Println("ackJob err:", err)
+	}
+}
+
+// cancelJob marks queued or running job as cancelled and stops its computation
+func (s *MyServer) cancelJob(ID string) (*models.Job, error) {
+	s.workers.jobsMx.Lock()
+	defer s.workers.jobsMx.Unlock()
+	j, err := s.jobs.GetJob(ID)
+	if err != nil {
+		return nil, err
+	}
+	if j.Status.Finished() {
+		return nil, myerrors.ErrJobFinished
+	}
+	now := s.workers.clock.Now().UTC()
+	j.Status, j.FinishedAt = models.JobCancelled, &now
+	if err := s.jobs.UpdateJob(j); err != nil {
+		return nil, err
+	}
+	if cancel, ok := s.workers.cancels[ID]; ok {
+		cancel()
+		delete(s.workers.cancels, ID)
+	}
+	s.publish(j)
+	s.notify(j)
+	return j, nil
+}
+
+// requeueJob resets failed or cancelled job so that it can be enqueued again
+func (s *MyServer) requeueJob(ID string) (*models.Job, error) {
+	s.workers.jobsMx.Lock()
+	defer s.workers.jobsMx.Unlock()
+	j, err := s.jobs.GetJob(ID)
+	if err != nil {
+		return nil, err
+	}
+	if j.Status != models.JobFailed && j.Status != models.JobCancelled {
+		return nil, myerrors.ErrJobNotRetriable
+	}
+	if _, err := s.newJob(j); err != nil {
+		return nil, err
+	}
+	j.Status = models.JobQueued
+	j.StartedAt, j.FinishedAt, j.Result, j.Digest, j.Error = nil, nil, nil, "", ""
+	if err := s.jobs.UpdateJob(j); err != nil {
+		return nil, err
+	}
+	s.publish(j)
+	return j, nil
+}
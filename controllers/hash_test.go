@@ -0,0 +1,482 @@
+package controllers
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"rest/config"
+	"rest/models"
+	"rest/models/memory"
+	"rest/utils"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// testConfig returns default config with single worker
+func testConfig() *config.Config {
+	cfg := config.Default()
+	cfg.Workers.Count, cfg.Workers.QueueSize = 1, 10
+	return cfg
+}
+
+// newTestServer returns server with in-memory job queue
+func newTestServer(jobs models.JobStore, cfg *config.Config) *MyServer {
+	return NewMyServer(newTestDB(), &testRedis{}, jobs, memory.NewQueue(cfg.Workers.QueueSize), nil, cfg)
+}
+
+// queued returns number of jobs waiting in the queue of server
+func queued(s *MyServer) int {
+	return s.queue.(*memory.Queue).Len()
+}
+
+// waitStatus waits until job with ID has status
+func waitStatus(t *testing.T, jobs models.JobStore, ID string, status models.JobStatus) {
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		if j, err := jobs.GetJob(ID); err == nil && j.Status == status {
+			return
+		}
+	}
+	t.Fatalf("expected job %q to be %q", ID, status)
+}
+
+// testClock is utils.Clock returning preset timestamps and ticking on demand
+type testClock struct {
+	mx    sync.Mutex
+	now   []time.Time
+	ticks chan time.Time
+	after []time.Duration
+}
+
+func (c *testClock) Now() time.Time {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	now := c.now[0]
+	c.now = c.now[1:]
+	return now
+}
+
+func (c *testClock) NewTicker(d time.Duration) utils.Ticker {
+	return c
+}
+
+func (c *testClock) After(d time.Duration) <-chan time.Time {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.after = append(c.after, d)
+	ch := make(chan time.Time, 1)
+	ch <- time.Time{}
+	return ch
+}
+
+func (c *testClock) C() <-chan time.Time {
+	return c.ticks
+}
+
+func (c *testClock) Stop() {}
+
+var makeHashTests = []struct {
+	number         int
+	hash           int64
+	duration       time.Duration
+	interval       time.Duration
+	now            []int64
+	expectedResult int
+	expectedAfter  []time.Duration
+}{
+	{0, 0b1111, time.Second * 3, time.Second, []int64{0b1110, 0b0111, 0b1111}, 2, nil},
+	{1, 0b1111, time.Second * 5, time.Second * 2, []int64{0b1011, 0b1001}, 2, []time.Duration{time.Second}},
+	{2, 0b1111, time.Second, time.Second, []int64{0}, 0, nil},
+	{3, -1, time.Second, time.Second, []int64{0b101}, 2, nil},
+}
+
+// TestMakeHash tests MakeHash with clock under test control
+func TestMakeHash(t *testing.T) {
+	for _, testCase := range makeHashTests {
+		clock := &testClock{ticks: make(chan time.Time, len(testCase.now))}
+		for _, n := range testCase.now {
+			clock.now = append(clock.now, time.Unix(0, n))
+			clock.ticks <- time.Time{}
+		}
+		s := newTestServer(newTestJobs(), testConfig())
+		s.workers.clock = clock
+		res, err := s.MakeHash(context.Background(), testCase.hash, testCase.duration, testCase.interval)
+		if err != nil {
+			t.Fatalf("for test #%d, unexpected error %v", testCase.number, err)
+		}
+		if res != testCase.expectedResult {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedResult, res)
+		}
+		if len(clock.now) != 0 {
+			t.Errorf("for test #%d, expected %d ticks but got %d", testCase.number, len(testCase.now), len(testCase.now)-len(clock.now))
+		}
+		if !reflect.DeepEqual(clock.after, testCase.expectedAfter) {
+			t.Errorf("for test #%d, expected waits %v but got %v", testCase.number, testCase.expectedAfter, clock.after)
+		}
+	}
+
+	// computation stops once context is cancelled
+	s := newTestServer(newTestJobs(), testConfig())
+	s.workers.clock = &testClock{ticks: make(chan time.Time)}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.MakeHash(ctx, 1, time.Minute, time.Second); err != context.Canceled {
+		t.Errorf("expected %v but got %v", context.Canceled, err)
+	}
+}
+
+var generateHashTests = []struct {
+	number             int
+	body               string
+	expectedOutput     string
+	expectedStatusCode int
+	expectedDuration   time.Duration
+	expectedInterval   time.Duration
+}{
+	{0, `"15"`, "", fasthttp.StatusOK, time.Minute, time.Second * 5},
+	{1, `{"input": "15", "duration": "10s", "interval": "2s"}`, "", fasthttp.StatusOK, time.Second * 10, time.Second * 2},
+	{2, `{"input": "15", "duration": "2m"}`, "", fasthttp.StatusOK, time.Minute * 2, time.Second * 5},
+	{3, `{"input": "15", "duration": "1h"}`, "invalid input, duration must be between 1s and 10m0s", fasthttp.StatusBadRequest, 0, 0},
+	{4, `{"input": "15", "interval": "10ms"}`, "invalid input, interval must be between 100ms and 1m0s and not exceed duration", fasthttp.StatusBadRequest, 0, 0},
+	{5, `{"input": "15", "duration": "2s", "interval": "3s"}`, "invalid input, interval must be between 100ms and 1m0s and not exceed duration", fasthttp.StatusBadRequest, 0, 0},
+	{6, `{"input": "15", "duration": "soon"}`, "invalid input", fasthttp.StatusBadRequest, 0, 0},
+	{7, `{"duration": "10s"}`, "invalid input", fasthttp.StatusBadRequest, 0, 0},
+	{8, `"abc"`, "invalid input", fasthttp.StatusBadRequest, 0, 0},
+	{9, `{"input": "15", "callback_url": "ftp://example.com"}`, "invalid input, callback_url must be absolute http or https URL", fasthttp.StatusBadRequest, 0, 0},
+	{10, `{"input": "15", "callback_url": "/hook"}`, "invalid input, callback_url must be absolute http or https URL", fasthttp.StatusBadRequest, 0, 0},
+	{11, `{"input": "15", "callback_url": "https://example.com/hook"}`, "", fasthttp.StatusOK, time.Minute, time.Second * 5},
+	{12, `{"input": "hello world", "algorithm": "sha256"}`, "", fasthttp.StatusOK, time.Minute, time.Second * 5},
+	{13, `{"input": "15", "algorithm": "md5"}`, "invalid input, algorithm must be one of argon2id, bitcount, crc32, fnv64, sha256, xxhash", fasthttp.StatusBadRequest, 0, 0},
+}
+
+// TestGenerateHash tests duration and interval set in request body
+func TestGenerateHash(t *testing.T) {
+	jobs := newTestJobs()
+	cfg := testConfig()
+	cfg.Webhooks.Secret = "secret"
+	server := newTestServer(jobs, cfg)
+	r := NewRouter(server)
+	ln := fasthttputil.NewInmemoryListener()
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	s := &fasthttp.Server{
+		Handler: r.Handler,
+	}
+	go s.Serve(ln) //nolint:errcheck
+	c := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
+	req.SetRequestURI("http://test.com/rest/hash/calc")
+	for _, testCase := range generateHashTests {
+		req.SetBodyString(testCase.body)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode() != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, res.StatusCode())
+		}
+		if testCase.expectedStatusCode != fasthttp.StatusOK {
+			if body := string(res.Body()); body != testCase.expectedOutput {
+				t.Errorf("for test #%d, expected %q but got %q", testCase.number, testCase.expectedOutput, body)
+			}
+			continue
+		}
+		qj, err := server.queue.Pop(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if j, _ := jobs.GetJob(qj.ID); time.Duration(j.Duration) != testCase.expectedDuration || time.Duration(j.Interval) != testCase.expectedInterval {
+			t.Errorf("for test #%d, expected %v/%v but got %+v", testCase.number, testCase.expectedDuration, testCase.expectedInterval, j)
+		}
+	}
+}
+
+// TestShutdown tests that jobs unfinished by the deadline are marked as cancelled
+func TestShutdown(t *testing.T) {
+	jobs := newTestJobs()
+	s := newTestServer(jobs, testConfig())
+	go s.DispatchWorkers()
+	for _, ID := range []string{"running", "queued"} {
+		jobs.CreateJob(&models.Job{ID: ID, Input: "1", Status: models.JobQueued})
+		if err := s.enqueue(context.Background(), ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// single worker keeps second job in the queue
+	waitStatus(t, jobs, "running", models.JobRunning)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected %v but got %v", context.DeadlineExceeded, err)
+	}
+	for _, ID := range []string{"running", "queued"} {
+		j, _ := jobs.GetJob(ID)
+		if j.Status != models.JobCancelled || j.FinishedAt == nil {
+			t.Errorf("for job %q, expected %q with finish time but got %q", ID, models.JobCancelled, j.Status)
+		}
+	}
+	if j, _ := jobs.GetJob("running"); j.StartedAt == nil {
+		t.Error("expected running job to have start time")
+	}
+	if err := s.enqueue(context.Background(), "late"); err == nil {
+		t.Error("expected error on enqueue after shutdown")
+	}
+}
+
+var runJobTests = []struct {
+	number         int
+	job            models.Job
+	expectedResult string
+}{
+	{0, models.Job{Input: "123456789", Algorithm: "crc32", Duration: models.Duration(time.Second), Interval: models.Duration(time.Second)}, "cbf43926"},
+	{1, models.Job{Input: "abc", Algorithm: "xxhash"}, "44bc2cf5ad770999"},
+	{2, models.Job{Input: "7"}, "2"},
+}
+
+// TestRunJobAlgorithm tests that job is computed with its algorithm
+func TestRunJobAlgorithm(t *testing.T) {
+	for _, testCase := range runJobTests {
+		clock := &testClock{ticks: make(chan time.Time, 1)}
+		clock.ticks <- time.Time{}
+		for i := 0; i < 3; i++ {
+			clock.now = append(clock.now, time.Unix(0, 0b101))
+		}
+		j := testCase.job
+		j.ID, j.Status = "a", models.JobQueued
+		jobs := newTestJobs(j)
+		s := newTestServer(jobs, testConfig())
+		s.workers.clock = clock
+		// bitcount of default settings would take a minute
+		s.workers.cfg.Duration, s.workers.cfg.Interval = time.Second, time.Second
+		s.runJob(models.QueuedJob{ID: "a", Ack: func() error { return nil }})
+		got, _ := jobs.GetJob("a")
+		if got.Status != models.JobDone || hashText(got) != testCase.expectedResult {
+			t.Errorf("for test #%d, expected %q but got %q of %+v", testCase.number, testCase.expectedResult, hashText(got), got)
+		}
+		if (got.Result != nil) == (got.Digest != "") {
+			t.Errorf("for test #%d, expected either result or digest but got %+v", testCase.number, got)
+		}
+	}
+}
+
+var startJobTests = []struct {
+	number   int
+	status   models.JobStatus
+	owned    bool
+	expected bool
+}{
+	{0, models.JobQueued, false, true},
+	{1, models.JobRunning, false, true},
+	{2, models.JobRunning, true, false},
+	{3, models.JobCancelled, false, false},
+	{4, models.JobDone, false, false},
+}
+
+// TestStartJob tests that only queued jobs and jobs orphaned by other instances are started
+func TestStartJob(t *testing.T) {
+	for _, testCase := range startJobTests {
+		s := newTestServer(newTestJobs(models.Job{ID: "a", Input: "1", Status: testCase.status}), testConfig())
+		if testCase.owned {
+			s.workers.cancels["a"] = func() {}
+		}
+		if _, ok := s.startJob("a", func() {}); ok != testCase.expected {
+			t.Errorf("for test #%d, expected %v but got %v", testCase.number, testCase.expected, ok)
+		}
+	}
+}
+
+// TestResumeJobs tests that unfinished jobs are enqueued again
+func TestResumeJobs(t *testing.T) {
+	now := time.Now()
+	jobs := newTestJobs(
+		models.Job{ID: "queued", Input: "1", Status: models.JobQueued, CreatedAt: now},
+		models.Job{ID: "running", Input: "2", Status: models.JobRunning, CreatedAt: now.Add(time.Second), StartedAt: &now},
+		models.Job{ID: "broken", Input: "abc", Status: models.JobQueued, CreatedAt: now.Add(time.Second * 2)},
+		models.Job{ID: "done", Input: "3", Status: models.JobDone, CreatedAt: now.Add(time.Second * 3)},
+	)
+	s := newTestServer(jobs, testConfig())
+	if err := s.ResumeJobs(); err != nil {
+		t.Fatal(err)
+	}
+	if queued(s) != 2 {
+		t.Fatalf("expected %d jobs in queue but got %d", 2, queued(s))
+	}
+	if qj, _ := s.queue.Pop(context.Background()); qj.ID != "queued" {
+		t.Errorf("expected job %q but got %q", "queued", qj.ID)
+	}
+	if j, _ := jobs.GetJob("running"); j.Status != models.JobQueued || j.StartedAt != nil {
+		t.Errorf("expected running job to be queued again but got %+v", j)
+	}
+	if j, _ := jobs.GetJob("broken"); j.Status != models.JobFailed || j.Error == "" {
+		t.Errorf("expected broken job to fail but got %+v", j)
+	}
+}
+
+var hashJobsTests = []struct {
+	number             int
+	uri                string
+	expectedOutput     string
+	expectedStatusCode int
+}{
+	{0, "/rest/hash/jobs", "a PENDING\nb 13\nc CANCELLED\nd FAILED\n", fasthttp.StatusOK},
+	{1, "/rest/hash/jobs?status=done", "b 13\n", fasthttp.StatusOK},
+	{2, "/rest/hash/jobs?offset=1&limit=2", "b 13\nc CANCELLED\n", fasthttp.StatusOK},
+	{3, "/rest/hash/jobs?offset=10", "", fasthttp.StatusOK},
+	{4, "/rest/hash/jobs?status=unknown", "invalid input, unknown status", fasthttp.StatusBadRequest},
+	{5, "/rest/hash/jobs?limit=0", "invalid input, limit must be between 1 and 100", fasthttp.StatusBadRequest},
+	{6, "/rest/hash/jobs?offset=-1", "invalid input, offset must be non-negative", fasthttp.StatusBadRequest},
+	{7, "/rest/hash/result/b", "Your hash is 13", fasthttp.StatusOK},
+	{8, "/rest/hash/result/a", "Your hash is PENDING", fasthttp.StatusOK},
+	{9, "/rest/hash/result/c", "Your hash is CANCELLED", fasthttp.StatusOK},
+	{10, "/rest/hash/result/x", "job not found", fasthttp.StatusNotFound},
+}
+
+// TestHashJobs tests ListJobs and GetHash
+func TestHashJobs(t *testing.T) {
+	now, result := time.Now(), 13
+	r := NewRouter(
+		&MyServer{
+			db:        newTestDB(),
+			redisConn: &testRedis{},
+			jobs: newTestJobs(
+				models.Job{ID: "a", Status: models.JobRunning, CreatedAt: now},
+				models.Job{ID: "b", Status: models.JobDone, CreatedAt: now.Add(time.Second), Result: &result},
+				models.Job{ID: "c", Status: models.JobCancelled, CreatedAt: now.Add(time.Second * 2)},
+				models.Job{ID: "d", Status: models.JobFailed, CreatedAt: now.Add(time.Second * 3)},
+			),
+		},
+	)
+	ln := fasthttputil.NewInmemoryListener()
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	s := &fasthttp.Server{
+		Handler: r.Handler,
+	}
+	go s.Serve(ln) //nolint:errcheck
+	c := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
+	for _, testCase := range hashJobsTests {
+		req.SetRequestURI("http://test.com" + testCase.uri)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode() != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, res.StatusCode())
+		}
+		if body, exp := string(res.Body()), testCase.expectedOutput; body != exp {
+			t.Errorf("for test #%d, expected %q but got %q", testCase.number, exp, body)
+		}
+	}
+}
+
+var cancelRetryTests = []struct {
+	number             int
+	uri                string
+	expectedOutput     string
+	expectedStatusCode int
+	method             string
+	expectedStatus     models.JobStatus
+}{
+	{0, "/rest/hash/result/running", "Success! Cancelled hash request running", fasthttp.StatusOK, fasthttp.MethodDelete, models.JobCancelled},
+	{1, "/rest/hash/result/queued", "Success! Cancelled hash request queued", fasthttp.StatusOK, fasthttp.MethodDelete, models.JobCancelled},
+	{2, "/rest/hash/result/queued", "job is already finished", fasthttp.StatusConflict, fasthttp.MethodDelete, models.JobCancelled},
+	{3, "/rest/hash/result/queued/retry", "Success! Resubmitted hash request queued", fasthttp.StatusOK, fasthttp.MethodPost, ""},
+	{4, "/rest/hash/result/queued/retry", "only failed or cancelled jobs can be retried", fasthttp.StatusConflict, fasthttp.MethodPost, ""},
+	{5, "/rest/hash/result/missing", "job not found", fasthttp.StatusNotFound, fasthttp.MethodDelete, ""},
+	{6, "/rest/hash/result/missing/retry", "job not found", fasthttp.StatusNotFound, fasthttp.MethodPost, ""},
+}
+
+// TestCancelRetryHash tests CancelHash and RetryHash
+func TestCancelRetryHash(t *testing.T) {
+	jobs := newTestJobs()
+	server := newTestServer(jobs, testConfig())
+	go server.DispatchWorkers()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	for _, ID := range []string{"running", "queued"} {
+		jobs.CreateJob(&models.Job{ID: ID, Input: "1", Status: models.JobQueued})
+		if err := server.enqueue(context.Background(), ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitStatus(t, jobs, "running", models.JobRunning)
+
+	r := NewRouter(server)
+	ln := fasthttputil.NewInmemoryListener()
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	s := &fasthttp.Server{
+		Handler: r.Handler,
+	}
+	go s.Serve(ln) //nolint:errcheck
+	c := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
+	for _, testCase := range cancelRetryTests {
+		req.Header.SetMethod(testCase.method)
+		req.SetRequestURI("http://test.com" + testCase.uri)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode() != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, res.StatusCode())
+		}
+		if body, exp := string(res.Body()), testCase.expectedOutput; body != exp {
+			t.Errorf("for test #%d, expected %q but got %q", testCase.number, exp, body)
+		}
+		if testCase.expectedStatus == "" {
+			continue
+		}
+		ID := strings.Split(testCase.uri, "/")[4]
+		if j, _ := jobs.GetJob(ID); j.Status != testCase.expectedStatus {
+			t.Errorf("for test #%d, expected status %q but got %q", testCase.number, testCase.expectedStatus, j.Status)
+		}
+	}
+	// resubmitted job is started by worker
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		if j, _ := jobs.GetJob("queued"); j.Status == models.JobRunning {
+			return
+		}
+	}
+	t.Error("expected resubmitted job to run")
+}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"rest/myerrors"
+	"rest/viewmodels"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	// replayedHeader marks responses returned from cache of idempotent requests
+	replayedHeader = "Idempotent-Replayed"
+	// idempotencyPrefix prefixes redis keys of idempotent requests
+	idempotencyPrefix = "idempotency:"
+	// idempotencyLock is how long key stays reserved after the last refresh,
+	// so that key of request that never completed, e.g. due to crash, is freed soon
+	idempotencyLock = time.Minute
+	// idempotencyRefresh is how often reservation is extended while request is handled
+	idempotencyRefresh = idempotencyLock / 3
+	maxIdempotencyKey  = 255
+)
+
+// idempotentResponse is stored under Idempotency-Key, zero status marks request in progress
+type idempotentResponse struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Idempotent wraps handler of POST endpoint so that request repeated with the same Idempotency-Key
+// gets the original response instead of being handled again.
+// Key reused with different method, path or body is rejected with 422.
+// Server errors and 429 are not kept so that such requests can be retried with the same key.
+func (s *MyServer) Idempotent(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		key := string(ctx.Request.Header.Peek(idempotencyHeader))
+		if key == "" {
+			h(ctx)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			log.Println("Idempotent: key is too long")
+			viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail("Idempotency-Key must not exceed 255 characters"))
+			return
+		}
+		key = idempotencyPrefix + key
+		fingerprint := requestFingerprint(ctx)
+		pending, err := json.Marshal(idempotentResponse{Fingerprint: fingerprint})
+		if err != nil {
+			log.Println("Idempotent err:", err)
+			viewmodels.Error(ctx, err)
+			return
+		}
+		ok, err := s.redisConn.SetNX(key, string(pending), idempotencyLock)
+		if err != nil {
+			log.Println("Idempotent err:", err)
+			viewmodels.Error(ctx, err)
+			return
+		}
+		if !ok {
+			s.replay(ctx, key, fingerprint)
+			return
+		}
+
+		release := s.holdKey(key, string(pending))
+		h(ctx)
+		release()
+		res := &ctx.Response
+		if code := res.StatusCode(); code >= fasthttp.StatusInternalServerError || code == fasthttp.StatusTooManyRequests {
+			if err := s.redisConn.Del(key); err != nil {
+				log.Println("Idempotent err:", err)
+			}
+			return
+		}
+		stored, err := json.Marshal(idempotentResponse{
+			Fingerprint: fingerprint,
+			Status:      res.StatusCode(),
+			ContentType: string(res.Header.ContentType()),
+			Body:        res.Body(),
+		})
+		if err == nil {
+			err = s.redisConn.SetEX(key, string(stored), s.idempotencyTTL)
+		}
+		if err != nil {
+			log.Println("Idempotent err:", err)
+		}
+	}
+}
+
+// holdKey extends reservation of key while request runs for however long it takes.
+// Returned function stops refreshing and waits for refresh in progress so that it cannot overwrite the response.
+func (s *MyServer) holdKey(key, pending string) func() {
+	ticker := s.workers.clock.NewTicker(idempotencyRefresh)
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C():
+				if err := s.redisConn.SetEX(key, pending, idempotencyLock); err != nil {
+					log.Println("Idempotent err:", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// replay writes response stored under key if it was made for the same request
+func (s *MyServer) replay(ctx *fasthttp.RequestCtx, key, fingerprint string) {
+	val, err := s.redisConn.Get(key)
+	if err != nil {
+		// key expired in the meantime, client may retry
+		if errors.Is(err, myerrors.ErrNotFound) {
+			err = myerrors.ErrIdempotencyBusy
+		}
+		log.Println("Idempotent err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	var stored idempotentResponse
+	if err := json.Unmarshal([]byte(val), &stored); err != nil {
+		log.Println("Idempotent err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	switch {
+	case stored.Fingerprint != fingerprint:
+		log.Println("Idempotent: key reused with different request")
+		viewmodels.Error(ctx, myerrors.ErrIdempotencyKey)
+	case stored.Status == 0:
+		log.Println("Idempotent: key is in use")
+		viewmodels.Error(ctx, myerrors.ErrIdempotencyBusy)
+	default:
+		ctx.SetStatusCode(stored.Status)
+		ctx.SetContentType(stored.ContentType)
+		ctx.Response.Header.Set(replayedHeader, "true")
+		ctx.SetBody(stored.Body)
+	}
+}
+
+// requestFingerprint returns SHA-256 of method, path and body of request
+func requestFingerprint(ctx *fasthttp.RequestCtx) string {
+	h := sha256.New()
+	h.Write(ctx.Method())
+	h.Write([]byte{' '})
+	h.Write(ctx.Path())
+	h.Write([]byte{'\n'})
+	h.Write(ctx.Request.Body())
+	return hex.EncodeToString(h.Sum(nil))
+}
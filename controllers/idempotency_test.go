@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	"net"
+	"rest/config"
+	"rest/models/redis"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+var idempotencyTests = []struct {
+	number             int
+	key                string
+	body               string
+	expectedStatusCode int
+	expectedReplayOf   int
+	expectedJobs       int
+}{
+	{0, "k1", `"15"`, fasthttp.StatusOK, -1, 1},
+	{1, "k1", `"15"`, fasthttp.StatusOK, 0, 1},
+	{2, "k1", `"16"`, fasthttp.StatusUnprocessableEntity, -1, 1},
+	{3, "", `"15"`, fasthttp.StatusOK, -1, 2},
+	{4, "k2", `"abc"`, fasthttp.StatusBadRequest, -1, 2},
+	{5, "k2", `"abc"`, fasthttp.StatusBadRequest, 4, 2},
+	{6, strings.Repeat("k", 256), `"15"`, fasthttp.StatusBadRequest, -1, 2},
+	{7, "k3", `"15"`, fasthttp.StatusConflict, -1, 2},
+	// queue is full, rejected job is not stored
+	{8, "k4", `"17"`, fasthttp.StatusTooManyRequests, -1, 2},
+	{9, "k4", `"17"`, fasthttp.StatusOK, -1, 3},
+}
+
+// TestIdempotent tests that requests repeated with Idempotency-Key get the original response
+func TestIdempotent(t *testing.T) {
+	jobs := newTestJobs()
+	cfg := testConfig()
+	cfg.Workers.QueueSize = 2
+	server := newTestServer(jobs, cfg)
+	redis := server.redisConn.(*testRedis)
+	// k3 is held by request in progress
+	redis.SetNX(idempotencyPrefix+"k3", `{"fingerprint":"`+fingerprintOf(`"15"`)+`","status":0}`, idempotencyLock)
+	r := NewRouter(server)
+	ln := fasthttputil.NewInmemoryListener()
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	s := &fasthttp.Server{
+		Handler: r.Handler,
+	}
+	go s.Serve(ln) //nolint:errcheck
+	c := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	bodies := make([]string, len(idempotencyTests))
+	for _, testCase := range idempotencyTests {
+		if testCase.number == 9 {
+			// make room for retried request
+			server.queue.Pop(context.Background()) //nolint:errcheck
+		}
+		req.Reset()
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetRequestURI("http://test.com/rest/hash/calc")
+		if testCase.key != "" {
+			req.Header.Set(idempotencyHeader, testCase.key)
+		}
+		req.SetBodyString(testCase.body)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		bodies[testCase.number] = string(res.Body())
+		if res.StatusCode() != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, res.StatusCode())
+		}
+		replayed := string(res.Header.Peek(replayedHeader)) == "true"
+		if replayed != (testCase.expectedReplayOf >= 0) {
+			t.Errorf("for test #%d, expected replayed %v but got %v", testCase.number, testCase.expectedReplayOf >= 0, replayed)
+		}
+		if i := testCase.expectedReplayOf; i >= 0 && bodies[i] != bodies[testCase.number] {
+			t.Errorf("for test #%d, expected body %q but got %q", testCase.number, bodies[i], bodies[testCase.number])
+		}
+		if n := len(jobs.jobs); n != testCase.expectedJobs {
+			t.Errorf("for test #%d, expected %d jobs but got %d", testCase.number, testCase.expectedJobs, n)
+		}
+	}
+}
+
+// fingerprintOf returns fingerprint of POST /rest/hash/calc with body
+func fingerprintOf(body string) string {
+	var ctx fasthttp.RequestCtx
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.SetRequestURI("/rest/hash/calc")
+	ctx.Request.SetBodyString(body)
+	return requestFingerprint(&ctx)
+}
+
+// TestIdempotentLongRequest tests that key stays reserved while request outlives idempotencyLock
+func TestIdempotentLongRequest(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	cache, err := redis.NewRedisCache(config.Redis{Addr: mr.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	server := NewMyServer(newTestDB(), cache, newTestJobs(), nil, nil, testConfig())
+	clock := &testClock{ticks: make(chan time.Time)}
+	server.workers.clock = clock
+	var calls int32
+	started, release := make(chan struct{}), make(chan struct{})
+	h := server.Idempotent(func(ctx *fasthttp.RequestCtx) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+			<-release
+		}
+		ctx.SetBodyString("imported")
+	})
+	request := func() *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.SetRequestURI("/rest/user/import")
+		ctx.Request.Header.Set(idempotencyHeader, "k")
+		ctx.Request.SetBodyString(`[]`)
+		return ctx
+	}
+	first := request()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h(first)
+	}()
+	<-started
+
+	key := idempotencyPrefix + "k"
+	for i := 0; i < 3; i++ {
+		mr.FastForward(idempotencyRefresh)
+		select {
+		case clock.ticks <- time.Now():
+		case <-time.After(time.Second):
+			t.Fatal("expected reservation to be refreshed while request runs")
+		}
+		for start := time.Now(); mr.TTL(key) != idempotencyLock; time.Sleep(time.Millisecond) {
+			if time.Since(start) > time.Second {
+				t.Fatalf("expected reservation to be refreshed but ttl is %v", mr.TTL(key))
+			}
+		}
+	}
+	// request has run for idempotencyLock, retry must not handle it again
+	retry := request()
+	h(retry)
+	if retry.Response.StatusCode() != fasthttp.StatusConflict || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("expected %d without handling but got %d after %d calls", fasthttp.StatusConflict, retry.Response.StatusCode(), atomic.LoadInt32(&calls))
+	}
+
+	close(release)
+	<-done
+	replayed := request()
+	h(replayed)
+	if string(replayed.Response.Body()) != "imported" || string(replayed.Response.Header.Peek(replayedHeader)) != "true" || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("expected replayed response but got %q after %d calls", replayed.Response.Body(), atomic.LoadInt32(&calls))
+	}
+}
@@ -0,0 +1,291 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"rest/models"
+	"rest/myerrors"
+	"rest/viewmodels"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	csvType    = "text/csv"
+	ndjsonType = "application/x-ndjson"
+	// exportFlush is number of exported users written between flushes to client
+	exportFlush = 100
+)
+
+// csvColumns are written by export, import requires first two and ignores the ones set by the store
+var csvColumns = []string{"id", "first_name", "last_name", "email", "iin", "created_at", "updated_at"}
+
+// importRow is a user read from import body, err is set if row cannot be imported
+type importRow struct {
+	user models.User
+	err  error
+}
+
+// WithStatic routes requests whose :id equals name to static and the rest to h.
+// fasthttprouter does not allow static segment next to wildcard, e.g. /rest/user/export and /rest/user/:id.
+// Nil h answers 405 like the router does for unknown methods.
+func WithStatic(name string, static, h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if ID, _ := ctx.UserValue("id").(string); ID == name {
+			static(ctx)
+			return
+		}
+		if h == nil {
+			ctx.Error(fasthttp.StatusMessage(fasthttp.StatusMethodNotAllowed), fasthttp.StatusMethodNotAllowed)
+			return
+		}
+		h(ctx)
+	}
+}
+
+// ImportUsers handles POST /rest/user/import creating users from CSV or NDJSON body.
+// CSV needs header with first_name and last_name, NDJSON has user object on every line.
+// Valid rows are saved in transactions of ImportChunk rows, rows that fail are reported by their number.
+func (s *MyServer) ImportUsers(ctx *fasthttp.RequestCtx) {
+	mediaType, _, _ := mime.ParseMediaType(string(ctx.Request.Header.ContentType()))
+	var (
+		rows []importRow
+		err  error
+	)
+	switch mediaType {
+	case csvType:
+		rows, err = readCSV(ctx.Request.Body(), s.users.MaxImport)
+	case ndjsonType, "application/ndjson":
+		rows, err = readNDJSON(ctx.Request.Body(), s.users.MaxImport)
+	default:
+		log.Println("ImportUsers: unsupported content type", mediaType)
+		viewmodels.Error(ctx, myerrors.ErrUnsupportedMedia.WithDetail("use text/csv or application/x-ndjson"))
+		return
+	}
+	if err != nil {
+		log.Println("ImportUsers err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	if len(rows) == 0 {
+		log.Println("ImportUsers: no rows")
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail("no users to import"))
+		return
+	}
+	for i := range rows {
+		if rows[i].err == nil {
+			rows[i].err = s.validateUser(&rows[i].user)
+		}
+	}
+	s.saveRows(rows)
+
+	res := viewmodels.Import{Errors: []viewmodels.ImportError{}}
+	text := ""
+	for i, r := range rows {
+		if r.err == nil {
+			res.Imported++
+			continue
+		}
+		e := myerrors.As(r.err)
+		res.Failed++
+		res.Errors = append(res.Errors, viewmodels.ImportError{Row: i + 1, Code: e.Code, Message: e.Message})
+		text += fmt.Sprintf("row %d: %s\n", i+1, e.Message)
+	}
+	viewmodels.Result(ctx, res, fmt.Sprintf("%s Imported %d users, %d failed\n%s", successMsg, res.Imported, res.Failed, text))
+}
+
+// saveRows saves valid rows chunk by chunk
+// Row rejected by the store is marked and the rest of its chunk is saved again
+func (s *MyServer) saveRows(rows []importRow) {
+	for start := 0; start < len(rows); start += s.users.ImportChunk {
+		end := start + s.users.ImportChunk
+		if end > len(rows) {
+			end = len(rows)
+		}
+		for {
+			var (
+				idx   []int
+				users []models.User
+			)
+			for i := start; i < end; i++ {
+				if rows[i].err == nil {
+					idx, users = append(idx, i), append(users, rows[i].user)
+				}
+			}
+			if len(users) == 0 {
+				break
+			}
+			err := s.db.CreateUsers(users)
+			var ue *models.UserError
+			if errors.As(err, &ue) && ue.Index >= 0 && ue.Index < len(idx) {
+				rows[idx[ue.Index]].err = ue.Err
+				continue
+			}
+			if err != nil {
+				log.Println("ImportUsers err:", err)
+				for _, i := range idx {
+					rows[i].err = err
+				}
+			}
+			break
+		}
+	}
+}
+
+// readCSV parses CSV body with header into rows
+func readCSV(body []byte, max int) ([]importRow, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, myerrors.ErrInvalidInput.WithDetail("malformed csv header")
+	}
+	cols := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "first_name", "last_name", "email", "iin":
+			cols[name] = i
+		case "id", "created_at", "updated_at":
+		default:
+			return nil, myerrors.ErrInvalidInput.WithDetail(fmt.Sprintf("unknown csv column %q", name))
+		}
+	}
+	if _, ok := cols["first_name"]; !ok {
+		return nil, myerrors.ErrInvalidInput.WithDetail("csv header must contain first_name and last_name")
+	}
+	if _, ok := cols["last_name"]; !ok {
+		return nil, myerrors.ErrInvalidInput.WithDetail("csv header must contain first_name and last_name")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	var rows []importRow
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if len(rows) == max {
+			return nil, myerrors.ErrInvalidInput.WithDetail(fmt.Sprintf("import must contain at most %d users", max))
+		}
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			rows = append(rows, importRow{err: myerrors.ErrInvalidInput.WithDetail("malformed csv row")})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, importRow{user: models.User{
+			FirstName: field(record, "first_name"),
+			LastName:  field(record, "last_name"),
+			Email:     field(record, "email"),
+			IIN:       field(record, "iin"),
+		}})
+	}
+}
+
+// readNDJSON parses body with JSON user on every line into rows, blank lines are skipped
+func readNDJSON(body []byte, max int) ([]importRow, error) {
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	sc.Buffer(make([]byte, 0, 4096), len(body)+1)
+	var rows []importRow
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(rows) == max {
+			return nil, myerrors.ErrInvalidInput.WithDetail(fmt.Sprintf("import must contain at most %d users", max))
+		}
+		var in models.User
+		if err := json.Unmarshal(line, &in); err != nil {
+			rows = append(rows, importRow{err: myerrors.ErrInvalidInput.WithDetail("malformed json")})
+			continue
+		}
+		// only fields accepted by CreateUser are taken
+		rows = append(rows, importRow{user: models.User{FirstName: in.FirstName, LastName: in.LastName, Email: in.Email, IIN: in.IIN}})
+	}
+	return rows, sc.Err()
+}
+
+// ExportUsers handles GET /rest/user/export streaming live users ordered by ID
+// format is either csv (default) or ndjson
+func (s *MyServer) ExportUsers(ctx *fasthttp.RequestCtx) {
+	format := string(ctx.QueryArgs().Peek("format"))
+	switch format {
+	case "", "csv":
+		format = "csv"
+		ctx.SetContentType(csvType + "; charset=utf-8")
+	case "ndjson":
+		ctx.SetContentType(ndjsonType)
+	default:
+		log.Println("ExportUsers: invalid format", format)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail("format must be csv or ndjson"))
+		return
+	}
+	ctx.Response.Header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="users.%s"`, format))
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		var (
+			write func(u models.User) error
+			flush = w.Flush
+		)
+		if format == "csv" {
+			cw := csv.NewWriter(w)
+			write = func(u models.User) error {
+				return cw.Write([]string{strconv.FormatInt(u.ID, 10), u.FirstName, u.LastName, u.Email, u.IIN,
+					u.CreatedAt.Format(time.RFC3339Nano), u.UpdatedAt.Format(time.RFC3339Nano)})
+			}
+			flush = func() error {
+				cw.Flush()
+				if err := cw.Error(); err != nil {
+					return err
+				}
+				return w.Flush()
+			}
+			if err := cw.Write(csvColumns); err != nil {
+				log.Println("ExportUsers err:", err)
+				return
+			}
+		} else {
+			enc := json.NewEncoder(w)
+			write = func(u models.User) error {
+				return enc.Encode(u)
+			}
+		}
+		n := 0
+		err := s.db.EachUser(func(u models.User) error {
+			if err := write(u); err != nil {
+				return err
+			}
+			if n++; n%exportFlush == 0 {
+				return flush()
+			}
+			return nil
+		})
+		if err == nil {
+			err = flush()
+		}
+		if err != nil {
+			// status is already sent, client sees truncated body
+			log.Println("ExportUsers err:", err)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"rest/models"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+var importUsersTests = []struct {
+	number             int
+	contentType        string
+	body               string
+	expectedOutput     string
+	expectedStatusCode int
+}{
+	{0, "text/csv", "first_name,last_name,email,iin\nAnn,Lee,ann@mail.kz,980124450084\nBob,Smith,,\n", "Success! Imported 2 users, 0 failed\n", fasthttp.StatusOK},
+	{1, "text/csv; charset=utf-8", "first_name, last_name, iin\nDan,Ray,980124450084\nEve,Ray,\nFay,Ray1,\nGus,Ray,,extra\n",
+		"Success! Imported 1 users, 3 failed\nrow 1: user with this IIN already exists\nrow 3: invalid input, last_name cannot contain '1'\nrow 4: invalid input, malformed csv row\n", fasthttp.StatusOK},
+	{2, "application/x-ndjson", "{\"first_name\": \"Hal\", \"last_name\": \"Ng\", \"id\": 1}\n\nnot json\n{\"first_name\": \"Ida\", \"last_name\": \"Ng\", \"email\": \"bad\"}\n",
+		"Success! Imported 1 users, 2 failed\nrow 2: invalid input, malformed json\nrow 3: invalid input, invalid email\n", fasthttp.StatusOK},
+	{3, "application/json", "[]", "unsupported content type, use text/csv or application/x-ndjson", fasthttp.StatusUnsupportedMediaType},
+	{4, "text/csv", "first_name,last_name,age\nAnn,Lee,20\n", `invalid input, unknown csv column "age"`, fasthttp.StatusBadRequest},
+	{5, "text/csv", "first_name,email\nAnn,ann@mail.kz\n", "invalid input, csv header must contain first_name and last_name", fasthttp.StatusBadRequest},
+	{6, "text/csv", "first_name,last_name\nA,B\nA,B\nA,B\nA,B\nA,B\n", "invalid input, import must contain at most 4 users", fasthttp.StatusBadRequest},
+	{7, "application/ndjson", "\n", "invalid input, no users to import", fasthttp.StatusBadRequest},
+	{8, "text/csv", "first_name,last_name\n", "invalid input, no users to import", fasthttp.StatusBadRequest},
+}
+
+// TestImportUsers tests that valid rows are saved and the rest are reported
+func TestImportUsers(t *testing.T) {
+	db := newTestDB()
+	cfg := testConfig()
+	cfg.Users.MaxImport, cfg.Users.ImportChunk = 4, 2
+	server := NewMyServer(db, &testRedis{}, newTestJobs(), nil, nil, cfg)
+	c, stop := listen(server)
+	defer stop()
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
+	req.SetRequestURI("http://test.com/rest/user/import")
+	for _, testCase := range importUsersTests {
+		req.Header.SetContentType(testCase.contentType)
+		req.SetBodyString(testCase.body)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode() != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, res.StatusCode())
+		}
+		if body, exp := string(res.Body()), testCase.expectedOutput; body != exp {
+			t.Errorf("for test #%d, expected %q but got %q", testCase.number, exp, body)
+		}
+	}
+
+	users, _, _ := db.ListUsers(models.UserFilter{Limit: 10})
+	var names []string
+	for _, u := range users {
+		names = append(names, u.FirstName)
+	}
+	if got, exp := fmt.Sprint(names), "[Ann Bob Eve Hal]"; got != exp {
+		t.Errorf("expected imported users %s but got %s", exp, got)
+	}
+}
+
+// TestExportUsers tests that every live user is exported in requested format
+func TestExportUsers(t *testing.T) {
+	db := newTestDB(testUsers...)
+	db.UpdateUser("1", models.User{FirstName: "Ann", LastName: "Lee", Email: "ann@mail.kz", IIN: "980124450084"})
+	db.DeleteUser("5")
+	server := NewMyServer(db, &testRedis{}, newTestJobs(), nil, nil, testConfig())
+	c, stop := listen(server)
+	defer stop()
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+
+	req.SetRequestURI("http://test.com/rest/user/export")
+	if err := c.Do(req, res); err != nil {
+		t.Fatal(err)
+	}
+	live, _, _ := db.ListUsers(models.UserFilter{Limit: 10})
+	exp := "id,first_name,last_name,email,iin,created_at,updated_at\n"
+	for _, u := range live {
+		exp += fmt.Sprintf("%d,%s,%s,%s,%s,%s,%s\n", u.ID, u.FirstName, u.LastName, u.Email, u.IIN,
+			u.CreatedAt.Format(time.RFC3339Nano), u.UpdatedAt.Format(time.RFC3339Nano))
+	}
+	if body := string(res.Body()); body != exp {
+		t.Errorf("expected csv %q but got %q", exp, body)
+	}
+	if ct := string(res.Header.ContentType()); ct != "text/csv; charset=utf-8" {
+		t.Errorf("expected csv content type but got %q", ct)
+	}
+
+	req.SetRequestURI("http://test.com/rest/user/export?format=ndjson")
+	if err := c.Do(req, res); err != nil {
+		t.Fatal(err)
+	}
+	sc := bufio.NewScanner(bytes.NewReader(res.Body()))
+	var ids []int64
+	for sc.Scan() {
+		var u models.User
+		if err := json.Unmarshal(sc.Bytes(), &u); err != nil {
+			t.Fatalf("unexpected line %q", sc.Text())
+		}
+		ids = append(ids, u.ID)
+	}
+	if got := fmt.Sprint(ids); got != "[1 2 3 4]" {
+		t.Errorf("expected users [1 2 3 4] but got %s", got)
+	}
+
+	req.SetRequestURI("http://test.com/rest/user/export?format=xml")
+	if err := c.Do(req, res); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode() != fasthttp.StatusBadRequest {
+		t.Errorf("expected %d for unknown format but got %d", fasthttp.StatusBadRequest, res.StatusCode())
+	}
+
+	// routes sharing path with :id keep working
+	req.SetRequestURI("http://test.com/rest/user/2")
+	if err := c.Do(req, res); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode() != fasthttp.StatusOK {
+		t.Errorf("expected %d for user but got %d", fasthttp.StatusOK, res.StatusCode())
+	}
+	req.Header.SetMethod(fasthttp.MethodPost)
+	if err := c.Do(req, res); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode() != fasthttp.StatusMethodNotAllowed {
+		t.Errorf("expected %d for POST to user but got %d", fasthttp.StatusMethodNotAllowed, res.StatusCode())
+	}
+}
@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"rest/myerrors"
+	"rest/viewmodels"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// States of worker reported by GET /rest/admin/workers
+const (
+	workerIdle     = "idle"
+	workerBusy     = "busy"
+	workerStopping = "stopping"
+)
+
+const (
+	// popRetry is the first pause of worker after queue fails, it doubles on every failure in a row
+	popRetry = time.Millisecond * 100
+	// maxPopRetry caps pause of worker while queue keeps failing
+	maxPopRetry = time.Second * 5
+)
+
+// worker takes jobs from the queue one at a time
+type worker struct {
+	ID int
+	// stop is called to retire worker once its current job is finished
+	stop      context.CancelFunc
+	state     string
+	jobID     string
+	since     time.Time
+	processed int
+}
+
+// pool keeps workers of this instance, its size can be changed at runtime
+type pool struct {
+	mx      sync.Mutex
+	workers map[int]*worker
+	nextID  int
+	size    int
+	// exited is done once every started worker has returned
+	exited sync.WaitGroup
+}
+
+// poolRequest is the body of PUT /rest/admin/workers
+type poolRequest struct {
+	Size int `json:"size"`
+}
+
+// GetWorkers handles GET /rest/admin/workers reporting state of every worker
+func (s *MyServer) GetWorkers(ctx *fasthttp.RequestCtx) {
+	viewmodels.JSON(ctx, s.poolState())
+}
+
+// ResizeWorkers handles PUT /rest/admin/workers setting number of workers.
+// Retired workers finish their current job before they exit.
+func (s *MyServer) ResizeWorkers(ctx *fasthttp.RequestCtx) {
+	var req poolRequest
+	if err := json.Unmarshal(ctx.Request.Body(), &req); err != nil {
+		log.Println("ResizeWorkers err:", err)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput)
+		return
+	}
+	if max := s.workers.cfg.MaxCount; req.Size < 1 || req.Size > max {
+		log.Println("ResizeWorkers: invalid size", req.Size)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail(fmt.Sprintf("size must be between 1 and %d", max)))
+		return
+	}
+	if err := s.resize(req.Size); err != nil {
+		log.Println("ResizeWorkers err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	log.Printf("INFO|Resized worker pool to %d", req.Size)
+	viewmodels.Result(ctx, s.poolState(), fmt.Sprintf("%s Resized worker pool to %d", successMsg, req.Size))
+}
+
+// DispatchWorkers starts workers of configured number and waits for them
+// It returns once Shutdown stops every worker or the job queue is closed
+func (s *MyServer) DispatchWorkers() {
+	defer close(s.workers.done)
+	if err := s.resize(s.workers.cfg.Count); err != nil {
+		log.Println("DispatchWorkers err:", err)
+		return
+	}
+	s.pool.exited.Wait()
+}
+
+// resize starts or retires workers so that n of them are active
+// Idle workers are retired first, then the most recently started ones
+func (s *MyServer) resize(n int) error {
+	// closing cannot change while workers are started
+	s.qmx.RLock()
+	defer s.qmx.RUnlock()
+	if s.closing {
+		return myerrors.ErrShuttingDown
+	}
+	p := s.pool
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	for ; p.size < n; p.size++ {
+		ctx, stop := context.WithCancel(s.workers.dispatch)
+		p.nextID++
+		w := &worker{ID: p.nextID, stop: stop, state: workerIdle, since: s.workers.clock.Now().UTC()}
+		p.workers[w.ID] = w
+		p.exited.Add(1)
+		go s.work(ctx, w)
+	}
+	if p.size > n {
+		active := make([]*worker, 0, p.size)
+		for _, w := range p.workers {
+			if w.state != workerStopping {
+				active = append(active, w)
+			}
+		}
+		sort.Slice(active, func(i, k int) bool {
+			if (active[i].state == workerIdle) != (active[k].state == workerIdle) {
+				return active[i].state == workerIdle
+			}
+			return active[i].ID > active[k].ID
+		})
+		for _, w := range active[:p.size-n] {
+			w.state = workerStopping
+			w.stop()
+		}
+		p.size = n
+	}
+	return nil
+}
+
+// work runs jobs from the queue until worker is retired, server shuts down or queue is closed
+func (s *MyServer) work(ctx context.Context, w *worker) {
+	defer s.pool.exit(w)
+	var retry time.Duration
+	for ctx.Err() == nil {
+		qj, err := s.queue.Pop(ctx)
+		if err != nil {
+			if errors.Is(err, myerrors.ErrQueueClosed) || ctx.Err() != nil {
+				return
+			}
+			// broker or store may be down for a while, workers should not spin on it
+			retry = nextPopRetry(retry)
+			log.Println("work err:", err, "retrying in", retry)
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.workers.clock.After(retry):
+			}
+			continue
+		}
+		retry = 0
+		// job may be popped together with the shutdown signal
+		if s.isClosing() {
+			s.dropJob(qj)
+			return
+		}
+		s.pool.update(w, workerBusy, qj.ID, s.workers.clock.Now().UTC())
+		s.runJob(qj)
+		s.pool.update(w, workerIdle, "", s.workers.clock.Now().UTC())
+	}
+}
+
+// nextPopRetry returns pause following retry, zero retry means queue has just failed
+func nextPopRetry(retry time.Duration) time.Duration {
+	if retry == 0 {
+		return popRetry
+	}
+	if retry *= 2; retry > maxPopRetry {
+		return maxPopRetry
+	}
+	return retry
+}
+
+// update records state of worker, retired worker stays stopping
+func (p *pool) update(w *worker, state, jobID string, now time.Time) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if state == workerIdle {
+		w.processed++
+	}
+	if w.state == workerStopping {
+		state = workerStopping
+	}
+	w.state, w.jobID, w.since = state, jobID, now
+}
+
+// exit removes worker from the pool
+func (p *pool) exit(w *worker) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	w.stop()
+	if w.state != workerStopping {
+		// worker stopped by shutdown or closed queue rather than resize
+		p.size--
+	}
+	delete(p.workers, w.ID)
+	p.exited.Done()
+}
+
+// poolState returns snapshot of workers ordered by ID
+func (s *MyServer) poolState() viewmodels.Workers {
+	p := s.pool
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	state := viewmodels.Workers{Size: p.size, Max: s.workers.cfg.MaxCount, Workers: []viewmodels.Worker{}}
+	for _, w := range p.workers {
+		if w.state == workerBusy {
+			state.Busy++
+		}
+		state.Workers = append(state.Workers, viewmodels.Worker{
+			ID:        w.ID,
+			State:     w.state,
+			JobID:     w.jobID,
+			Since:     w.since,
+			Processed: w.processed,
+		})
+	}
+	sort.Slice(state.Workers, func(i, k int) bool {
+		return state.Workers[i].ID < state.Workers[k].ID
+	})
+	return state
+}
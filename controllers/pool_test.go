@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"reflect"
+	"rest/models"
+	"rest/myerrors"
+	"rest/viewmodels"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// poolSize waits until pool of server has n workers and returns its state
+func poolSize(t *testing.T, s *MyServer, n int) viewmodels.Workers {
+	var state viewmodels.Workers
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		if state = s.poolState(); len(state.Workers) == n {
+			return state
+		}
+	}
+	t.Fatalf("expected %d workers but got %+v", n, state)
+	return state
+}
+
+var resizeWorkersTests = []struct {
+	number             int
+	body               string
+	expectedOutput     string
+	expectedStatusCode int
+}{
+	{0, `{"size": 0}`, "invalid input, size must be between 1 and 4", fasthttp.StatusBadRequest},
+	{1, `{"size": 5}`, "invalid input, size must be between 1 and 4", fasthttp.StatusBadRequest},
+	{2, `three`, "invalid input", fasthttp.StatusBadRequest},
+	{3, `{"size": 1}`, "Success! Resized worker pool to 1", fasthttp.StatusOK},
+}
+
+// TestWorkerPool tests that pool is resized at runtime and busy workers finish their job before they exit
+func TestWorkerPool(t *testing.T) {
+	jobs := newTestJobs()
+	cfg := testConfig()
+	cfg.Workers.Count, cfg.Workers.MaxCount = 2, 4
+	server := newTestServer(jobs, cfg)
+	go server.DispatchWorkers()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	poolSize(t, server, 2)
+	for _, ID := range []string{"a", "b"} {
+		jobs.CreateJob(&models.Job{ID: ID, Input: "1", Status: models.JobQueued})
+		if err := server.enqueue(context.Background(), ID); err != nil {
+			t.Fatal(err)
+		}
+		waitStatus(t, jobs, ID, models.JobRunning)
+	}
+
+	r := NewRouter(server)
+	ln := fasthttputil.NewInmemoryListener()
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	s := &fasthttp.Server{
+		Handler: r.Handler,
+	}
+	go s.Serve(ln) //nolint:errcheck
+	c := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	req.Header.SetMethod(fasthttp.MethodPut)
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
+	req.SetRequestURI("http://test.com/rest/admin/workers")
+	for _, testCase := range resizeWorkersTests {
+		req.SetBodyString(testCase.body)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode() != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, res.StatusCode())
+		}
+		if body := string(res.Body()); body != testCase.expectedOutput {
+			t.Errorf("for test #%d, expected %q but got %q", testCase.number, testCase.expectedOutput, body)
+		}
+	}
+
+	// retired worker keeps running its job
+	state := poolSize(t, server, 2)
+	if state.Size != 1 || state.Max != 4 || state.Busy != 1 {
+		t.Errorf("expected size 1 of 4 with one busy worker but got %+v", state)
+	}
+	stopping := state.Workers[0]
+	if stopping.State != workerStopping {
+		stopping = state.Workers[1]
+	}
+	if stopping.State != workerStopping || stopping.JobID == "" {
+		t.Fatalf("expected stopping worker with job but got %+v", state.Workers)
+	}
+
+	req.Reset()
+	req.SetRequestURI("http://test.com/rest/admin/workers")
+	if err := c.Do(req, res); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Data viewmodels.Workers `json:"data"`
+	}
+	if err := json.Unmarshal(res.Body(), &got); err != nil {
+		t.Fatalf("unexpected body %q", res.Body())
+	}
+	if got.Data.Size != 1 || len(got.Data.Workers) != 2 {
+		t.Errorf("expected size 1 with 2 workers but got %+v", got.Data)
+	}
+
+	if _, err := server.cancelJob(stopping.JobID); err != nil {
+		t.Fatal(err)
+	}
+	if state := poolSize(t, server, 1); state.Workers[0].State != workerBusy {
+		t.Errorf("expected busy worker but got %+v", state.Workers[0])
+	}
+	if err := server.resize(3); err != nil {
+		t.Fatal(err)
+	}
+	if state := poolSize(t, server, 3); state.Size != 3 || state.Busy != 1 {
+		t.Errorf("expected size 3 with one busy worker but got %+v", state)
+	}
+}
+
+// TestGenerateHashQueueFull tests that hash request is rejected once queue is full
+func TestGenerateHashQueueFull(t *testing.T) {
+	jobs := newTestJobs()
+	cfg := testConfig()
+	cfg.Workers.QueueSize = 1
+	server := newTestServer(jobs, cfg)
+	r := NewRouter(server)
+	ln := fasthttputil.NewInmemoryListener()
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	s := &fasthttp.Server{
+		Handler: r.Handler,
+	}
+	go s.Serve(ln) //nolint:errcheck
+	c := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
+	req.SetRequestURI("http://test.com/rest/hash/calc")
+	req.SetBodyString(`"15"`)
+	for i, expected := range []int{fasthttp.StatusOK, fasthttp.StatusTooManyRequests} {
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode() != expected {
+			t.Errorf("for request #%d, expected %d but got %d", i, expected, res.StatusCode())
+		}
+	}
+	if retry := string(res.Header.Peek(fasthttp.HeaderRetryAfter)); retry != "1" {
+		t.Errorf("expected Retry-After %q but got %q", "1", retry)
+	}
+	// rejected job is not left stored
+	if list, _, _ := jobs.ListJobs(models.JobFilter{Limit: 10}); len(list) != 1 || list[0].Status != models.JobQueued {
+		t.Errorf("expected single queued job but got %+v", list)
+	}
+	if queued(server) != 1 {
+		t.Errorf("expected %d job in queue but got %d", 1, queued(server))
+	}
+}
+
+// failingQueue is JobQueue whose Pop fails a number of times before it reports closed queue and Push fails with pushErr
+type failingQueue struct {
+	mx       sync.Mutex
+	failures int
+	pushErr  error
+}
+
+func (q *failingQueue) Push(ctx context.Context, IDs ...string) error {
+	return q.pushErr
+}
+
+func (q *failingQueue) Pop(ctx context.Context) (models.QueuedJob, error) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	if q.failures == 0 {
+		return models.QueuedJob{}, myerrors.ErrQueueClosed
+	}
+	if q.failures > 0 {
+		q.failures--
+	}
+	return models.QueuedJob{}, errors.New("broker is down")
+}
+
+func (q *failingQueue) Close() error {
+	return nil
+}
+
+// TestWorkerPopRetry tests that worker backs off exponentially while queue fails
+func TestWorkerPopRetry(t *testing.T) {
+	cfg := testConfig()
+	cfg.Workers.Count = 1
+	server := NewMyServer(newTestDB(), &testRedis{}, newTestJobs(), &failingQueue{failures: 7}, nil, cfg)
+	clock := &testClock{now: []time.Time{time.Now()}}
+	server.workers.clock = clock
+	server.DispatchWorkers()
+	expected := []time.Duration{popRetry, popRetry * 2, popRetry * 4, popRetry * 8, popRetry * 16, popRetry * 32, maxPopRetry}
+	if !reflect.DeepEqual(clock.after, expected) {
+		t.Errorf("expected pauses %v but got %v", expected, clock.after)
+	}
+}
+
+// stoppedClock is testClock whose timers never fire
+type stoppedClock struct {
+	testClock
+}
+
+func (c *stoppedClock) After(d time.Duration) <-chan time.Time {
+	return nil
+}
+
+// TestWorkerPopRetryShutdown tests that worker pausing after failed Pop exits on shutdown
+func TestWorkerPopRetryShutdown(t *testing.T) {
+	cfg := testConfig()
+	cfg.Workers.Count = 2
+	server := NewMyServer(newTestDB(), &testRedis{}, newTestJobs(), &failingQueue{failures: -1}, nil, cfg)
+	server.workers.clock = &stoppedClock{testClock{now: []time.Time{time.Now(), time.Now()}}}
+	go server.DispatchWorkers()
+	poolSize(t, server, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("expected workers to exit but got %v", err)
+	}
+}
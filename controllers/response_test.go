@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"net"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+var envelopeTests = []struct {
+	number             int
+	uri                string
+	body               string
+	expectedOutput     string
+	expectedStatusCode int
+	method             string
+}{
+	{0, "/rest/counter/val", "", `{"data":{"counter":0}}`, fasthttp.StatusOK, fasthttp.MethodGet},
+	{1, "/rest/counter/add/7", "", `{"data":{"counter":7}}`, fasthttp.StatusOK, fasthttp.MethodPost},
+	{2, "/rest/counter/add/2", "", `{"error":{"code":"internal_error","message":"Something went wrong. Please try again later."}}`, fasthttp.StatusInternalServerError, fasthttp.MethodPost},
+	{3, "/rest/counter/sub/1234567", "", `{"error":{"code":"negative_counter","message":"input exceeds counter: counter cannot be negative"}}`, fasthttp.StatusBadRequest, fasthttp.MethodPost},
+	{4, "/rest/counter/sub/lejew", "", `{"error":{"code":"invalid_input","message":"invalid input"}}`, fasthttp.StatusBadRequest, fasthttp.MethodPost},
+	{5, "/rest/substr/find", `"pwwke"`, `{"data":{"substring":"wke"}}`, fasthttp.StatusOK, fasthttp.MethodPost},
+	{6, "/rest/email/check", `"Email:__valid@sss.com Email:__other@sss.com"`, `{"data":{"emails":["valid@sss.com","other@sss.com"]}}`, fasthttp.StatusOK, fasthttp.MethodPost},
+	{7, "/rest/iin/check", `"IIN:__980124450084"`, `{"data":{"iins":["980124450084"]}}`, fasthttp.StatusOK, fasthttp.MethodPost},
+	{8, "/rest/substr", "", `{"data":"To get the longest substring, follow the /find endpoint."}`, fasthttp.StatusOK, fasthttp.MethodGet},
+}
+
+// TestEnvelope tests that responses are wrapped in JSON envelope by default
+func TestEnvelope(t *testing.T) {
+	r := NewRouter(
+		&MyServer{
+			db:        newTestDB(),
+			redisConn: &testRedis{},
+		},
+	)
+	ln := fasthttputil.NewInmemoryListener()
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	s := &fasthttp.Server{
+		Handler: r.Handler,
+	}
+	go s.Serve(ln) //nolint:errcheck
+	c := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	for _, testCase := range envelopeTests {
+		req.Reset()
+		req.Header.SetMethod(testCase.method)
+		req.SetRequestURI("http://test.com" + testCase.uri)
+		req.SetBody([]byte(testCase.body))
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode() != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, res.StatusCode())
+		}
+		if ct := string(res.Header.ContentType()); ct != "application/json" {
+			t.Errorf("for test #%d, expected content type %q but got %q", testCase.number, "application/json", ct)
+		}
+		if body, exp := string(res.Body()), testCase.expectedOutput+"\n"; body != exp {
+			t.Errorf("for test #%d, expected %q but got %q", testCase.number, exp, body)
+		}
+	}
+}
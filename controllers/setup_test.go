@@ -3,72 +3,262 @@ package controllers
 import (
 	"fmt"
 	"rest/models"
+	"rest/models/memory"
 	"rest/myerrors"
-	"strconv"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/buaazp/fasthttprouter"
 )
 
-type testDB struct{}
-
-func (db *testDB) CreateUser(u *models.User) (int64, error) {
-	fmt.Println("ass")
-	return 0, nil
-}
-
-func (db *testDB) GetUser(ID string) (*models.User, error) {
-	fmt.Println("asas")
-	return nil, nil
-}
-
-func (db *testDB) UpdateUser(ID string, u models.User) error {
-	return nil
+// newTestDB returns in-memory store holding users
+func newTestDB(users ...models.User) *memory.Users {
+	return memory.NewUsers(users...)
 }
 
-func (db *testDB) DeleteUser(ID string) error {
-	return nil
+// testRedis keeps values in memory ignoring their ttl, counter is stubbed
+type testRedis struct {
+	mx   sync.Mutex
+	vals map[string]string
 }
 
-type testRedis struct{}
-
-func (r *testRedis) GetCounter() (string, error) {
-	return "0", nil
+func (r *testRedis) GetCounter(name string) (*models.Counter, error) {
+	return &models.Counter{Name: name}, nil
 }
 
-func (r *testRedis) SetCounter(n int) (string, error) {
+func (r *testRedis) SetCounter(name string, n int) (*models.Counter, error) {
+	counter := func(v int64) *models.Counter {
+		return &models.Counter{Name: name, Value: v}
+	}
 	// testing add
 	if n == 0 {
-		return "0", nil
+		return counter(0), nil
 	}
 	if n == 1 {
-		return "1", nil
+		return counter(1), nil
 	}
 	if n == -1 {
-		return "0", nil
+		return counter(0), nil
 	}
 	if n == 2 {
-		return "0", fmt.Errorf("some error")
+		return nil, fmt.Errorf("some error")
 	}
 	// testing sub
 	if n == -3 {
-		return "2", nil
+		return counter(2), nil
 	}
 
 	if n == -1234567 {
-		return "0", myerrors.ErrNegativeCounter
+		return nil, myerrors.ErrNegativeCounter
 	}
 	if n < 0 {
-		return "0", fmt.Errorf("some error")
+		return nil, fmt.Errorf("some error")
 	}
-	return strconv.Itoa(n), nil
+	return counter(int64(n)), nil
 }
 
-func (r *testRedis) Set(key string, val interface{}) error {
+func (r *testRedis) SetCounterBounds(c models.Counter) (*models.Counter, error) {
+	return &c, nil
+}
+
+func (r *testRedis) DelCounter(name string) error {
 	return nil
 }
 
+func (r *testRedis) ListCounters() ([]models.Counter, error) {
+	return []models.Counter{}, nil
+}
+
+func (r *testRedis) Set(key string, val interface{}) error {
+	return r.SetEX(key, val, 0)
+}
+
 func (r *testRedis) Get(key string) (string, error) {
-	return "", nil
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	val, ok := r.vals[key]
+	if !ok {
+		return "", myerrors.ErrNotFound
+	}
+	return val, nil
+}
+
+func (r *testRedis) SetNX(key string, val interface{}, ttl time.Duration) (bool, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if _, ok := r.vals[key]; ok {
+		return false, nil
+	}
+	if r.vals == nil {
+		r.vals = make(map[string]string)
+	}
+	r.vals[key] = fmt.Sprint(val)
+	return true, nil
+}
+
+func (r *testRedis) SetEX(key string, val interface{}, ttl time.Duration) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.vals == nil {
+		r.vals = make(map[string]string)
+	}
+	r.vals[key] = fmt.Sprint(val)
+	return nil
+}
+
+func (r *testRedis) Del(key string) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	delete(r.vals, key)
+	return nil
+}
+
+func (r *testRedis) Close() error {
+	return nil
+}
+
+// testJobs keeps jobs in memory
+type testJobs struct {
+	mx         sync.Mutex
+	jobs       map[string]models.Job
+	deliveries map[string][]models.Delivery
+	batches    map[string]models.Batch
+}
+
+func newTestJobs(jobs ...models.Job) *testJobs {
+	t := &testJobs{jobs: make(map[string]models.Job), deliveries: make(map[string][]models.Delivery), batches: make(map[string]models.Batch)}
+	for _, j := range jobs {
+		t.jobs[j.ID] = j
+	}
+	return t
+}
+
+func (t *testJobs) CreateJob(j *models.Job) error {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.jobs[j.ID] = *j
+	return nil
+}
+
+func (t *testJobs) UpdateJob(j *models.Job) error {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if _, ok := t.jobs[j.ID]; !ok {
+		return myerrors.ErrJobNotFound
+	}
+	t.jobs[j.ID] = *j
+	return nil
+}
+
+func (t *testJobs) GetJob(ID string) (*models.Job, error) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	j, ok := t.jobs[ID]
+	if !ok {
+		return nil, myerrors.ErrJobNotFound
+	}
+	return &j, nil
+}
+
+func (t *testJobs) DeleteJob(ID string) error {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	delete(t.jobs, ID)
+	delete(t.deliveries, ID)
+	return nil
+}
+
+func (t *testJobs) ListJobs(f models.JobFilter) ([]models.Job, int, error) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	jobs := []models.Job{}
+	for _, j := range t.jobs {
+		if f.Status == "" || j.Status == f.Status {
+			jobs = append(jobs, j)
+		}
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].CreatedAt.Before(jobs[k].CreatedAt)
+	})
+	total := len(jobs)
+	if f.Offset > total {
+		f.Offset = total
+	}
+	end := f.Offset + f.Limit
+	if end > total {
+		end = total
+	}
+	return jobs[f.Offset:end], total, nil
+}
+
+func (t *testJobs) UnfinishedJobs() ([]models.Job, error) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	jobs := []models.Job{}
+	for _, j := range t.jobs {
+		if !j.Status.Finished() {
+			jobs = append(jobs, j)
+		}
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].CreatedAt.Before(jobs[k].CreatedAt)
+	})
+	return jobs, nil
+}
+
+func (t *testJobs) AddDelivery(d *models.Delivery) error {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.deliveries[d.JobID] = append(t.deliveries[d.JobID], *d)
+	return nil
+}
+
+func (t *testJobs) ListDeliveries(jobID string) ([]models.Delivery, error) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return append([]models.Delivery{}, t.deliveries[jobID]...), nil
+}
+
+func (t *testJobs) CreateBatch(b *models.Batch, jobs []models.Job) error {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.batches[b.ID] = *b
+	for _, j := range jobs {
+		t.jobs[j.ID] = j
+	}
+	return nil
+}
+
+func (t *testJobs) GetBatch(ID string) (*models.Batch, []models.Job, error) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	b, ok := t.batches[ID]
+	if !ok {
+		return nil, nil, myerrors.ErrBatchNotFound
+	}
+	jobs := []models.Job{}
+	for _, ID := range b.JobIDs {
+		if j, ok := t.jobs[ID]; ok {
+			jobs = append(jobs, j)
+		}
+	}
+	return &b, jobs, nil
+}
+
+func (t *testJobs) DeleteBatch(b *models.Batch) error {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	delete(t.batches, b.ID)
+	for _, ID := range b.JobIDs {
+		delete(t.jobs, ID)
+		delete(t.deliveries, ID)
+	}
+	return nil
+}
+
+func (t *testJobs) Close() error {
+	return nil
 }
 
 // NewRouter returns fasthttprouter.Router for supported routes
@@ -79,16 +269,32 @@ func NewRouter(server *MyServer) *fasthttprouter.Router {
 	r.GET("/rest/email", server.EmailHandler)
 	r.POST("/rest/email/check", server.GetEmail)
 	r.POST("/rest/iin/check", server.GetIIN)
-	r.POST("/rest/counter/add/:add", server.AddCounter)
-	r.POST("/rest/counter/sub/:sub", server.SubCounter)
-	r.GET("/rest/counter/val", server.GetCounter)
-	r.POST("/rest/user", server.CreateUser)
-	r.GET("/rest/user/:id", server.GetUser)
+	r.GET("/rest/counter", server.ListCounters)
+	r.GET("/rest/counter/:name", server.GetCounter)
+	r.PUT("/rest/counter/:name", server.SetCounterBounds)
+	r.DELETE("/rest/counter/:name", server.DeleteCounter)
+	r.POST("/rest/counter/:name/:op", server.ChangeDefaultCounter)
+	r.POST("/rest/counter/:name/:op/:n", server.ChangeCounter)
+	r.GET("/rest/user", server.ListUsers)
+	r.POST("/rest/user", server.Idempotent(server.CreateUser))
+	r.GET("/rest/user/:id", WithStatic("export", server.ExportUsers, server.GetUser))
+	r.POST("/rest/user/:id", WithStatic("import", server.Idempotent(server.ImportUsers), nil))
 	r.PUT("/rest/user/:id", server.UpdateUser)
+	r.PATCH("/rest/user/:id", server.PatchUser)
 	r.DELETE("/rest/user/:id", server.DeleteUser)
-	r.POST("/rest/hash/calc", server.GenerateHash)
+	r.POST("/rest/user/:id/restore", server.RestoreUser)
+	r.POST("/rest/hash/calc", server.Idempotent(server.GenerateHash))
+	r.POST("/rest/hash/calc/batch", server.Idempotent(server.GenerateBatch))
+	r.GET("/rest/hash/batch/:id", server.GetBatch)
 	r.GET("/rest/hash/result/:id", server.GetHash)
+	r.DELETE("/rest/hash/result/:id", server.CancelHash)
+	r.POST("/rest/hash/result/:id/retry", server.RetryHash)
+	r.GET("/rest/hash/result/:id/deliveries", server.ListDeliveries)
+	r.GET("/rest/hash/result/:id/events", server.HashEvents)
 	r.GET("/rest/hash", server.HashHandler)
+	r.GET("/rest/hash/jobs", server.ListJobs)
 	r.GET("/rest/self/find/:str", server.GetIdentifiers)
+	r.GET("/rest/admin/workers", server.GetWorkers)
+	r.PUT("/rest/admin/workers", server.ResizeWorkers)
 	return r
 }
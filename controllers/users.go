@@ -0,0 +1,328 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"rest/models"
+	"rest/myerrors"
+	"rest/utils"
+	"rest/viewmodels"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	defaultUsersLimit = 20
+	maxUsersLimit     = 100
+	maxUserQuery      = 255
+	mergePatchType    = "application/merge-patch+json"
+	jsonPatchType     = "application/json-patch+json"
+	// patchAttempts is how many times unconditional patch is applied when user changes concurrently
+	patchAttempts = 3
+)
+
+// userFields is the part of user set by PUT and changed by PATCH
+type userFields struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email,omitempty"`
+	IIN       string `json:"iin,omitempty"`
+}
+
+// userETag returns entity tag of user at version
+// Version changes with every update, so it identifies representation of the user
+func userETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// matchETag reports whether If-Match or If-None-Match header value lists etag or is *
+// Weak tags match only if weak is set, If-Match requires strong comparison
+func matchETag(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[2:]
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatch checks If-Match of request against ETag of current user
+func ifMatch(ctx *fasthttp.RequestCtx, current *models.User) error {
+	im := ctx.Request.Header.Peek(fasthttp.HeaderIfMatch)
+	if len(im) > 0 && !matchETag(string(im), userETag(current.Version), false) {
+		return myerrors.ErrVersionMismatch.WithDetail(fmt.Sprintf("current ETag is %s", userETag(current.Version)))
+	}
+	return nil
+}
+
+// userUpdated answers successful PUT or PATCH with ETag of new version
+func userUpdated(ctx *fasthttp.RequestCtx, u *models.User) {
+	ctx.Response.Header.Set(fasthttp.HeaderETag, userETag(u.Version))
+	viewmodels.Result(ctx, viewmodels.UserID{ID: u.ID}, fmt.Sprintf("%s Updated user under ID %d. To view changes, go to /rest/user/%d.", successMsg, u.ID, u.ID))
+}
+
+// PatchUser handles PATCH /rest/user/:id changing user with JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902)
+// Patch is applied to object with first_name, last_name, email and iin, result is validated like PUT body.
+// If-Match is honoured like in UpdateUser, without it patch is applied to the latest version of user.
+func (s *MyServer) PatchUser(ctx *fasthttp.RequestCtx) {
+	ID, ok := ctx.UserValue("id").(string)
+	if !ok {
+		log.Println("Couldn't get ID from context")
+		viewmodels.Error(ctx, myerrors.ErrCtxValue)
+		return
+	}
+	if !utils.ValidateID(ID) {
+		log.Println("Invalid ID:", ID)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput)
+		return
+	}
+	var apply func(doc, patch []byte) ([]byte, error)
+	switch mediaType, _, _ := mime.ParseMediaType(string(ctx.Request.Header.ContentType())); mediaType {
+	case mergePatchType:
+		apply = utils.MergePatch
+	case jsonPatchType:
+		apply = utils.JSONPatch
+	default:
+		log.Println("PatchUser: unsupported content type", mediaType)
+		viewmodels.Error(ctx, myerrors.ErrUnsupportedMedia.WithDetail("use "+mergePatchType+" or "+jsonPatchType))
+		return
+	}
+	patch := ctx.Request.Body()
+	if len(patch) == 0 {
+		log.Println("Couldn't get body")
+		viewmodels.Error(ctx, myerrors.ErrBodyNotFound)
+		return
+	}
+	conditional := len(ctx.Request.Header.Peek(fasthttp.HeaderIfMatch)) > 0
+	for attempt := 1; ; attempt++ {
+		current, err := s.db.GetUser(ID)
+		if err != nil {
+			log.Println("PatchUser err:", err)
+			viewmodels.Error(ctx, err)
+			return
+		}
+		if err := ifMatch(ctx, current); err != nil {
+			log.Println("PatchUser err:", err)
+			viewmodels.Error(ctx, err)
+			return
+		}
+		user, err := s.patchUser(*current, patch, apply)
+		if err != nil {
+			log.Println("PatchUser err:", err)
+			viewmodels.Error(ctx, err)
+			return
+		}
+		updated, err := s.db.UpdateUser(ID, user)
+		if errors.Is(err, myerrors.ErrVersionMismatch) && !conditional && attempt < patchAttempts {
+			continue
+		}
+		if err != nil {
+			log.Println("PatchUser err:", err)
+			viewmodels.Error(ctx, err)
+			return
+		}
+		userUpdated(ctx, updated)
+		return
+	}
+}
+
+// patchUser applies patch to editable fields of u and validates result
+// Returned user keeps version of u so that it is not saved over concurrent change
+func (s *MyServer) patchUser(u models.User, patch []byte, apply func(doc, patch []byte) ([]byte, error)) (models.User, error) {
+	doc, err := json.Marshal(userFields{FirstName: u.FirstName, LastName: u.LastName, Email: u.Email, IIN: u.IIN})
+	if err != nil {
+		return u, err
+	}
+	patched, err := apply(doc, patch)
+	var pe *utils.PatchError
+	if errors.As(err, &pe) {
+		return u, myerrors.ErrPatchFailed.WithDetail(pe.Error())
+	}
+	if err != nil {
+		return u, myerrors.ErrInvalidInput.WithDetail("malformed patch: " + err.Error())
+	}
+	var f userFields
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return u, myerrors.ErrInvalidInput.WithDetail("patched user may only have first_name, last_name, email and iin strings")
+	}
+	u.FirstName, u.LastName, u.Email, u.IIN = f.FirstName, f.LastName, f.Email, f.IIN
+	return u, s.validateUser(&u)
+}
+
+// userCursor is the decoded form of next_cursor
+// Sort and order are kept so that cursor is not reused with different ordering
+type userCursor struct {
+	Sort models.UserSort `json:"s"`
+	Desc bool            `json:"d,omitempty"`
+	Key  string          `json:"k,omitempty"`
+	ID   int64           `json:"id"`
+}
+
+// encodeCursor returns opaque cursor pointing at u
+func encodeCursor(f models.UserFilter, u models.User) string {
+	c := userCursor{Sort: f.Sort, Desc: f.Desc, ID: u.ID}
+	if f.Sort != models.SortByID {
+		c.Key = f.Sort.Key(u)
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor parses cursor produced by encodeCursor for the same ordering as f
+func decodeCursor(f models.UserFilter, s string) (*models.UserCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, myerrors.ErrInvalidInput.WithDetail("malformed cursor")
+	}
+	var c userCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, myerrors.ErrInvalidInput.WithDetail("malformed cursor")
+	}
+	if c.Sort != f.Sort || c.Desc != f.Desc {
+		return nil, myerrors.ErrInvalidInput.WithDetail("cursor does not match sort and order")
+	}
+	return &models.UserCursor{Key: c.Key, ID: c.ID}, nil
+}
+
+// ListUsers handles GET /rest/user returning page of users
+// q selects users whose first or last name starts with it, deleted=true lists deleted users instead,
+// sort and order set ordering, pages are selected either with offset or with cursor from next_cursor
+func (s *MyServer) ListUsers(ctx *fasthttp.RequestCtx) {
+	args := ctx.QueryArgs()
+	f := models.UserFilter{
+		Query:   utils.NormalizeName(string(args.Peek("q"))),
+		Sort:    models.SortByID,
+		Deleted: args.GetBool("deleted"),
+		Limit:   defaultUsersLimit,
+	}
+	if len(f.Query) > maxUserQuery {
+		log.Println("ListUsers: query is too long")
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail(fmt.Sprintf("q must not be longer than %d bytes", maxUserQuery)))
+		return
+	}
+	if args.Has("sort") {
+		f.Sort = models.UserSort(args.Peek("sort"))
+	}
+	if !f.Sort.Valid() {
+		log.Println("ListUsers: invalid sort", f.Sort)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail("sort must be one of id, first_name, last_name"))
+		return
+	}
+	switch order := string(args.Peek("order")); order {
+	case "", "asc":
+	case "desc":
+		f.Desc = true
+	default:
+		log.Println("ListUsers: invalid order", order)
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail("order must be asc or desc"))
+		return
+	}
+	var err error
+	if f.Offset, err = intArg(args, "offset", 0); err != nil || f.Offset < 0 {
+		log.Println("ListUsers: invalid offset")
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail("offset must be non-negative"))
+		return
+	}
+	if f.Limit, err = intArg(args, "limit", defaultUsersLimit); err != nil || f.Limit < 1 || f.Limit > maxUsersLimit {
+		log.Println("ListUsers: invalid limit")
+		viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail(fmt.Sprintf("limit must be between 1 and %d", maxUsersLimit)))
+		return
+	}
+	if args.Has("cursor") {
+		if args.Has("offset") {
+			log.Println("ListUsers: both cursor and offset")
+			viewmodels.Error(ctx, myerrors.ErrInvalidInput.WithDetail("use either cursor or offset"))
+			return
+		}
+		if f.After, err = decodeCursor(f, string(args.Peek("cursor"))); err != nil {
+			log.Println("ListUsers: invalid cursor")
+			viewmodels.Error(ctx, err)
+			return
+		}
+	}
+	// one more user is requested to know whether there is next page
+	page := f
+	page.Limit++
+	users, total, err := s.db.ListUsers(page)
+	if err != nil {
+		log.Println("ListUsers err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	res := viewmodels.Users{Users: users, Total: total, Offset: f.Offset, Limit: f.Limit}
+	if len(users) > f.Limit {
+		res.Users = users[:f.Limit]
+		res.NextCursor = encodeCursor(f, res.Users[f.Limit-1])
+	}
+	var text string
+	for _, u := range res.Users {
+		text += fmt.Sprintf("%d %s %s\n", u.ID, u.FirstName, u.LastName)
+	}
+	viewmodels.Result(ctx, res, text)
+}
+
+// validateContacts checks optional email and IIN of u
+func validateContacts(u models.User) error {
+	if u.Email != "" && !utils.ValidateEmail(u.Email) {
+		return myerrors.ErrInvalidInput.WithDetail("invalid email")
+	}
+	if u.IIN != "" && !utils.ValidateIIN(u.IIN) {
+		return myerrors.ErrInvalidInput.WithDetail("invalid iin")
+	}
+	return nil
+}
+
+// validateUser normalizes names of user saved as a whole and checks them against name policy together with contacts
+func (s *MyServer) validateUser(u *models.User) error {
+	if err := utils.ValidateUser(u, s.users.Names); err != nil {
+		return myerrors.ErrInvalidInput.WithDetail(err.Error())
+	}
+	return validateContacts(*u)
+}
+
+// PurgeUsers permanently removes users deleted longer than retention ago
+// It runs every purge interval until ctx is done
+func (s *MyServer) PurgeUsers(ctx context.Context) {
+	ticker := s.workers.clock.NewTicker(s.users.PurgeInterval)
+	defer ticker.Stop()
+	for {
+		s.purgeUsers()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// purgeUsers removes users deleted before retention
+func (s *MyServer) purgeUsers() {
+	n, err := s.db.PurgeUsers(s.workers.clock.Now().UTC().Add(-s.users.Retention))
+	if err != nil {
+		log.Println("ERROR|Purge users:", err)
+		return
+	}
+	if n > 0 {
+		log.Println("INFO|Purged", n, "deleted users")
+	}
+}
@@ -0,0 +1,447 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"rest/models"
+	"rest/myerrors"
+	"rest/viewmodels"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+var testUsers = []models.User{
+	{ID: 1, FirstName: "Ann", LastName: "Lee"},
+	{ID: 2, FirstName: "Bob", LastName: "Smith"},
+	{ID: 3, FirstName: "Anna", LastName: "Brown"},
+	{ID: 4, FirstName: "Carl", LastName: "Anders"},
+	{ID: 5, FirstName: "Bob", LastName: "Abbot"},
+}
+
+var listUsersTests = []struct {
+	number             int
+	uri                string
+	expectedOutput     string
+	expectedStatusCode int
+}{
+	{0, "/rest/user", "1 Ann Lee\n2 Bob Smith\n3 Anna Brown\n4 Carl Anders\n5 Bob Abbot\n", fasthttp.StatusOK},
+	{1, "/rest/user?limit=2&offset=1", "2 Bob Smith\n3 Anna Brown\n", fasthttp.StatusOK},
+	{2, "/rest/user?order=desc&limit=2", "5 Bob Abbot\n4 Carl Anders\n", fasthttp.StatusOK},
+	{3, "/rest/user?sort=first_name", "1 Ann Lee\n3 Anna Brown\n2 Bob Smith\n5 Bob Abbot\n4 Carl Anders\n", fasthttp.StatusOK},
+	{4, "/rest/user?sort=last_name&order=desc", "2 Bob Smith\n1 Ann Lee\n3 Anna Brown\n4 Carl Anders\n5 Bob Abbot\n", fasthttp.StatusOK},
+	{5, "/rest/user?q=An", "1 Ann Lee\n3 Anna Brown\n4 Carl Anders\n", fasthttp.StatusOK},
+	{6, "/rest/user?q=Ann&sort=first_name&order=desc", "3 Anna Brown\n1 Ann Lee\n", fasthttp.StatusOK},
+	{7, "/rest/user?q=Zed", "", fasthttp.StatusOK},
+	{8, "/rest/user?sort=email", "invalid input, sort must be one of id, first_name, last_name", fasthttp.StatusBadRequest},
+	{9, "/rest/user?order=up", "invalid input, order must be asc or desc", fasthttp.StatusBadRequest},
+	{10, "/rest/user?limit=101", "invalid input, limit must be between 1 and 100", fasthttp.StatusBadRequest},
+	{11, "/rest/user?offset=-1", "invalid input, offset must be non-negative", fasthttp.StatusBadRequest},
+	{12, "/rest/user?cursor=%21", "invalid input, malformed cursor", fasthttp.StatusBadRequest},
+	{13, "/rest/user?cursor=eyJzIjoiaWQiLCJpZCI6MX0&offset=1", "invalid input, use either cursor or offset", fasthttp.StatusBadRequest},
+	{14, "/rest/user?cursor=eyJzIjoiaWQiLCJpZCI6MX0&sort=last_name", "invalid input, cursor does not match sort and order", fasthttp.StatusBadRequest},
+	{15, "/rest/user?cursor=eyJzIjoiaWQiLCJpZCI6MX0&limit=2", "2 Bob Smith\n3 Anna Brown\n", fasthttp.StatusOK},
+}
+
+// TestListUsers tests filtering, ordering and pagination of users
+func TestListUsers(t *testing.T) {
+	server := NewMyServer(newTestDB(testUsers...), &testRedis{}, newTestJobs(), nil, nil, testConfig())
+	c, stop := listen(server)
+	defer stop()
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
+	for _, testCase := range listUsersTests {
+		req.SetRequestURI("http://test.com" + testCase.uri)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode() != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, res.StatusCode())
+		}
+		if body, exp := string(res.Body()), testCase.expectedOutput; body != exp {
+			t.Errorf("for test #%d, expected %q but got %q", testCase.number, exp, body)
+		}
+	}
+}
+
+// TestListUsersCursor tests that following next_cursor visits every user once
+func TestListUsersCursor(t *testing.T) {
+	server := NewMyServer(newTestDB(testUsers...), &testRedis{}, newTestJobs(), nil, nil, testConfig())
+	c, stop := listen(server)
+	defer stop()
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	var ids []int64
+	uri := "http://test.com/rest/user?sort=first_name&order=desc&limit=2"
+	for pages := 0; ; pages++ {
+		if pages == len(testUsers) {
+			t.Fatalf("cursor does not advance, got %v", ids)
+		}
+		req.SetRequestURI(uri)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		var got struct {
+			Data viewmodels.Users `json:"data"`
+		}
+		if err := json.Unmarshal(res.Body(), &got); err != nil {
+			t.Fatalf("unexpected body %q", res.Body())
+		}
+		if got.Data.Total != len(testUsers) {
+			t.Errorf("expected total %d but got %d", len(testUsers), got.Data.Total)
+		}
+		for _, u := range got.Data.Users {
+			ids = append(ids, u.ID)
+		}
+		if got.Data.NextCursor == "" {
+			break
+		}
+		uri = "http://test.com/rest/user?sort=first_name&order=desc&limit=2&cursor=" + got.Data.NextCursor
+	}
+	exp := []int64{4, 5, 2, 3, 1}
+	if len(ids) != len(exp) {
+		t.Fatalf("expected %v but got %v", exp, ids)
+	}
+	for i := range exp {
+		if ids[i] != exp[i] {
+			t.Fatalf("expected %v but got %v", exp, ids)
+		}
+	}
+}
+
+// listen serves router of server in memory and returns client connected to it
+func listen(server *MyServer) (*fasthttp.Client, func()) {
+	ln := fasthttputil.NewInmemoryListener()
+	s := &fasthttp.Server{
+		Handler: NewRouter(server).Handler,
+	}
+	go s.Serve(ln) //nolint:errcheck
+	c := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	return c, func() {
+		_ = ln.Close()
+	}
+}
+
+var userLifecycleTests = []struct {
+	number             int
+	method             string
+	uri                string
+	body               string
+	expectedOutput     string
+	expectedStatusCode int
+}{
+	{0, fasthttp.MethodPost, "/rest/user", `{"first_name": "Dan", "last_name": "Ray", "email": "dan@mail.kz", "iin": "980124450084"}`, "Success! Created new user under ID 6", fasthttp.StatusOK},
+	{1, fasthttp.MethodPost, "/rest/user", `{"first_name": "Dan", "last_name": "Ray", "email": "Email: dan@mail.kz"}`, "invalid input, invalid email", fasthttp.StatusBadRequest},
+	{2, fasthttp.MethodPost, "/rest/user", `{"first_name": "Dan", "last_name": "Ray", "iin": "991301300123"}`, "invalid input, invalid iin", fasthttp.StatusBadRequest},
+	{3, fasthttp.MethodPost, "/rest/user", `{"first_name": "Eve", "last_name": "Ray", "iin": "980124450084"}`, "user with this IIN already exists", fasthttp.StatusConflict},
+	{4, fasthttp.MethodPut, "/rest/user/6", `{"first_name": "Dan", "last_name": "Ray", "email": "ray@mail.kz", "iin": "980124450084"}`, "Success! Updated user under ID 6. To view changes, go to /rest/user/6.", fasthttp.StatusOK},
+	{5, fasthttp.MethodPut, "/rest/user/6", `{"first_name": "Dan", "last_name": "Ray", "iin": "1"}`, "invalid input, invalid iin", fasthttp.StatusBadRequest},
+	{6, fasthttp.MethodPost, "/rest/user/6/restore", "", "user is not deleted", fasthttp.StatusConflict},
+	{7, fasthttp.MethodDelete, "/rest/user/6", "", "Success! Deleted user under ID 6", fasthttp.StatusOK},
+	{8, fasthttp.MethodGet, "/rest/user/6", "", "user not found", fasthttp.StatusNotFound},
+	{9, fasthttp.MethodPut, "/rest/user/6", `{"first_name": "Ed", "last_name": "Ray"}`, "user not found", fasthttp.StatusNotFound},
+	{10, fasthttp.MethodDelete, "/rest/user/6", "", "user not found", fasthttp.StatusNotFound},
+	{11, fasthttp.MethodGet, "/rest/user?deleted=true", "", "6 Dan Ray\n", fasthttp.StatusOK},
+	{12, fasthttp.MethodPost, "/rest/user/6/restore", "", "Success! Restored user under ID 6", fasthttp.StatusOK},
+	{13, fasthttp.MethodPost, "/rest/user/7/restore", "", "user not found", fasthttp.StatusNotFound},
+	{14, fasthttp.MethodPost, "/rest/user/x/restore", "", "invalid input", fasthttp.StatusBadRequest},
+	{15, fasthttp.MethodGet, "/rest/user?q=Dan", "", "6 Dan Ray\n", fasthttp.StatusOK},
+}
+
+// TestUserLifecycle tests contacts validation, soft delete and restore of users
+func TestUserLifecycle(t *testing.T) {
+	db := newTestDB(testUsers...)
+	server := NewMyServer(db, &testRedis{}, newTestJobs(), nil, nil, testConfig())
+	c, stop := listen(server)
+	defer stop()
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	for _, testCase := range userLifecycleTests {
+		req.Reset()
+		req.Header.SetMethod(testCase.method)
+		req.Header.Set(fasthttp.HeaderAccept, "text/plain")
+		req.SetRequestURI("http://test.com" + testCase.uri)
+		req.SetBodyString(testCase.body)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode() != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, res.StatusCode())
+		}
+		if body, exp := string(res.Body()), testCase.expectedOutput; body != exp {
+			t.Errorf("for test #%d, expected %q but got %q", testCase.number, exp, body)
+		}
+	}
+
+	u, err := db.GetUser("6")
+	if err != nil || u.Email != "ray@mail.kz" || u.IIN != "980124450084" || u.DeletedAt != nil {
+		t.Errorf("unexpected restored user %+v, %v", u, err)
+	}
+}
+
+// TestPurgeUsers tests that only users deleted longer than retention ago are purged
+func TestPurgeUsers(t *testing.T) {
+	now := time.Now().UTC()
+	old, recent := now.Add(-time.Hour*2), now.Add(-time.Minute)
+	db := newTestDB(
+		models.User{ID: 1, FirstName: "Ann", LastName: "Lee"},
+		models.User{ID: 2, FirstName: "Bob", LastName: "Smith", DeletedAt: &old},
+		models.User{ID: 3, FirstName: "Anna", LastName: "Brown", DeletedAt: &recent},
+	)
+	cfg := testConfig()
+	cfg.Users.Retention = time.Hour
+	server := NewMyServer(db, &testRedis{}, newTestJobs(), nil, nil, cfg)
+	server.purgeUsers()
+	if _, total, _ := db.ListUsers(models.UserFilter{Limit: 10}); total != 1 {
+		t.Errorf("expected %d live users but got %d", 1, total)
+	}
+	deleted, _, _ := db.ListUsers(models.UserFilter{Deleted: true, Limit: 10})
+	if len(deleted) != 1 || deleted[0].ID != 3 {
+		t.Errorf("expected only user 3 to stay deleted but got %+v", deleted)
+	}
+	if err := db.RestoreUser("2"); err != myerrors.ErrUserNotFound {
+		t.Errorf("expected purged user to be gone but got %v", err)
+	}
+}
+
+// TestPurgeUsersLoop tests that users are purged on start and then on every tick of the clock
+func TestPurgeUsersLoop(t *testing.T) {
+	now := time.Now().UTC()
+	deleted := now.Add(-time.Minute)
+	db := newTestDB(
+		models.User{ID: 1, FirstName: "Ann", LastName: "Lee"},
+		models.User{ID: 2, FirstName: "Bob", LastName: "Smith", DeletedAt: &deleted},
+	)
+	cfg := testConfig()
+	cfg.Users.Retention = time.Hour
+	server := NewMyServer(db, &testRedis{}, newTestJobs(), nil, nil, cfg)
+	clock := &testClock{now: []time.Time{now, now.Add(time.Hour * 2)}, ticks: make(chan time.Time)}
+	server.workers.clock = clock
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		server.PurgeUsers(ctx)
+		close(done)
+	}()
+	clock.ticks <- time.Time{}
+	cancel()
+	<-done
+	if len(clock.now) != 0 {
+		t.Errorf("expected %d purges but got %d", 2, 2-len(clock.now))
+	}
+	if err := db.RestoreUser("2"); err != myerrors.ErrUserNotFound {
+		t.Errorf("expected user to be purged on tick but got %v", err)
+	}
+}
+
+var conditionalUserTests = []struct {
+	number             int
+	method             string
+	header             string
+	value              string
+	body               string
+	expectedETag       string
+	expectedStatusCode int
+}{
+	{0, fasthttp.MethodGet, "", "", "", `"1"`, fasthttp.StatusOK},
+	{1, fasthttp.MethodGet, fasthttp.HeaderIfNoneMatch, `"1"`, "", `"1"`, fasthttp.StatusNotModified},
+	{2, fasthttp.MethodGet, fasthttp.HeaderIfNoneMatch, `"0", W/"1"`, "", `"1"`, fasthttp.StatusNotModified},
+	{3, fasthttp.MethodGet, fasthttp.HeaderIfNoneMatch, `"2"`, "", `"1"`, fasthttp.StatusOK},
+	{4, fasthttp.MethodPut, fasthttp.HeaderIfMatch, `"2"`, `{"first_name": "Ed", "last_name": "Lee"}`, "", fasthttp.StatusPreconditionFailed},
+	{5, fasthttp.MethodPut, fasthttp.HeaderIfMatch, `W/"1"`, `{"first_name": "Ed", "last_name": "Lee"}`, "", fasthttp.StatusPreconditionFailed},
+	{6, fasthttp.MethodPut, fasthttp.HeaderIfMatch, `"0", "1"`, `{"first_name": "Ed", "last_name": "Lee"}`, `"2"`, fasthttp.StatusOK},
+	{7, fasthttp.MethodPut, fasthttp.HeaderIfMatch, `"1"`, `{"first_name": "Al", "last_name": "Lee"}`, "", fasthttp.StatusPreconditionFailed},
+	{8, fasthttp.MethodGet, fasthttp.HeaderIfNoneMatch, `"1"`, "", `"2"`, fasthttp.StatusOK},
+	{9, fasthttp.MethodPut, fasthttp.HeaderIfMatch, "*", `{"first_name": "Ed", "last_name": "Ng"}`, `"3"`, fasthttp.StatusOK},
+	{10, fasthttp.MethodPut, "", "", `{"first_name": "Ed", "last_name": "Li", "version": 1}`, `"4"`, fasthttp.StatusOK},
+	{11, fasthttp.MethodGet, fasthttp.HeaderIfNoneMatch, "*", "", `"4"`, fasthttp.StatusNotModified},
+}
+
+// TestConditionalUser tests ETag of users and conditional reads and updates
+func TestConditionalUser(t *testing.T) {
+	db := newTestDB(testUsers...)
+	server := NewMyServer(db, &testRedis{}, newTestJobs(), nil, nil, testConfig())
+	c, stop := listen(server)
+	defer stop()
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	for _, testCase := range conditionalUserTests {
+		req.Reset()
+		req.Header.SetMethod(testCase.method)
+		req.SetRequestURI("http://test.com/rest/user/1")
+		if testCase.header != "" {
+			req.Header.Set(testCase.header, testCase.value)
+		}
+		req.SetBodyString(testCase.body)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode() != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, res.StatusCode())
+		}
+		if etag := string(res.Header.Peek(fasthttp.HeaderETag)); etag != testCase.expectedETag {
+			t.Errorf("for test #%d, expected ETag %q but got %q", testCase.number, testCase.expectedETag, etag)
+		}
+		if res.StatusCode() == fasthttp.StatusNotModified && len(res.Body()) != 0 {
+			t.Errorf("for test #%d, expected empty body but got %q", testCase.number, res.Body())
+		}
+	}
+
+	u, _ := db.GetUser("1")
+	if u.FirstName != "Ed" || u.LastName != "Li" || u.Version != 4 {
+		t.Errorf("unexpected user %+v", u)
+	}
+}
+
+var patchUserTests = []struct {
+	number             int
+	contentType        string
+	ifMatch            string
+	body               string
+	expectedOutput     string
+	expectedStatusCode int
+}{
+	{0, "application/merge-patch+json", "", `{"email": "ann@mail.kz", "iin": "980124450084"}`, "Success! Updated user under ID 1. To view changes, go to /rest/user/1.", fasthttp.StatusOK},
+	{1, "application/merge-patch+json", `"1"`, `{"first_name": "Anna"}`, `user was modified by another request, fetch it again, current ETag is "2"`, fasthttp.StatusPreconditionFailed},
+	{2, "application/merge-patch+json", `"2"`, `{"email": null, "last_name": "Li"}`, "Success! Updated user under ID 1. To view changes, go to /rest/user/1.", fasthttp.StatusOK},
+	{3, "application/merge-patch+json", "", `{"last_name": null}`, "invalid input, last_name is required", fasthttp.StatusBadRequest},
+	{4, "application/merge-patch+json", "", `{"version": 7}`, "invalid input, patched user may only have first_name, last_name, email and iin strings", fasthttp.StatusBadRequest},
+	{5, "application/merge-patch+json", "", `{"first_name": 1}`, "invalid input, patched user may only have first_name, last_name, email and iin strings", fasthttp.StatusBadRequest},
+	{6, "application/merge-patch+json", "", `{"email": "bad"}`, "invalid input, invalid email", fasthttp.StatusBadRequest},
+	{7, "application/merge-patch+json", "", `{`, "invalid input, malformed patch: unexpected end of JSON input", fasthttp.StatusBadRequest},
+	{8, "application/json-patch+json", "", `[{"op": "test", "path": "/last_name", "value": "Li"}, {"op": "replace", "path": "/last_name", "value": "Ng"}, {"op": "remove", "path": "/iin"}]`,
+		"Success! Updated user under ID 1. To view changes, go to /rest/user/1.", fasthttp.StatusOK},
+	{9, "application/json-patch+json", "", `[{"op": "test", "path": "/last_name", "value": "Li"}, {"op": "replace", "path": "/last_name", "value": "Ray"}]`,
+		`patch cannot be applied, operation 0: test failed at "/last_name"`, fasthttp.StatusUnprocessableEntity},
+	{10, "application/json-patch+json", "", `[{"op": "remove", "path": "/email"}]`, `patch cannot be applied, operation 0: path "/email" does not exist`, fasthttp.StatusUnprocessableEntity},
+	{11, "application/json-patch+json", "", `[{"op": "copy", "from": "/first_name", "path": "/last_name"}, {"op": "add", "path": "/email", "value": "ann@mail.kz"}]`,
+		"Success! Updated user under ID 1. To view changes, go to /rest/user/1.", fasthttp.StatusOK},
+	{12, "application/json-patch+json", "", `[{"op": "move", "from": "/email", "path": "/iin"}]`, "invalid input, invalid iin", fasthttp.StatusBadRequest},
+	{13, "application/json-patch+json", "", `[{"op": "jump", "path": "/iin"}]`, `invalid input, malformed patch: operation 0: unknown op "jump"`, fasthttp.StatusBadRequest},
+	{14, "application/json-patch+json", "", `{"op": "remove", "path": "/iin"}`, "invalid input, malformed patch: patch must be array of operations", fasthttp.StatusBadRequest},
+	{15, "application/json-patch+json", "", `[{"op": "add", "path": "first_name", "value": "Bo"}]`, `invalid input, malformed patch: operation 0: path "first_name" must start with /`, fasthttp.StatusBadRequest},
+	{16, "application/json", "", `{"first_name": "Bo"}`, "unsupported content type, use application/merge-patch+json or application/json-patch+json", fasthttp.StatusUnsupportedMediaType},
+	{17, "application/merge-patch+json", "", "", "couldn't get body", fasthttp.StatusBadRequest},
+}
+
+// TestPatchUser tests merge patch and JSON patch of users
+func TestPatchUser(t *testing.T) {
+	db := newTestDB(testUsers...)
+	server := NewMyServer(db, &testRedis{}, newTestJobs(), nil, nil, testConfig())
+	c, stop := listen(server)
+	defer stop()
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	for _, testCase := range patchUserTests {
+		req.Reset()
+		req.Header.SetMethod(fasthttp.MethodPatch)
+		req.Header.Set(fasthttp.HeaderAccept, "text/plain")
+		req.Header.SetContentType(testCase.contentType)
+		if testCase.ifMatch != "" {
+			req.Header.Set(fasthttp.HeaderIfMatch, testCase.ifMatch)
+		}
+		req.SetRequestURI("http://test.com/rest/user/1")
+		req.SetBodyString(testCase.body)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode() != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, res.StatusCode())
+		}
+		if body, exp := string(res.Body()), testCase.expectedOutput; body != exp {
+			t.Errorf("for test #%d, expected %q but got %q", testCase.number, exp, body)
+		}
+	}
+
+	u, _ := db.GetUser("1")
+	if u.FirstName != "Ann" || u.LastName != "Ann" || u.Email != "ann@mail.kz" || u.IIN != "" || u.Version != 5 {
+		t.Errorf("unexpected patched user %+v", u)
+	}
+}
+
+var namePolicyTests = []struct {
+	number         int
+	scripts        string
+	separators     string
+	firstName      string
+	lastName       string
+	expectedOutput string
+}{
+	{0, "latin,kazakh", "hyphen,apostrophe,space", "Әлия", "Сейтқали", "Success! Created new user under ID 1"},
+	{1, "latin,kazakh", "hyphen,apostrophe,space", "Jürgen", "Müller", "Success! Created new user under ID 2"},
+	{2, "latin,kazakh", "hyphen,apostrophe,space", "Anna Maria", "O'Brien", "Success! Created new user under ID 3"},
+	{3, "latin,kazakh", "hyphen,apostrophe,space", "  Sara  ", "Smith-Jones", "Success! Created new user under ID 4"},
+	{4, "latin,kazakh", "hyphen,apostrophe,space", "Zoe\u0301", "Mu\u0308ller", "Success! Created new user under ID 5"},
+	{5, "latin,kazakh", "hyphen,apostrophe,space", "Ann1", "Lee", "invalid input, first_name cannot contain '1'"},
+	{6, "latin,kazakh", "hyphen,apostrophe,space", "-Ann", "Lee-", "invalid input, first_name must start and end with a letter; last_name must start and end with a letter"},
+	{7, "latin,kazakh", "hyphen,apostrophe,space", "Ann", "Lee--Ray", "invalid input, last_name cannot have '-' after '-'"},
+	{8, "latin,kazakh", "hyphen,apostrophe,space", "Ђорђе", "Lee", "invalid input, first_name cannot contain 'Ђ'"},
+	{9, "latin,kazakh", "hyphen,apostrophe,space", "", " ", "invalid input, first_name is required; last_name is required"},
+	{10, "latin,kazakh", "hyphen,apostrophe,space", strings.Repeat("a", 101), "Lee", "invalid input, first_name must be from 1 to 100 characters long"},
+	{11, "cyrillic", "hyphen,apostrophe,space", "Ђорђе", "Ann", "invalid input, last_name cannot contain 'A'"},
+	{12, "cyrillic", "hyphen,apostrophe,space", "Ђорђе", "Јовић", "Success! Created new user under ID 6"},
+	{13, "latin", "", "Ann", "O'Brien", "invalid input, last_name cannot contain '\\''"},
+	{14, "latin", "", "Ann", "Lee Ray", "invalid input, last_name cannot contain ' '"},
+}
+
+// TestNamePolicy tests that names are normalized and checked against configured policy
+func TestNamePolicy(t *testing.T) {
+	db := newTestDB()
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	for _, testCase := range namePolicyTests {
+		cfg := testConfig()
+		cfg.Users.Names.Scripts = strings.Split(testCase.scripts, ",")
+		cfg.Users.Names.Separators = nil
+		if testCase.separators != "" {
+			cfg.Users.Names.Separators = strings.Split(testCase.separators, ",")
+		}
+		server := NewMyServer(db, &testRedis{}, newTestJobs(), nil, nil, cfg)
+		c, stop := listen(server)
+		req.Reset()
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.Header.Set(fasthttp.HeaderAccept, "text/plain")
+		req.SetRequestURI("http://test.com/rest/user")
+		req.SetBodyString(`{"first_name": "` + testCase.firstName + `", "last_name": "` + testCase.lastName + `"}`)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		stop()
+		if body, exp := string(res.Body()), testCase.expectedOutput; body != exp {
+			t.Errorf("for test #%d, expected %q but got %q", testCase.number, exp, body)
+		}
+	}
+
+	for ID, exp := range map[string]string{"4": "Sara Smith-Jones", "5": "Zoé Müller"} {
+		if u, err := db.GetUser(ID); err != nil || u.FirstName+" "+u.LastName != exp {
+			t.Errorf("expected user %s to be saved as %q but got %+v, %v", ID, exp, u, err)
+		}
+	}
+}
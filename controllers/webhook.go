@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"rest/config"
+	"rest/models"
+	"rest/myerrors"
+	"rest/viewmodels"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// signatureHeader carries HMAC-SHA256 of callback body in form sha256=<hex>
+	signatureHeader = "X-Signature-256"
+	jobIDHeader     = "X-Hash-Job-ID"
+)
+
+type webhooks struct {
+	cfg    config.Webhooks
+	client *fasthttp.Client
+	// running counts callbacks being delivered
+	running sync.WaitGroup
+}
+
+// ListDeliveries handles GET /rest/hash/result/:id/deliveries returning callback delivery attempts of job
+func (s *MyServer) ListDeliveries(ctx *fasthttp.RequestCtx) {
+	ID, ok := ctx.UserValue("id").(string)
+	if !ok {
+		log.Println("ListDeliveries: couldn't get ID value from context")
+		viewmodels.Error(ctx, myerrors.ErrCtxValue)
+		return
+	}
+	if _, err := s.jobs.GetJob(ID); err != nil {
+		log.Println("ListDeliveries err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	deliveries, err := s.jobs.ListDeliveries(ID)
+	if err != nil {
+		log.Println("ListDeliveries err:", err)
+		viewmodels.Error(ctx, err)
+		return
+	}
+	viewmodels.JSON(ctx, viewmodels.Deliveries{Deliveries: deliveries})
+}
+
+// validCallback reports whether raw is absolute http or https URL
+func validCallback(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// notify sends finished job to its callback URL in background
+func (s *MyServer) notify(j *models.Job) {
+	if j.CallbackURL == "" {
+		return
+	}
+	s.hooks.running.Add(1)
+	go func(j models.Job) {
+		defer s.hooks.running.Done()
+		s.deliver(j)
+	}(*j)
+}
+
+// deliver posts job to its callback URL until receiver responds with 2xx or attempts run out.
+// Delay between attempts doubles up to max backoff, forced shutdown stops retries.
+func (s *MyServer) deliver(j models.Job) {
+	body, err := json.Marshal(j)
+	if err != nil {
+		log.Println("deliver err:", err)
+		return
+	}
+	cfg := s.hooks.cfg
+	backoff := cfg.Backoff
+	for attempt := 1; attempt <= cfg.Attempts; attempt++ {
+		d := s.post(&j, attempt, body)
+		if err := s.jobs.AddDelivery(&d); err != nil {
+			log.Println("deliver err:", err)
+		}
+		if d.Delivered {
+			return
+		}
+		if attempt == cfg.Attempts {
+			log.Printf("Callback of hash job %s was not delivered after %d attempts", j.ID, attempt)
+			return
+		}
+		select {
+		case <-s.workers.clock.After(backoff):
+		case <-s.workers.ctx.Done():
+			return
+		}
+		if backoff *= 2; backoff > cfg.MaxBackoff {
+			backoff = cfg.MaxBackoff
+		}
+	}
+}
+
+// post makes single attempt to deliver callback of job
+func (s *MyServer) post(j *models.Job, attempt int, body []byte) models.Delivery {
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	req.SetRequestURI(j.CallbackURL)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/json")
+	req.Header.Set(signatureHeader, sign(s.hooks.cfg.Secret, body))
+	req.Header.Set(jobIDHeader, j.ID)
+	req.SetBody(body)
+
+	d := models.Delivery{
+		JobID:   j.ID,
+		Attempt: attempt,
+		URL:     j.CallbackURL,
+		SentAt:  s.workers.clock.Now().UTC(),
+	}
+	if err := s.hooks.client.DoTimeout(req, res, s.hooks.cfg.Timeout); err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	d.StatusCode = res.StatusCode()
+	d.Delivered = d.StatusCode >= 200 && d.StatusCode < 300
+	if !d.Delivered {
+		d.Error = fmt.Sprintf("unexpected status %d", d.StatusCode)
+	}
+	return d
+}
+
+// sign returns HMAC-SHA256 of body in form sha256=<hex>
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"rest/models"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+var webhookTests = []struct {
+	number             int
+	statusCodes        []int
+	expectedStatusCode []int
+	expectedDelivered  bool
+}{
+	{0, []int{http.StatusOK}, []int{http.StatusOK}, true},
+	{1, []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusNoContent}, []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusNoContent}, true},
+	{2, []int{http.StatusInternalServerError}, []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError}, false},
+}
+
+// TestWebhook tests that finished job is delivered to callback URL with signature and retries
+func TestWebhook(t *testing.T) {
+	for _, testCase := range webhookTests {
+		var (
+			mx       sync.Mutex
+			received []models.Job
+		)
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if sig := r.Header.Get(signatureHeader); sig != sign("secret", body) {
+				t.Errorf("for test #%d, unexpected signature %q", testCase.number, sig)
+			}
+			var j models.Job
+			if err := json.Unmarshal(body, &j); err != nil {
+				t.Errorf("for test #%d, unexpected body %q", testCase.number, body)
+			}
+			mx.Lock()
+			defer mx.Unlock()
+			received = append(received, j)
+			code := testCase.statusCodes[len(testCase.statusCodes)-1]
+			if len(received) <= len(testCase.statusCodes) {
+				code = testCase.statusCodes[len(received)-1]
+			}
+			w.WriteHeader(code)
+		}))
+
+		cfg := testConfig()
+		cfg.Webhooks.Secret = "secret"
+		cfg.Webhooks.Attempts = 3
+		cfg.Webhooks.Backoff, cfg.Webhooks.MaxBackoff = time.Millisecond, time.Millisecond*2
+		jobs := newTestJobs(models.Job{ID: "a", Input: "1", Status: models.JobRunning, CallbackURL: receiver.URL + "/hook"})
+		s := newTestServer(jobs, cfg)
+		s.finishJob("a", "5", nil)
+		s.hooks.running.Wait()
+		receiver.Close()
+
+		if len(received) != len(testCase.expectedStatusCode) {
+			t.Fatalf("for test #%d, expected %d requests but got %d", testCase.number, len(testCase.expectedStatusCode), len(received))
+		}
+		if j := received[0]; j.ID != "a" || j.Status != models.JobDone || j.Result == nil || *j.Result != 5 {
+			t.Errorf("for test #%d, expected finished job but got %+v", testCase.number, j)
+		}
+		deliveries, _ := jobs.ListDeliveries("a")
+		if len(deliveries) != len(testCase.expectedStatusCode) {
+			t.Fatalf("for test #%d, expected %d deliveries but got %d", testCase.number, len(testCase.expectedStatusCode), len(deliveries))
+		}
+		for i, d := range deliveries {
+			if d.Attempt != i+1 || d.StatusCode != testCase.expectedStatusCode[i] {
+				t.Errorf("for test #%d, unexpected delivery %+v", testCase.number, d)
+			}
+		}
+		if last := deliveries[len(deliveries)-1]; last.Delivered != testCase.expectedDelivered {
+			t.Errorf("for test #%d, expected delivered %v but got %+v", testCase.number, testCase.expectedDelivered, last)
+		}
+	}
+}
+
+// TestWebhookUnreachable tests that failed connections are recorded as deliveries
+func TestWebhookUnreachable(t *testing.T) {
+	receiver := httptest.NewServer(http.NotFoundHandler())
+	receiver.Close()
+	cfg := testConfig()
+	cfg.Webhooks.Attempts = 2
+	cfg.Webhooks.Backoff = time.Millisecond
+	jobs := newTestJobs(models.Job{ID: "a", Input: "1", Status: models.JobQueued, CallbackURL: receiver.URL})
+	s := newTestServer(jobs, cfg)
+	if _, err := s.cancelJob("a"); err != nil {
+		t.Fatal(err)
+	}
+	s.hooks.running.Wait()
+	deliveries, _ := jobs.ListDeliveries("a")
+	if len(deliveries) != 2 {
+		t.Fatalf("expected %d deliveries but got %d", 2, len(deliveries))
+	}
+	for _, d := range deliveries {
+		if d.Delivered || d.StatusCode != 0 || d.Error == "" {
+			t.Errorf("expected failed delivery but got %+v", d)
+		}
+	}
+}
+
+// TestCallbackWithoutSecret tests that callback is refused when it could not be signed
+func TestCallbackWithoutSecret(t *testing.T) {
+	jobs := newTestJobs()
+	c, stop := listen(newTestServer(jobs, testConfig()))
+	defer stop()
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
+	job := `{"input": "15", "callback_url": "https://example.com/hook"}`
+	for uri, body := range map[string]string{"/rest/hash/calc": job, "/rest/hash/calc/batch": "[" + job + "]"} {
+		req.SetRequestURI("http://test.com" + uri)
+		req.SetBodyString(body)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		expected := "callback_url is not accepted until webhook secret is configured"
+		if res.StatusCode() != fasthttp.StatusBadRequest || !strings.HasSuffix(string(res.Body()), expected) {
+			t.Errorf("for %s, expected %d %q but got %d %q", uri, fasthttp.StatusBadRequest, expected, res.StatusCode(), res.Body())
+		}
+	}
+	if list, total, _ := jobs.ListJobs(models.JobFilter{Limit: 10}); total != 0 {
+		t.Errorf("expected no jobs but got %+v", list)
+	}
+}
+
+var deliveriesTests = []struct {
+	number             int
+	uri                string
+	expectedOutput     string
+	expectedStatusCode int
+}{
+	{0, "/rest/hash/result/a/deliveries", `{"deliveries":[{"job_id":"a","attempt":1,"url":"http://example.com","sent_at":"2022-01-02T03:04:05Z","status_code":500,"error":"unexpected status 500","delivered":false}]}` + "\n", fasthttp.StatusOK},
+	{1, "/rest/hash/result/b/deliveries", `{"deliveries":[]}` + "\n", fasthttp.StatusOK},
+	{2, "/rest/hash/result/x/deliveries", "job not found", fasthttp.StatusNotFound},
+}
+
+// TestListDeliveries tests ListDeliveries
+func TestListDeliveries(t *testing.T) {
+	jobs := newTestJobs(models.Job{ID: "a"}, models.Job{ID: "b"})
+	jobs.AddDelivery(&models.Delivery{
+		JobID:      "a",
+		Attempt:    1,
+		URL:        "http://example.com",
+		SentAt:     time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+		StatusCode: 500,
+		Error:      "unexpected status 500",
+	})
+	r := NewRouter(&MyServer{db: newTestDB(), redisConn: &testRedis{}, jobs: jobs})
+	ln := fasthttputil.NewInmemoryListener()
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	s := &fasthttp.Server{
+		Handler: r.Handler,
+	}
+	go s.Serve(ln) //nolint:errcheck
+	c := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	req.Header.Set(fasthttp.HeaderAccept, "text/plain")
+	for _, testCase := range deliveriesTests {
+		req.SetRequestURI("http://test.com" + testCase.uri)
+		if err := c.Do(req, res); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode() != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, res.StatusCode())
+		}
+		if body, exp := string(res.Body()), testCase.expectedOutput; body != exp {
+			t.Errorf("for test #%d, expected %q but got %q", testCase.number, exp, body)
+		}
+	}
+}
+
+// TestGenerateHashPushFails tests that job which could not be enqueued is removed without webhook
+func TestGenerateHashPushFails(t *testing.T) {
+	var hooks int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hooks, 1)
+	}))
+	defer receiver.Close()
+	cfg := testConfig()
+	cfg.Webhooks.Secret = "secret"
+	jobs := newTestJobs()
+	server := NewMyServer(newTestDB(), &testRedis{}, jobs, &failingQueue{pushErr: errors.New("broker is down")}, nil, cfg)
+	c, stop := listen(server)
+	defer stop()
+	req, res := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+	}()
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.SetRequestURI("http://test.com/rest/hash/calc")
+	req.SetBodyString(`{"input": "15", "callback_url": "` + receiver.URL + `"}`)
+	if err := c.Do(req, res); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode() != fasthttp.StatusInternalServerError {
+		t.Errorf("expected %d but got %d %q", fasthttp.StatusInternalServerError, res.StatusCode(), res.Body())
+	}
+	server.hooks.running.Wait()
+	if list, total, _ := jobs.ListJobs(models.JobFilter{Limit: 10}); total != 0 {
+		t.Errorf("expected no jobs but got %+v", list)
+	}
+	if n := atomic.LoadInt32(&hooks); n != 0 {
+		t.Errorf("expected no webhooks but got %d", n)
+	}
+}
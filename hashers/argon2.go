@@ -0,0 +1,52 @@
+package hashers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id is Hasher deriving key from input with random salt.
+// Hash is encoded in PHC string format so that it can be verified later.
+type Argon2id struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+	// Salt returns salt of new hash
+	Salt func() ([]byte, error)
+}
+
+// NewArgon2id returns Argon2id with parameters recommended by RFC 9106 for memory constrained environments
+func NewArgon2id() *Argon2id {
+	return &Argon2id{
+		Time:    3,
+		Memory:  64 * 1024,
+		Threads: 4,
+		KeyLen:  32,
+		Salt: func() ([]byte, error) {
+			salt := make([]byte, 16)
+			_, err := rand.Read(salt)
+			return salt, err
+		},
+	}
+}
+
+// Validate limits length of input
+func (a *Argon2id) Validate(input string) error {
+	return validLength(input)
+}
+
+// Hash returns $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>
+func (a *Argon2id) Hash(ctx context.Context, input string, p Params) (string, error) {
+	salt, err := a.Salt()
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(input), salt, a.Time, a.Memory, a.Threads, a.KeyLen)
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, a.Memory, a.Time, a.Threads, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
@@ -0,0 +1,115 @@
+// Package hashers keeps algorithms hash jobs can be computed with
+package hashers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"rest/myerrors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxInput limits length of input hashed by digest algorithms
+const MaxInput = 4096
+
+// Params are settings of hash job, algorithms that compute at once ignore them
+type Params struct {
+	Duration time.Duration
+	Interval time.Duration
+}
+
+// Hasher computes hash of job input
+type Hasher interface {
+	// Validate reports whether input can be hashed, it is called before job is queued
+	Validate(input string) error
+	// Hash returns hash of input as text, computation stops once ctx is done
+	Hash(ctx context.Context, input string, p Params) (string, error)
+}
+
+// Registry maps names of algorithms to their hashers
+type Registry struct {
+	hashers map[string]Hasher
+}
+
+// New returns registry of digest algorithms: sha256, xxhash, fnv64, crc32 and argon2id
+func New() *Registry {
+	r := &Registry{hashers: make(map[string]Hasher)}
+	r.Register("sha256", Digest(func(b []byte) []byte {
+		sum := sha256.Sum256(b)
+		return sum[:]
+	}))
+	r.Register("xxhash", Digest(func(b []byte) []byte {
+		return uint64Bytes(Sum64(b))
+	}))
+	r.Register("fnv64", Digest(func(b []byte) []byte {
+		h := fnv.New64a()
+		h.Write(b)
+		return h.Sum(nil)
+	}))
+	r.Register("crc32", Digest(func(b []byte) []byte {
+		h := crc32.NewIEEE()
+		h.Write(b)
+		return h.Sum(nil)
+	}))
+	r.Register("argon2id", NewArgon2id())
+	return r
+}
+
+// Register adds hasher under name replacing previous one
+func (r *Registry) Register(name string, h Hasher) {
+	r.hashers[name] = h
+}
+
+// Get returns hasher registered under name
+func (r *Registry) Get(name string) (Hasher, error) {
+	h, ok := r.hashers[name]
+	if !ok {
+		return nil, myerrors.ErrInvalidInput.WithDetail("algorithm must be one of " + strings.Join(r.Names(), ", "))
+	}
+	return h, nil
+}
+
+// Names returns sorted names of registered algorithms
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.hashers))
+	for name := range r.hashers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Digest is Hasher of function computing checksum of input at once, hash is its hex encoding
+type Digest func(b []byte) []byte
+
+// Validate limits length of input
+func (d Digest) Validate(input string) error {
+	return validLength(input)
+}
+
+// Hash returns hex encoded checksum of input
+func (d Digest) Hash(ctx context.Context, input string, p Params) (string, error) {
+	return hex.EncodeToString(d([]byte(input))), nil
+}
+
+// validLength reports error if input is longer than MaxInput
+func validLength(input string) error {
+	if len(input) > MaxInput {
+		return myerrors.ErrInvalidInput.WithDetail(fmt.Sprintf("input must not exceed %d bytes", MaxInput))
+	}
+	return nil
+}
+
+// uint64Bytes returns big endian bytes of n
+func uint64Bytes(n uint64) []byte {
+	b := make([]byte, 8)
+	for i := range b {
+		b[i] = byte(n >> (56 - 8*i))
+	}
+	return b
+}
@@ -0,0 +1,91 @@
+package hashers
+
+import (
+	"context"
+	"errors"
+	"rest/myerrors"
+	"strings"
+	"testing"
+)
+
+var digestTests = []struct {
+	number    int
+	algorithm string
+	input     string
+	expected  string
+}{
+	{0, "sha256", "", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	{1, "sha256", "abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	{2, "xxhash", "", "ef46db3751d8e999"},
+	{3, "xxhash", "abc", "44bc2cf5ad770999"},
+	{4, "xxhash", "Nobody inspects the spammish repetition", "fbcea83c8a378bf1"},
+	{5, "fnv64", "", "cbf29ce484222325"},
+	{6, "fnv64", "a", "af63dc4c8601ec8c"},
+	{7, "fnv64", "foobar", "85944171f73967e8"},
+	{8, "crc32", "", "00000000"},
+	{9, "crc32", "123456789", "cbf43926"},
+	{10, "crc32", "The quick brown fox jumps over the lazy dog", "414fa339"},
+}
+
+// TestDigests tests digest algorithms against known vectors
+func TestDigests(t *testing.T) {
+	r := New()
+	for _, testCase := range digestTests {
+		h, err := r.Get(testCase.algorithm)
+		if err != nil {
+			t.Fatalf("for test #%d, unexpected error %v", testCase.number, err)
+		}
+		if err := h.Validate(testCase.input); err != nil {
+			t.Errorf("for test #%d, unexpected error %v", testCase.number, err)
+		}
+		res, err := h.Hash(context.Background(), testCase.input, Params{})
+		if err != nil || res != testCase.expected {
+			t.Errorf("for test #%d, expected %q but got %q, %v", testCase.number, testCase.expected, res, err)
+		}
+	}
+}
+
+// TestArgon2id tests Argon2id against vector of reference implementation
+func TestArgon2id(t *testing.T) {
+	a := &Argon2id{
+		Time:    1,
+		Memory:  64,
+		Threads: 1,
+		KeyLen:  24,
+		Salt: func() ([]byte, error) {
+			return []byte("somesalt"), nil
+		},
+	}
+	res, err := a.Hash(context.Background(), "password", Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// key is hex 655ad15eac652dc59f7170a7332bf49b8469be1fdb9c28bb
+	if exp := "$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ$ZVrRXqxlLcWfcXCnMyv0m4Rpvh/bnCi7"; res != exp {
+		t.Errorf("expected %q but got %q", exp, res)
+	}
+
+	// default salt is random
+	b := NewArgon2id()
+	b.Memory, b.Time = 64, 1
+	first, _ := b.Hash(context.Background(), "password", Params{})
+	second, _ := b.Hash(context.Background(), "password", Params{})
+	if first == second || !strings.HasPrefix(first, "$argon2id$v=19$m=64,t=1,p=4$") {
+		t.Errorf("expected different salted hashes but got %q and %q", first, second)
+	}
+}
+
+// TestRegistry tests lookup of algorithms and input limit
+func TestRegistry(t *testing.T) {
+	r := New()
+	if names := strings.Join(r.Names(), ","); names != "argon2id,crc32,fnv64,sha256,xxhash" {
+		t.Errorf("unexpected algorithms %q", names)
+	}
+	if _, err := r.Get("md5"); !errors.Is(err, myerrors.ErrInvalidInput) {
+		t.Errorf("expected %v but got %v", myerrors.ErrInvalidInput, err)
+	}
+	h, _ := r.Get("sha256")
+	if err := h.Validate(strings.Repeat("a", MaxInput+1)); !errors.Is(err, myerrors.ErrInvalidInput) {
+		t.Errorf("expected %v for long input but got %v", myerrors.ErrInvalidInput, err)
+	}
+}
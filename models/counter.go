@@ -0,0 +1,65 @@
+package models
+
+import (
+	"regexp"
+	"rest/myerrors"
+	"time"
+)
+
+// DefaultCounter is counter changed by unnamed routes /rest/counter/add, /rest/counter/sub and /rest/counter/val
+const DefaultCounter = "default"
+
+// ReservedCounter is name taken by route /rest/counter/val, so no counter can have it
+const ReservedCounter = "val"
+
+// counterNameRe matches names of counters, they never contain ':' used in keys
+var counterNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Counter is named integer kept within its bounds
+// Nil Min or Max means there is no such bound, counters that were never configured have Min 0 and cannot be negative.
+type Counter struct {
+	Name  string
+	Value int64
+	Min   *int64
+	Max   *int64
+	// TTL is how long counter is kept after its last change, zero keeps it forever
+	TTL time.Duration
+}
+
+// NewCounter returns counter at 0 that cannot be negative and expires after ttl
+func NewCounter(name string, ttl time.Duration) Counter {
+	var zero int64
+	return Counter{Name: name, Min: &zero, TTL: ttl}
+}
+
+// ValidCounterName reports whether name has only latin letters, digits, '_' and '-', is at most 64 characters long
+// and is not ReservedCounter
+func ValidCounterName(name string) bool {
+	return name != ReservedCounter && counterNameRe.MatchString(name)
+}
+
+// Check returns error if value v is out of bounds of c
+// myerrors.ErrNegativeCounter is returned for min 0, so that default counter fails the way it always has.
+func (c Counter) Check(v int64) error {
+	if c.Min != nil && v < *c.Min {
+		if *c.Min == 0 {
+			return myerrors.ErrNegativeCounter
+		}
+		return myerrors.ErrCounterBounds
+	}
+	if c.Max != nil && v > *c.Max {
+		return myerrors.ErrCounterBounds
+	}
+	return nil
+}
+
+// Clamp returns value nearest to v within bounds of c
+func (c Counter) Clamp(v int64) int64 {
+	if c.Min != nil && v < *c.Min {
+		return *c.Min
+	}
+	if c.Max != nil && v > *c.Max {
+		return *c.Max
+	}
+	return v
+}
@@ -1,15 +1,98 @@
 package models
 
+import (
+	"context"
+	"time"
+)
+
 type MySQLInterface interface {
 	CreateUser(u *User) (int64, error)
+	// CreateUsers saves all users or none of them, *UserError tells which user was rejected
+	CreateUsers(users []User) error
 	GetUser(ID string) (*User, error)
-	UpdateUser(ID string, u User) error
+	// UpdateUser replaces editable fields of user with those of u and returns updated user.
+	// Non-zero u.Version must match stored version, otherwise myerrors.ErrVersionMismatch is returned.
+	UpdateUser(ID string, u User) (*User, error)
+	// DeleteUser marks user as deleted, RestoreUser brings them back
 	DeleteUser(ID string) error
+	RestoreUser(ID string) error
+	// PurgeUsers permanently removes users deleted before given time and returns their number
+	PurgeUsers(before time.Time) (int64, error)
+	// ListUsers returns page of users matching filter and total number of matching users
+	ListUsers(f UserFilter) ([]User, int, error)
+	// EachUser calls fn for every live user ordered by ID until fn returns error
+	EachUser(fn func(u User) error) error
+	Close() error
 }
 
 type RedisInterface interface {
-	GetCounter() (string, error)
-	SetCounter(n int) (string, error)
+	// GetCounter returns counter by name, missing counter reads as 0 with default bounds
+	GetCounter(name string) (*Counter, error)
+	// SetCounter adds n to counter by name unless it would leave bounds of the counter, see Counter.Check
+	SetCounter(name string, n int) (*Counter, error)
+	// SetCounterBounds replaces Min, Max and TTL of counter c.Name keeping its value.
+	// Missing counter starts at value nearest to 0 within bounds, myerrors.ErrCounterBounds is returned if current value is outside them.
+	SetCounterBounds(c Counter) (*Counter, error)
+	// DelCounter removes counter with its bounds or returns myerrors.ErrCounterNotFound
+	DelCounter(name string) error
+	// ListCounters returns existing counters ordered by name
+	ListCounters() ([]Counter, error)
 	Set(string, interface{}) error
 	Get(string) (string, error)
+	// SetNX sets value of key unless it exists and reports whether it was set
+	SetNX(key string, value interface{}, ttl time.Duration) (bool, error)
+	// SetEX sets value of key expiring after ttl
+	SetEX(key string, value interface{}, ttl time.Duration) error
+	Del(key string) error
+	Close() error
+}
+
+type JobStore interface {
+	CreateJob(j *Job) error
+	UpdateJob(j *Job) error
+	GetJob(ID string) (*Job, error)
+	// DeleteJob removes job with its deliveries, e.g. when it could not be enqueued
+	DeleteJob(ID string) error
+	// ListJobs returns page of jobs matching filter and total number of matching jobs
+	ListJobs(f JobFilter) ([]Job, int, error)
+	// UnfinishedJobs returns queued and running jobs
+	UnfinishedJobs() ([]Job, error)
+	// AddDelivery records attempt to deliver callback of job
+	AddDelivery(d *Delivery) error
+	// ListDeliveries returns delivery attempts of job in order they were made
+	ListDeliveries(jobID string) ([]Delivery, error)
+	// CreateBatch saves batch together with its jobs, either all of them are saved or none
+	CreateBatch(b *Batch, jobs []Job) error
+	// GetBatch retrieves batch and its jobs in order of submission
+	GetBatch(ID string) (*Batch, []Job, error)
+	// DeleteBatch removes batch together with its jobs, e.g. when they could not be enqueued
+	DeleteBatch(b *Batch) error
+	Close() error
+}
+
+// JobEvents fans out job status transitions to every instance of the API
+type JobEvents interface {
+	Publish(j *Job) error
+	// Subscribe returns channel receiving jobs published by every instance, including this one.
+	// Channel is closed by Close.
+	Subscribe() (<-chan Job, error)
+	Close() error
+}
+
+// QueuedJob is a job received from JobQueue
+type QueuedJob struct {
+	ID string
+	// Ack marks job as processed once its result is stored
+	Ack func() error
+}
+
+// JobQueue delivers IDs of queued hash jobs to workers
+type JobQueue interface {
+	// Push adds jobs to the queue in given order.
+	// myerrors.ErrQueueFull is returned if queue has no room for all of them, in which case none is added.
+	Push(ctx context.Context, IDs ...string) error
+	// Pop blocks until job is available.
+	// myerrors.ErrQueueClosed is returned once queue is closed and no jobs are left for this instance.
+	Pop(ctx context.Context) (QueuedJob, error)
+	Close() error
 }
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// JobStatus is the state of hash job
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobDone      JobStatus = "done"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Valid reports whether s is a known status
+func (s JobStatus) Valid() bool {
+	switch s {
+	case JobQueued, JobRunning, JobDone, JobFailed, JobCancelled:
+		return true
+	}
+	return false
+}
+
+// Finished reports whether job with status s will not change anymore
+func (s JobStatus) Finished() bool {
+	return s == JobDone || s == JobFailed || s == JobCancelled
+}
+
+// Job is a request to compute hash
+// Finished job is sent to CallbackURL if it is set
+type Job struct {
+	ID          string     `json:"id"`
+	Input       string     `json:"input"`
+	Algorithm   string     `json:"algorithm"`
+	Duration    Duration   `json:"duration"`
+	Interval    Duration   `json:"interval"`
+	CallbackURL string     `json:"callback_url,omitempty"`
+	Status      JobStatus  `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	// Result is set for jobs computed with bitcount, Digest for other algorithms
+	Result *int   `json:"result,omitempty"`
+	Digest string `json:"digest,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// JobFilter selects page of jobs ordered by creation time
+// Empty status matches jobs in any status
+type JobFilter struct {
+	Status JobStatus
+	Offset int
+	Limit  int
+}
+
+// Batch groups jobs submitted in one request, JobIDs keep order of submission
+type Batch struct {
+	ID        string    `json:"id"`
+	JobIDs    []string  `json:"job_ids"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery is an attempt to send finished job to its callback URL
+type Delivery struct {
+	JobID   string    `json:"job_id"`
+	Attempt int       `json:"attempt"`
+	URL     string    `json:"url"`
+	SentAt  time.Time `json:"sent_at"`
+	// StatusCode is zero if no response was received
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Delivered  bool   `json:"delivered"`
+}
+
+// Duration is time.Duration written in JSON as string, e.g. "1m30s"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
@@ -0,0 +1,172 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"rest/config"
+	"rest/models"
+	"rest/myerrors"
+	"sort"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// reader is the part of kafka.Reader used by Queue
+type reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// writer is the part of kafka.Writer used by Queue
+type writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Queue is JobQueue kept in kafka topic.
+// Instances sharing consumer group split jobs between each other,
+// offset of job is committed once its result is stored so that jobs of crashed instances are redelivered.
+// Workers share one reader and finish jobs in any order,
+// so offset of partition is committed only up to the first job still running.
+type Queue struct {
+	writer writer
+	reader reader
+	// ctx is cancelled by Close to abort commits in flight
+	ctx    context.Context
+	cancel context.CancelFunc
+	// mx guards partitions and serializes commits so that offset of partition never goes back
+	mx         sync.Mutex
+	partitions map[int]*partition
+}
+
+// partition keeps offsets fetched from one partition that are not committed yet
+type partition struct {
+	// fetched are messages in the order of their offsets
+	fetched []kafka.Message
+	// acked are offsets of fetched messages whose jobs are finished
+	acked map[int64]bool
+}
+
+// NewQueue returns queue producing to and consuming from topic from config
+func NewQueue(cfg config.Kafka) (models.JobQueue, error) {
+	conn, err := kafka.Dial("tcp", cfg.Brokers[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Close(); err != nil {
+		return nil, err
+	}
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: cfg.Brokers,
+		GroupID: cfg.Group,
+		Topic:   cfg.Topic,
+	})
+	return newQueue(r, w), nil
+}
+
+// newQueue returns queue using r and w
+func newQueue(r reader, w writer) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		writer:     w,
+		reader:     r,
+		ctx:        ctx,
+		cancel:     cancel,
+		partitions: make(map[int]*partition),
+	}
+}
+
+// Push produces jobs keyed by their IDs in a single write.
+// Kafka does not make writes to several partitions atomic,
+// so callers cancel jobs of failed push to skip those that were produced.
+func (q *Queue) Push(ctx context.Context, IDs ...string) error {
+	msgs := make([]kafka.Message, len(IDs))
+	for i, ID := range IDs {
+		msgs[i] = kafka.Message{Key: []byte(ID), Value: []byte(ID)}
+	}
+	return q.writer.WriteMessages(ctx, msgs...)
+}
+
+// Pop fetches next job assigned to this instance, Ack commits its offset once jobs before it are acknowledged too
+func (q *Queue) Pop(ctx context.Context) (models.QueuedJob, error) {
+	m, err := q.reader.FetchMessage(ctx)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return models.QueuedJob{}, myerrors.ErrQueueClosed
+		}
+		return models.QueuedJob{}, err
+	}
+	q.fetched(m)
+	return models.QueuedJob{
+		ID: string(m.Value),
+		Ack: func() error {
+			return q.ack(m)
+		},
+	}, nil
+}
+
+// fetched starts tracking offset of m
+func (q *Queue) fetched(m kafka.Message) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	p, ok := q.partitions[m.Partition]
+	if !ok {
+		p = &partition{acked: make(map[int64]bool)}
+		q.partitions[m.Partition] = p
+	}
+	// reader starts over from committed offset when partition is assigned again,
+	// messages from there on are redelivered and tracked anew
+	i := sort.Search(len(p.fetched), func(i int) bool {
+		return p.fetched[i].Offset >= m.Offset
+	})
+	for _, f := range p.fetched[i:] {
+		delete(p.acked, f.Offset)
+	}
+	p.fetched = append(p.fetched[:i], m)
+}
+
+// ack marks m as processed and commits the highest offset of its partition before which every job is acknowledged
+func (q *Queue) ack(m kafka.Message) error {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	p, ok := q.partitions[m.Partition]
+	if !ok {
+		return nil
+	}
+	p.acked[m.Offset] = true
+	n := 0
+	for n < len(p.fetched) && p.acked[p.fetched[n].Offset] {
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	if err := q.reader.CommitMessages(q.ctx, p.fetched[n-1]); err != nil {
+		// offsets stay acknowledged and are committed with the next ack
+		return err
+	}
+	for _, f := range p.fetched[:n] {
+		delete(p.acked, f.Offset)
+	}
+	p.fetched = p.fetched[n:]
+	return nil
+}
+
+// Close stops fetching jobs and flushes produced ones
+// Jobs fetched but not acknowledged are redelivered to other instances of the group
+func (q *Queue) Close() error {
+	q.cancel()
+	if err := q.reader.Close(); err != nil {
+		return err
+	}
+	return q.writer.Close()
+}
@@ -0,0 +1,181 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"rest/models"
+	"rest/myerrors"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// testReader is reader returning preset messages and recording commits
+type testReader struct {
+	msgs      []kafka.Message
+	commits   []string
+	commitErr error
+	closed    bool
+}
+
+func (r *testReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if r.closed || len(r.msgs) == 0 {
+		return kafka.Message{}, io.EOF
+	}
+	m := r.msgs[0]
+	r.msgs = r.msgs[1:]
+	return m, nil
+}
+
+func (r *testReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if r.commitErr != nil {
+		return r.commitErr
+	}
+	for _, m := range msgs {
+		r.commits = append(r.commits, string(m.Value))
+	}
+	return nil
+}
+
+func (r *testReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+// testWriter is writer keeping written messages
+type testWriter struct {
+	msgs   []kafka.Message
+	closed bool
+}
+
+func (w *testWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func (w *testWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+// message returns message of job ID at offset of partition
+func message(partition int, offset int64, ID string) kafka.Message {
+	return kafka.Message{Partition: partition, Offset: offset, Key: []byte(ID), Value: []byte(ID)}
+}
+
+// pop pops n jobs from q
+func pop(t *testing.T, q *Queue, n int) []models.QueuedJob {
+	t.Helper()
+	jobs := make([]models.QueuedJob, n)
+	for i := range jobs {
+		qj, err := q.Pop(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobs[i] = qj
+	}
+	return jobs
+}
+
+// TestQueue tests that jobs are pushed keyed by ID, popped in order and Close closes both ends
+func TestQueue(t *testing.T) {
+	r, w := &testReader{msgs: []kafka.Message{message(0, 0, "a")}}, &testWriter{}
+	q := newQueue(r, w)
+	if err := q.Push(context.Background(), "b", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.msgs) != 2 || string(w.msgs[1].Key) != "c" || string(w.msgs[1].Value) != "c" {
+		t.Errorf("unexpected produced messages %+v", w.msgs)
+	}
+	if qj := pop(t, q, 1)[0]; qj.ID != "a" || qj.Ack() != nil {
+		t.Errorf("expected job %q to be popped and acknowledged but got %+v", "a", qj)
+	}
+	if !reflect.DeepEqual(r.commits, []string{"a"}) {
+		t.Errorf("expected commit of %q but got %v", "a", r.commits)
+	}
+	if err := q.Close(); err != nil || !r.closed || !w.closed {
+		t.Errorf("expected reader and writer to be closed but got %v, %v, %v", err, r.closed, w.closed)
+	}
+	if _, err := q.Pop(context.Background()); !errors.Is(err, myerrors.ErrQueueClosed) {
+		t.Errorf("expected %v after Close but got %v", myerrors.ErrQueueClosed, err)
+	}
+}
+
+// TestQueueAckOutOfOrder tests that offset is not committed past jobs which are still running
+func TestQueueAckOutOfOrder(t *testing.T) {
+	r := &testReader{msgs: []kafka.Message{
+		message(0, 10, "a"), message(1, 5, "x"), message(0, 11, "b"), message(0, 12, "c"),
+	}}
+	q := newQueue(r, &testWriter{})
+	jobs := pop(t, q, 4)
+	a, x, b, c := jobs[0], jobs[1], jobs[2], jobs[3]
+	for _, qj := range []models.QueuedJob{c, b} {
+		if err := qj.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(r.commits) != 0 {
+		t.Errorf("expected nothing committed while %q is running but got %v", "a", r.commits)
+	}
+	// other partitions do not wait
+	if err := x.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.commits, []string{"x", "c"}) {
+		t.Errorf("expected commits %v but got %v", []string{"x", "c"}, r.commits)
+	}
+}
+
+// TestQueueAckRetry tests that offsets of failed commit are committed with the next ack
+func TestQueueAckRetry(t *testing.T) {
+	r := &testReader{msgs: []kafka.Message{message(0, 0, "a"), message(0, 1, "b")}}
+	q := newQueue(r, &testWriter{})
+	jobs := pop(t, q, 2)
+	r.commitErr = errors.New("broker is down")
+	if err := jobs[0].Ack(); err != r.commitErr {
+		t.Errorf("expected %v but got %v", r.commitErr, err)
+	}
+	r.commitErr = nil
+	if err := jobs[1].Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.commits, []string{"b"}) {
+		t.Errorf("expected commits %v but got %v", []string{"b"}, r.commits)
+	}
+}
+
+// TestQueueRedelivered tests that messages fetched again after rebalance are tracked from their offset
+func TestQueueRedelivered(t *testing.T) {
+	r := &testReader{msgs: []kafka.Message{message(0, 0, "a"), message(0, 1, "b"), message(0, 1, "b")}}
+	q := newQueue(r, &testWriter{})
+	jobs := pop(t, q, 3)
+	if err := jobs[1].Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if err := jobs[0].Ack(); err != nil {
+		t.Fatal(err)
+	}
+	// ack of the first copy covers the offset, redelivered one is a duplicate of the same job
+	if !reflect.DeepEqual(r.commits, []string{"b"}) {
+		t.Errorf("expected commits %v but got %v", []string{"b"}, r.commits)
+	}
+}
+
+// TestQueueAckAfterClose tests that commits do not outlive the queue
+func TestQueueAckAfterClose(t *testing.T) {
+	r := &testReader{msgs: []kafka.Message{message(0, 0, "a")}}
+	q := newQueue(r, &testWriter{})
+	qj := pop(t, q, 1)[0]
+	q.Close()
+	if err := qj.Ack(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v but got %v", context.Canceled, err)
+	}
+}
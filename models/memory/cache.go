@@ -0,0 +1,271 @@
+package memory
+
+import (
+	"fmt"
+	"rest/models"
+	"rest/myerrors"
+	"rest/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// counter is key of default counter and prefix of keys of other counters, the same as in redis
+	counter = "counter"
+	// sweepInterval is how often values that expired without being accessed again are removed
+	sweepInterval = time.Minute
+)
+
+// Cache is RedisInterface kept in memory of this process, values are lost on exit
+// Expired values are removed when they are next accessed and by a sweep every sweepInterval until Close.
+type Cache struct {
+	mx   sync.Mutex
+	vals map[string]entry
+	// bounds of counters set by SetCounterBounds, value of counter is kept in vals
+	bounds map[string]models.Counter
+	// expiration applies to Set and counter, zero means no expiration
+	expiration time.Duration
+	clock    utils.Clock
+	stop     chan struct{}
+	stopOnce sync.Once
+	// swept is closed once sweep returns
+	swept chan struct{}
+}
+
+// entry is value of key with its expiry time, zero expires means no expiry
+type entry struct {
+	val     string
+	expires time.Time
+}
+
+// NewCache returns empty cache, values set by Set and counters without bounds expire after expiration unless it is zero
+func NewCache(expiration time.Duration) *Cache {
+	return newCache(expiration, utils.RealClock{})
+}
+
+// newCache returns empty cache telling time with clock and starts its sweep
+func newCache(expiration time.Duration, clock utils.Clock) *Cache {
+	c := &Cache{
+		vals:       make(map[string]entry),
+		bounds:     make(map[string]models.Counter),
+		expiration: expiration,
+		clock:      clock,
+		stop:       make(chan struct{}),
+		swept:      make(chan struct{}),
+	}
+	ticker := clock.NewTicker(sweepInterval)
+	go c.sweep(ticker)
+	return c
+}
+
+// sweep removes expired values on every tick until Close
+func (c *Cache) sweep(ticker utils.Ticker) {
+	defer close(c.swept)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C():
+		}
+		c.mx.Lock()
+		for key := range c.vals {
+			c.get(key)
+		}
+		for name := range c.bounds {
+			if _, ok := c.vals[counterKey(name)]; !ok {
+				delete(c.bounds, name)
+			}
+		}
+		c.mx.Unlock()
+	}
+}
+
+// get returns live value of key removing it if expired, c.mx should be held
+func (c *Cache) get(key string) (string, bool) {
+	e, ok := c.vals[key]
+	if ok && !e.expires.IsZero() && !c.clock.Now().Before(e.expires) {
+		delete(c.vals, key)
+		return "", false
+	}
+	return e.val, ok
+}
+
+// set stores value of key expiring after ttl unless it is zero, c.mx should be held
+func (c *Cache) set(key string, value interface{}, ttl time.Duration) {
+	e := entry{val: fmt.Sprint(value)}
+	if ttl > 0 {
+		e.expires = c.clock.Now().Add(ttl)
+	}
+	c.vals[key] = e
+}
+
+// counterKey returns key of counter value, default counter keeps the key it had before counters were named
+func counterKey(name string) string {
+	if name == models.DefaultCounter {
+		return counter
+	}
+	return counter + ":" + name
+}
+
+// counter returns counter by name and reports whether it is stored, c.mx should be held
+// Bounds of counter are dropped once its value expires.
+func (c *Cache) counter(name string) (models.Counter, bool, error) {
+	val, stored := c.get(counterKey(name))
+	if !stored {
+		delete(c.bounds, name)
+		return models.NewCounter(name, c.expiration), false, nil
+	}
+	cnt, ok := c.bounds[name]
+	if !ok {
+		cnt = models.NewCounter(name, c.expiration)
+	}
+	v, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return cnt, true, myerrors.ErrNonNumericCounter
+	}
+	cnt.Value = v
+	return cnt, true, nil
+}
+
+// GetCounter returns counter by name, missing counter reads as 0
+func (c *Cache) GetCounter(name string) (*models.Counter, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	cnt, _, err := c.counter(name)
+	if err != nil {
+		return nil, err
+	}
+	return &cnt, nil
+}
+
+// SetCounter adds n to counter by name keeping it within its bounds
+func (c *Cache) SetCounter(name string, n int) (*models.Counter, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	cnt, _, err := c.counter(name)
+	if err != nil {
+		return nil, err
+	}
+	v := cnt.Value + int64(n)
+	if err := cnt.Check(v); err != nil {
+		return nil, err
+	}
+	cnt.Value = v
+	c.set(counterKey(name), v, cnt.TTL)
+	return &cnt, nil
+}
+
+// SetCounterBounds replaces bounds and TTL of counter b.Name keeping its value
+func (c *Cache) SetCounterBounds(b models.Counter) (*models.Counter, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	cnt, stored, err := c.counter(b.Name)
+	if err != nil {
+		return nil, err
+	}
+	b.Value = b.Clamp(0)
+	if stored {
+		if b.Check(cnt.Value) != nil {
+			return nil, myerrors.ErrCounterBounds
+		}
+		b.Value = cnt.Value
+	}
+	c.bounds[b.Name] = b
+	c.set(counterKey(b.Name), b.Value, b.TTL)
+	return &b, nil
+}
+
+// DelCounter removes counter by name with its bounds
+func (c *Cache) DelCounter(name string) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	_, stored := c.get(counterKey(name))
+	delete(c.vals, counterKey(name))
+	delete(c.bounds, name)
+	if !stored {
+		return myerrors.ErrCounterNotFound
+	}
+	return nil
+}
+
+// ListCounters returns stored counters ordered by name
+func (c *Cache) ListCounters() ([]models.Counter, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	counters := []models.Counter{}
+	for key := range c.vals {
+		name := strings.TrimPrefix(key, counter+":")
+		if key == counter {
+			name = models.DefaultCounter
+		} else if name == key {
+			continue
+		}
+		cnt, stored, err := c.counter(name)
+		if err != nil {
+			return nil, err
+		}
+		if stored {
+			counters = append(counters, cnt)
+		}
+	}
+	sort.Slice(counters, func(i, k int) bool {
+		return counters[i].Name < counters[k].Name
+	})
+	return counters, nil
+}
+
+// Set sets value of key expiring after configured expiration
+func (c *Cache) Set(key string, value interface{}) error {
+	return c.SetEX(key, value, c.expiration)
+}
+
+// Get returns value of key or myerrors.ErrNotFound
+func (c *Cache) Get(key string) (string, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	val, ok := c.get(key)
+	if !ok {
+		return "", myerrors.ErrNotFound
+	}
+	return val, nil
+}
+
+// SetNX sets value of key unless it exists and reports whether it was set
+func (c *Cache) SetNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if _, ok := c.get(key); ok {
+		return false, nil
+	}
+	c.set(key, value, ttl)
+	return true, nil
+}
+
+// SetEX sets value of key expiring after ttl regardless of configured expiration
+func (c *Cache) SetEX(key string, value interface{}, ttl time.Duration) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.set(key, value, ttl)
+	return nil
+}
+
+// Del removes key
+func (c *Cache) Del(key string) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	delete(c.vals, key)
+	return nil
+}
+
+// Close stops the sweep, values stay in memory and still expire when accessed
+func (c *Cache) Close() error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	<-c.swept
+	return nil
+}
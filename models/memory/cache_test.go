@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"rest/models"
+	"rest/models/storetest"
+	"rest/utils"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testClock is utils.Clock whose time is moved by advance and which ticks on demand
+type testClock struct {
+	mx    sync.Mutex
+	now   time.Time
+	ticks chan time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Now(), ticks: make(chan time.Time)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.now
+}
+
+func (c *testClock) NewTicker(d time.Duration) utils.Ticker {
+	return c
+}
+
+func (c *testClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (c *testClock) C() <-chan time.Time {
+	return c.ticks
+}
+
+func (c *testClock) Stop() {}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// TestCache tests that Cache conforms to RedisInterface
+func TestCache(t *testing.T) {
+	storetest.Cache(t, func(t *testing.T, expiration time.Duration) (models.RedisInterface, func(d time.Duration)) {
+		clock := newTestClock()
+		return newCache(expiration, clock), clock.advance
+	})
+}
+
+// TestCacheSweep tests that expired values are removed even if they are never read again
+func TestCacheSweep(t *testing.T) {
+	clock := newTestClock()
+	c := newCache(time.Minute, clock)
+	c.SetEX("idempotency:a", "1", time.Second)
+	c.Set("kept", "2")
+	if _, err := c.SetCounterBounds(models.Counter{Name: "visits", TTL: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(time.Second)
+	// sweep has finished the first tick once it receives the second one
+	clock.ticks <- time.Time{}
+	clock.ticks <- time.Time{}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.vals) != 1 || len(c.bounds) != 0 {
+		t.Errorf("expected only %q to be left but got %v and bounds %v", "kept", c.vals, c.bounds)
+	}
+	if _, ok := c.vals["kept"]; !ok {
+		t.Errorf("expected %q to be kept", "kept")
+	}
+}
@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"rest/models"
+	"rest/myerrors"
+	"sync"
+)
+
+// Queue is JobQueue kept in a buffered channel of this process
+type Queue struct {
+	// mx guards closed so that no job is sent to closed channel
+	// and serializes pushes so that room checked for batch is not taken by others
+	mx     sync.Mutex
+	closed bool
+	jobs   chan string
+}
+
+// NewQueue returns queue holding up to size jobs
+func NewQueue(size int) *Queue {
+	return &Queue{jobs: make(chan string, size)}
+}
+
+// Push adds jobs to the queue without waiting, myerrors.ErrQueueFull is returned if there is no room for all of them
+func (q *Queue) Push(ctx context.Context, IDs ...string) error {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	if q.closed {
+		return myerrors.ErrQueueClosed
+	}
+	// Pop only frees room so sends below never block
+	if cap(q.jobs)-len(q.jobs) < len(IDs) {
+		return myerrors.ErrQueueFull
+	}
+	for _, ID := range IDs {
+		q.jobs <- ID
+	}
+	return nil
+}
+
+// Pop returns next job, jobs left in the queue are still returned after Close
+func (q *Queue) Pop(ctx context.Context) (models.QueuedJob, error) {
+	select {
+	case ID, ok := <-q.jobs:
+		if !ok {
+			return models.QueuedJob{}, myerrors.ErrQueueClosed
+		}
+		return models.QueuedJob{ID: ID, Ack: ack}, nil
+	case <-ctx.Done():
+		return models.QueuedJob{}, ctx.Err()
+	}
+}
+
+// Len returns number of jobs waiting in the queue
+func (q *Queue) Len() int {
+	return len(q.jobs)
+}
+
+// Close stops accepting jobs
+func (q *Queue) Close() error {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	return nil
+}
+
+// ack is a no-op since popped jobs are not kept anywhere
+func ack() error {
+	return nil
+}
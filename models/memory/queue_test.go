@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"rest/myerrors"
+	"testing"
+)
+
+// TestQueue tests that jobs are popped in order and left ones are still popped after Close
+func TestQueue(t *testing.T) {
+	q := NewQueue(3)
+	if err := q.Push(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push(context.Background(), "b", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push(context.Background(), "d"); !errors.Is(err, myerrors.ErrQueueFull) {
+		t.Errorf("expected %v on push to full queue but got %v", myerrors.ErrQueueFull, err)
+	}
+	if qj, err := q.Pop(context.Background()); err != nil || qj.ID != "a" || qj.Ack() != nil {
+		t.Errorf("expected job %q but got %+v, %v", "a", qj, err)
+	}
+	// batch is not split when only part of it fits
+	if err := q.Push(context.Background(), "d", "e"); !errors.Is(err, myerrors.ErrQueueFull) || q.Len() != 2 {
+		t.Errorf("expected %v without pushing batch but got %v and %d jobs", myerrors.ErrQueueFull, err, q.Len())
+	}
+	q.Close()
+	if err := q.Push(context.Background(), "e"); !errors.Is(err, myerrors.ErrQueueClosed) {
+		t.Errorf("expected %v on push to closed queue but got %v", myerrors.ErrQueueClosed, err)
+	}
+	for _, ID := range []string{"b", "c"} {
+		if qj, err := q.Pop(context.Background()); err != nil || qj.ID != ID {
+			t.Errorf("expected job %q but got %+v, %v", ID, qj, err)
+		}
+	}
+	if _, err := q.Pop(context.Background()); !errors.Is(err, myerrors.ErrQueueClosed) {
+		t.Errorf("expected %v once queue is drained but got %v", myerrors.ErrQueueClosed, err)
+	}
+	if err := q.Close(); err != nil {
+		t.Errorf("expected second close to succeed but got %v", err)
+	}
+}
+
+// TestQueuePopCancelled tests that Pop returns once ctx is done
+func TestQueuePopCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewQueue(1).Pop(ctx); err != context.Canceled {
+		t.Errorf("expected %v but got %v", context.Canceled, err)
+	}
+}
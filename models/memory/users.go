@@ -0,0 +1,229 @@
+package memory
+
+import (
+	"math"
+	"rest/models"
+	"rest/myerrors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Users is MySQLInterface kept in memory of this process, users are lost on exit
+// Like in MySQL, name search is case-insensitive and IIN is unique among all users including deleted ones.
+type Users struct {
+	mx     sync.Mutex
+	users  []models.User
+	lastID int64
+}
+
+// NewUsers returns store holding given users as they are, users without version get version 1
+func NewUsers(users ...models.User) *Users {
+	s := &Users{users: append([]models.User{}, users...)}
+	for i := range s.users {
+		if s.users[i].Version == 0 {
+			s.users[i].Version = 1
+		}
+		if s.users[i].ID > s.lastID {
+			s.lastID = s.users[i].ID
+		}
+	}
+	return s
+}
+
+// find returns index of user with ID or -1
+func (s *Users) find(ID string) int {
+	for i, u := range s.users {
+		if strconv.FormatInt(u.ID, 10) == ID {
+			return i
+		}
+	}
+	return -1
+}
+
+// iinTaken reports whether IIN belongs to user other than the one at index skip
+func (s *Users) iinTaken(IIN string, skip int) bool {
+	for i, u := range s.users {
+		if i != skip && IIN != "" && u.IIN == IIN {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateUser saves u setting its ID, version and timestamps
+func (s *Users) CreateUser(u *models.User) (int64, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.create(u)
+}
+
+// create saves u, s.mx should be held
+func (s *Users) create(u *models.User) (int64, error) {
+	if s.iinTaken(u.IIN, -1) {
+		return 0, myerrors.ErrIINTaken
+	}
+	now := time.Now().UTC()
+	s.lastID++
+	u.ID, u.Version, u.CreatedAt, u.UpdatedAt, u.DeletedAt = s.lastID, 1, now, now, nil
+	s.users = append(s.users, *u)
+	return u.ID, nil
+}
+
+// CreateUsers saves all users or none of them
+func (s *Users) CreateUsers(users []models.User) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	saved, lastID := len(s.users), s.lastID
+	for i := range users {
+		if _, err := s.create(&users[i]); err != nil {
+			s.users, s.lastID = s.users[:saved], lastID
+			return &models.UserError{Index: i, Err: err}
+		}
+	}
+	return nil
+}
+
+// GetUser returns live user by ID
+func (s *Users) GetUser(ID string) (*models.User, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	i := s.find(ID)
+	if i < 0 || s.users[i].DeletedAt != nil {
+		return new(models.User), myerrors.ErrUserNotFound
+	}
+	u := s.users[i]
+	return &u, nil
+}
+
+// UpdateUser replaces editable fields of live user, non-zero u.Version must match stored one
+func (s *Users) UpdateUser(ID string, u models.User) (*models.User, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	i := s.find(ID)
+	if i < 0 || s.users[i].DeletedAt != nil {
+		return nil, myerrors.ErrUserNotFound
+	}
+	if u.Version != 0 && u.Version != s.users[i].Version {
+		return nil, myerrors.ErrVersionMismatch
+	}
+	if s.iinTaken(u.IIN, i) {
+		return nil, myerrors.ErrIINTaken
+	}
+	stored := &s.users[i]
+	stored.FirstName, stored.LastName, stored.Email, stored.IIN = u.FirstName, u.LastName, u.Email, u.IIN
+	stored.UpdatedAt = time.Now().UTC()
+	stored.Version++
+	updated := *stored
+	return &updated, nil
+}
+
+// DeleteUser marks live user as deleted
+func (s *Users) DeleteUser(ID string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	i := s.find(ID)
+	if i < 0 || s.users[i].DeletedAt != nil {
+		return myerrors.ErrUserNotFound
+	}
+	now := time.Now().UTC()
+	s.users[i].DeletedAt, s.users[i].UpdatedAt = &now, now
+	s.users[i].Version++
+	return nil
+}
+
+// RestoreUser brings back user deleted by DeleteUser
+func (s *Users) RestoreUser(ID string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	i := s.find(ID)
+	if i < 0 {
+		return myerrors.ErrUserNotFound
+	}
+	if s.users[i].DeletedAt == nil {
+		return myerrors.ErrUserNotDeleted
+	}
+	s.users[i].DeletedAt, s.users[i].UpdatedAt = nil, time.Now().UTC()
+	s.users[i].Version++
+	return nil
+}
+
+// PurgeUsers removes users deleted before given time
+func (s *Users) PurgeUsers(before time.Time) (int64, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	users := s.users[:0]
+	for _, u := range s.users {
+		if u.DeletedAt == nil || !u.DeletedAt.Before(before) {
+			users = append(users, u)
+		}
+	}
+	n := int64(len(s.users) - len(users))
+	s.users = users
+	return n, nil
+}
+
+// ListUsers returns page of users matching filter and total number of matching users
+func (s *Users) ListUsers(f models.UserFilter) ([]models.User, int, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	users := []models.User{}
+	query := strings.ToLower(f.Query)
+	for _, u := range s.users {
+		if (u.DeletedAt != nil) != f.Deleted {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(u.FirstName), query) || strings.HasPrefix(strings.ToLower(u.LastName), query) {
+			users = append(users, u)
+		}
+	}
+	total := len(users)
+	// less reports whether a goes before b in requested order, ties are broken by ID
+	less := func(a, b models.User) bool {
+		if f.Desc {
+			a, b = b, a
+		}
+		ka, kb := strings.ToLower(f.Sort.Key(a)), strings.ToLower(f.Sort.Key(b))
+		if f.Sort == models.SortByID || f.Sort == "" || ka == kb {
+			return a.ID < b.ID
+		}
+		return ka < kb
+	}
+	sort.Slice(users, func(i, k int) bool {
+		return less(users[i], users[k])
+	})
+	if c := f.After; c != nil {
+		after := models.User{ID: c.ID, FirstName: c.Key, LastName: c.Key}
+		i := sort.Search(len(users), func(i int) bool {
+			return less(after, users[i])
+		})
+		users = users[i:]
+	}
+	if f.Offset > len(users) {
+		f.Offset = len(users)
+	}
+	end := f.Offset + f.Limit
+	if end > len(users) {
+		end = len(users)
+	}
+	return users[f.Offset:end], total, nil
+}
+
+// EachUser calls fn for every live user ordered by ID
+// fn is called without holding the lock so it may use the store
+func (s *Users) EachUser(fn func(u models.User) error) error {
+	users, _, _ := s.ListUsers(models.UserFilter{Sort: models.SortByID, Limit: math.MaxInt32})
+	for _, u := range users {
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close does nothing, users stay in memory
+func (s *Users) Close() error {
+	return nil
+}
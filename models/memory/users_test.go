@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"rest/models"
+	"rest/models/storetest"
+	"testing"
+)
+
+// TestUsers tests that Users conforms to MySQLInterface
+func TestUsers(t *testing.T) {
+	storetest.Users(t, func(t *testing.T) models.MySQLInterface {
+		return NewUsers()
+	})
+}
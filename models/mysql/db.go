@@ -2,28 +2,55 @@ package mysql
 
 import (
 	"database/sql"
+	"errors"
 	"log"
+	"rest/config"
 	"rest/models"
+	"rest/models/sqlutil"
 	"rest/myerrors"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 type MySQL struct {
 	db *sql.DB
 }
 
-// NewMySQL return new instance of MySQL
-func NewMySQL() (models.MySQLInterface, error) {
-	db, err := sql.Open("mysql", "tester:secret@tcp(db:3306)/db")
+// NewMySQL return new instance of MySQL built upon provided config
+// Pending schema migrations are applied before it is returned
+func NewMySQL(cfg config.MySQL) (models.MySQLInterface, error) {
+	db, err := open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	m, err := newMigrator(db)
+	if err == nil {
+		_, err = m.Up()
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &MySQL{db: db}, nil
+}
+
+// open connects to database from config waiting for it to come up
+// Timestamps are always parsed into time.Time
+func open(cfg config.MySQL) (*sql.DB, error) {
+	dsn, err := mysql.ParseDSN(cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	dsn.ParseTime = true
+	db, err := sql.Open("mysql", dsn.FormatDSN())
 	if err != nil {
 		return nil, err
 	}
 	log.Println("INFO|Success in opening DB")
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(time.Minute * 5)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 	// db.SetConnMaxIdleTime(time.Minute * 2)
 	start := time.Now()
 	for db.Ping() != nil {
@@ -33,90 +60,147 @@ func NewMySQL() (models.MySQLInterface, error) {
 		}
 	}
 	log.Println("INFO|DB Pong", db.Ping() == nil)
-	return &MySQL{db: db}, nil
+	return db, nil
 }
 
 // CreateUser creates adds record of new user to database and returns their ID
+// Timestamps of u are set to the time of creation, new user starts at version 1
 func (m *MySQL) CreateUser(u *models.User) (int64, error) {
-	insForm, err := m.db.Prepare("INSERT INTO users (firstname, lastname) VALUES(?, ?)")
+	now := time.Now().UTC()
+	res, err := m.db.Exec("INSERT INTO users (firstname, lastname, email, iin, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?)",
+		u.FirstName, u.LastName, sqlutil.NullString(u.Email), sqlutil.NullString(u.IIN), now, now)
 	if err != nil {
-		return 0, err
-	}
-	res, err := insForm.Exec(u.FirstName, u.LastName)
-	if err != nil {
-		return 0, err
+		return 0, userError(err)
 	}
 	ID, err := res.LastInsertId()
 	if err != nil {
 		return 0, err
 	}
+	u.ID, u.Version, u.CreatedAt, u.UpdatedAt = ID, 1, now, now
 	return ID, nil
 }
 
-// GetUser retrieves info on user by ID
-func (m *MySQL) GetUser(ID string) (*models.User, error) {
-	user := new(models.User)
-	err := m.db.QueryRow("SELECT * FROM users WHERE id=?", ID).Scan(&user.ID, &user.FirstName, &user.LastName)
-	if err == sql.ErrNoRows {
-		return user, myerrors.ErrUserNotFound
+// CreateUsers saves users in one transaction setting their IDs and timestamps
+func (m *MySQL) CreateUsers(users []models.User) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
 	}
-	return user, err
+	if err := createUsers(tx, users); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
 
-// UpdateUser updates user by ID to provided input
-func (m *MySQL) UpdateUser(ID string, u models.User) error {
-	tx, err := m.db.Begin()
+// createUsers inserts users within tx
+func createUsers(tx *sql.Tx, users []models.User) error {
+	insert, err := tx.Prepare("INSERT INTO users (firstname, lastname, email, iin, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return err
 	}
-	if firstname := u.FirstName; firstname != "" {
-		res, err := tx.Exec("UPDATE users SET firstname = ? where id = ?", firstname, ID)
+	defer insert.Close()
+	now := time.Now().UTC()
+	for i := range users {
+		u := &users[i]
+		res, err := insert.Exec(u.FirstName, u.LastName, sqlutil.NullString(u.Email), sqlutil.NullString(u.IIN), now, now)
 		if err != nil {
-			tx.Rollback()
+			if err := userError(err); err == myerrors.ErrIINTaken {
+				return &models.UserError{Index: i, Err: err}
+			}
 			return err
 		}
-		rows, err := res.RowsAffected()
-		if err != nil {
-			tx.Rollback()
+		if u.ID, err = res.LastInsertId(); err != nil {
 			return err
 		}
-		if rows == 0 {
-			tx.Rollback()
-			return myerrors.ErrUserNotFound
-		}
+		u.Version, u.CreatedAt, u.UpdatedAt, u.DeletedAt = 1, now, now, nil
 	}
+	return nil
+}
 
-	if lastname := u.LastName; lastname != "" {
-		res, err := tx.Exec("UPDATE users SET lastname = ? where id = ?", lastname, ID)
-		if err != nil {
-			tx.Rollback()
-			return err
-		}
-		rows, err := res.RowsAffected()
-		if err != nil {
-			tx.Rollback()
-			return err
+// GetUser retrieves info on user by ID, deleted users are not found
+func (m *MySQL) GetUser(ID string) (*models.User, error) {
+	user, err := scanUser(m.db.QueryRow("SELECT "+sqlutil.UserColumns+" FROM users WHERE id = ? AND deleted_at IS NULL", ID))
+	if err == sql.ErrNoRows {
+		return new(models.User), myerrors.ErrUserNotFound
+	}
+	return user, err
+}
+
+// UpdateUser replaces user by ID with u and returns user as stored
+// Empty email and IIN are cleared, every update increments version.
+// If u.Version is set, user is updated only if it is still at that version, otherwise ErrVersionMismatch is returned.
+func (m *MySQL) UpdateUser(ID string, u models.User) (*models.User, error) {
+	query := "UPDATE users SET firstname = ?, lastname = ?, email = ?, iin = ?, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL"
+	args := []interface{}{u.FirstName, u.LastName, sqlutil.NullString(u.Email), sqlutil.NullString(u.IIN), time.Now().UTC(), ID}
+	if u.Version != 0 {
+		query, args = query+" AND version = ?", append(args, u.Version)
+	}
+	tx, err := m.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return nil, userError(err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	// version always changes so zero rows means there is no such user or it has other version
+	if rows == 0 {
+		if u.Version == 0 {
+			return nil, myerrors.ErrUserNotFound
 		}
-		if rows == 0 {
-			tx.Rollback()
-			return myerrors.ErrUserNotFound
+		if _, err := m.GetUser(ID); err != nil {
+			return nil, err
 		}
+		return nil, myerrors.ErrVersionMismatch
 	}
-	if err = tx.Commit(); err != nil {
-		tx.Rollback()
-		return err
+	user, err := scanUser(tx.QueryRow("SELECT "+sqlutil.UserColumns+" FROM users WHERE id = ?", ID))
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return user, tx.Commit()
 }
 
-// DeleteUser deletes user by ID
+// DeleteUser marks user by ID as deleted
 // Initial user gets ID of 1
 // ID does not get reset with delete
 func (m *MySQL) DeleteUser(ID string) error {
-	res, err := m.db.Exec("DELETE FROM users WHERE id=?", ID)
-	if err != nil {
+	now := time.Now().UTC()
+	return m.exec("UPDATE users SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL", now, now, ID)
+}
+
+// RestoreUser brings back user deleted by DeleteUser
+func (m *MySQL) RestoreUser(ID string) error {
+	err := m.exec("UPDATE users SET deleted_at = NULL, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL", time.Now().UTC(), ID)
+	if err != myerrors.ErrUserNotFound {
+		return err
+	}
+	if _, err := m.GetUser(ID); err != nil {
 		return err
 	}
+	return myerrors.ErrUserNotDeleted
+}
+
+// PurgeUsers permanently removes users deleted before given time
+func (m *MySQL) PurgeUsers(before time.Time) (int64, error) {
+	res, err := m.db.Exec("DELETE FROM users WHERE deleted_at < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// exec runs statement changing single user, ErrUserNotFound is returned if no row was affected
+func (m *MySQL) exec(query string, args ...interface{}) error {
+	res, err := m.db.Exec(query, args...)
+	if err != nil {
+		return userError(err)
+	}
 	rows, err := res.RowsAffected()
 	if err != nil {
 		return err
@@ -126,3 +210,80 @@ func (m *MySQL) DeleteUser(ID string) error {
 	}
 	return nil
 }
+
+// userError converts violation of unique IIN into ErrIINTaken
+func userError(err error) error {
+	var e *mysql.MySQLError
+	if errors.As(err, &e) && e.Number == errDuplicateEntry {
+		return myerrors.ErrIINTaken
+	}
+	return err
+}
+
+// errDuplicateEntry is returned by MySQL when unique key is violated
+const errDuplicateEntry = 1062
+
+// scanUser reads user from row selected with sqlutil.UserColumns
+func scanUser(row sqlutil.Scanner) (*models.User, error) {
+	var (
+		u          models.User
+		email, iin sql.NullString
+		deleted    sql.NullTime
+	)
+	if err := row.Scan(&u.ID, &u.FirstName, &u.LastName, &email, &iin, &u.Version, &u.CreatedAt, &u.UpdatedAt, &deleted); err != nil {
+		return nil, err
+	}
+	u.Email, u.IIN = email.String, iin.String
+	if deleted.Valid {
+		u.DeletedAt = &deleted.Time
+	}
+	return &u, nil
+}
+
+// ListUsers returns page of users matching filter
+func (m *MySQL) ListUsers(f models.UserFilter) ([]models.User, int, error) {
+	where, args := sqlutil.UserFilter(f)
+	var total int
+	if err := m.db.QueryRow("SELECT COUNT(*) FROM users"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	query, args := sqlutil.UserPage(f)
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	users := []models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, *u)
+	}
+	return users, total, rows.Err()
+}
+
+// EachUser reads live users one by one so that they are never all kept in memory
+func (m *MySQL) EachUser(fn func(u models.User) error) error {
+	rows, err := m.db.Query("SELECT " + sqlutil.UserColumns + " FROM users WHERE deleted_at IS NULL ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(*u); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Close closes database connections
+func (m *MySQL) Close() error {
+	return m.db.Close()
+}
@@ -0,0 +1,262 @@
+package mysql
+
+import (
+	"database/sql"
+	"rest/config"
+	"rest/models"
+	"rest/myerrors"
+)
+
+const jobColumns = "id, input, algorithm, duration, tick_interval, callback_url, status, created_at, started_at, finished_at, result, digest, error"
+
+type JobStore struct {
+	db *sql.DB
+}
+
+// NewJobStore returns job store kept in MySQL
+func NewJobStore(cfg config.MySQL) (models.JobStore, error) {
+	db, err := open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &JobStore{db: db}, nil
+}
+
+// CreateJob saves new job
+func (m *JobStore) CreateJob(j *models.Job) error {
+	_, err := m.db.Exec("INSERT INTO hash_jobs ("+jobColumns+") VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		j.ID, j.Input, j.Algorithm, j.Duration, j.Interval, j.CallbackURL, j.Status, j.CreatedAt, j.StartedAt, j.FinishedAt, j.Result, nullString(j.Digest), nullString(j.Error))
+	return err
+}
+
+// UpdateJob overwrites existing job
+func (m *JobStore) UpdateJob(j *models.Job) error {
+	res, err := m.db.Exec("UPDATE hash_jobs SET input = ?, algorithm = ?, duration = ?, tick_interval = ?, callback_url = ?, status = ?, started_at = ?, finished_at = ?, result = ?, digest = ?, error = ? WHERE id = ?",
+		j.Input, j.Algorithm, j.Duration, j.Interval, j.CallbackURL, j.Status, j.StartedAt, j.FinishedAt, j.Result, nullString(j.Digest), nullString(j.Error), j.ID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	// MySQL reports zero rows for updates that change nothing
+	if rows == 0 {
+		if _, err := m.GetJob(j.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetJob retrieves job by ID
+func (m *JobStore) GetJob(ID string) (*models.Job, error) {
+	j, err := scanJob(m.db.QueryRow("SELECT "+jobColumns+" FROM hash_jobs WHERE id = ?", ID))
+	if err == sql.ErrNoRows {
+		return nil, myerrors.ErrJobNotFound
+	}
+	return j, err
+}
+
+// DeleteJob removes job and its deliveries in one transaction
+func (m *JobStore) DeleteJob(ID string) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, query := range []string{
+		"DELETE FROM hash_job_deliveries WHERE job_id = ?",
+		"DELETE FROM hash_jobs WHERE id = ?",
+	} {
+		if _, err := tx.Exec(query, ID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListJobs returns page of jobs matching filter
+func (m *JobStore) ListJobs(f models.JobFilter) ([]models.Job, int, error) {
+	where, args := "", []interface{}{}
+	if f.Status != "" {
+		where, args = " WHERE status = ?", append(args, f.Status)
+	}
+	var total int
+	if err := m.db.QueryRow("SELECT COUNT(*) FROM hash_jobs"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	jobs, err := m.query("SELECT "+jobColumns+" FROM hash_jobs"+where+" ORDER BY created_at, id LIMIT ? OFFSET ?", append(args, f.Limit, f.Offset)...)
+	return jobs, total, err
+}
+
+// UnfinishedJobs returns queued and running jobs
+func (m *JobStore) UnfinishedJobs() ([]models.Job, error) {
+	return m.query("SELECT "+jobColumns+" FROM hash_jobs WHERE status IN (?, ?) ORDER BY created_at, id", models.JobQueued, models.JobRunning)
+}
+
+// AddDelivery records attempt to deliver callback of job
+func (m *JobStore) AddDelivery(d *models.Delivery) error {
+	_, err := m.db.Exec("INSERT INTO hash_job_deliveries (job_id, attempt, url, sent_at, status_code, error, delivered) VALUES(?, ?, ?, ?, ?, ?, ?)",
+		d.JobID, d.Attempt, d.URL, d.SentAt, d.StatusCode, nullString(d.Error), d.Delivered)
+	return err
+}
+
+// ListDeliveries returns delivery attempts of job
+func (m *JobStore) ListDeliveries(jobID string) ([]models.Delivery, error) {
+	rows, err := m.db.Query("SELECT job_id, attempt, url, sent_at, status_code, error, delivered FROM hash_job_deliveries WHERE job_id = ? ORDER BY id", jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := []models.Delivery{}
+	for rows.Next() {
+		var (
+			d      models.Delivery
+			errMsg sql.NullString
+		)
+		if err := rows.Scan(&d.JobID, &d.Attempt, &d.URL, &d.SentAt, &d.StatusCode, &errMsg, &d.Delivered); err != nil {
+			return nil, err
+		}
+		d.Error = errMsg.String
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// CreateBatch saves batch and its jobs in one transaction
+func (m *JobStore) CreateBatch(b *models.Batch, jobs []models.Job) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := createBatch(tx, b, jobs); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// createBatch inserts batch, its jobs and their positions within tx
+func createBatch(tx *sql.Tx, b *models.Batch, jobs []models.Job) error {
+	if _, err := tx.Exec("INSERT INTO hash_batches (id, created_at) VALUES(?, ?)", b.ID, b.CreatedAt); err != nil {
+		return err
+	}
+	insertJob, err := tx.Prepare("INSERT INTO hash_jobs (" + jobColumns + ") VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertJob.Close()
+	insertPosition, err := tx.Prepare("INSERT INTO hash_batch_jobs (batch_id, position, job_id) VALUES(?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertPosition.Close()
+	for i, j := range jobs {
+		if _, err := insertJob.Exec(j.ID, j.Input, j.Algorithm, j.Duration, j.Interval, j.CallbackURL, j.Status, j.CreatedAt, j.StartedAt, j.FinishedAt, j.Result, nullString(j.Digest), nullString(j.Error)); err != nil {
+			return err
+		}
+		if _, err := insertPosition.Exec(b.ID, i, j.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetBatch retrieves batch and its jobs ordered by position
+func (m *JobStore) GetBatch(ID string) (*models.Batch, []models.Job, error) {
+	b := &models.Batch{ID: ID, JobIDs: []string{}}
+	err := m.db.QueryRow("SELECT created_at FROM hash_batches WHERE id = ?", ID).Scan(&b.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil, myerrors.ErrBatchNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	jobs, err := m.query("SELECT "+jobColumns+" FROM hash_jobs JOIN hash_batch_jobs ON job_id = id WHERE batch_id = ? ORDER BY position", ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, j := range jobs {
+		b.JobIDs = append(b.JobIDs, j.ID)
+	}
+	return b, jobs, nil
+}
+
+// DeleteBatch removes batch, its jobs and their deliveries in one transaction
+func (m *JobStore) DeleteBatch(b *models.Batch) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, query := range []string{
+		"DELETE FROM hash_job_deliveries WHERE job_id IN (SELECT job_id FROM hash_batch_jobs WHERE batch_id = ?)",
+		"DELETE FROM hash_jobs WHERE id IN (SELECT job_id FROM hash_batch_jobs WHERE batch_id = ?)",
+		"DELETE FROM hash_batch_jobs WHERE batch_id = ?",
+		"DELETE FROM hash_batches WHERE id = ?",
+	} {
+		if _, err := tx.Exec(query, b.ID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Close closes database connections
+func (m *JobStore) Close() error {
+	return m.db.Close()
+}
+
+// query returns all jobs selected by query
+func (m *JobStore) query(query string, args ...interface{}) ([]models.Job, error) {
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	jobs := []models.Job{}
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *j)
+	}
+	return jobs, rows.Err()
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanJob reads job from row selected with jobColumns
+func scanJob(row scanner) (*models.Job, error) {
+	var (
+		j                 models.Job
+		started, finished sql.NullTime
+		result            sql.NullInt64
+		digest, errMsg    sql.NullString
+	)
+	if err := row.Scan(&j.ID, &j.Input, &j.Algorithm, &j.Duration, &j.Interval, &j.CallbackURL, &j.Status, &j.CreatedAt, &started, &finished, &result, &digest, &errMsg); err != nil {
+		return nil, err
+	}
+	if started.Valid {
+		j.StartedAt = &started.Time
+	}
+	if finished.Valid {
+		j.FinishedAt = &finished.Time
+	}
+	if result.Valid {
+		n := int(result.Int64)
+		j.Result = &n
+	}
+	j.Digest, j.Error = digest.String, errMsg.String
+	return &j, nil
+}
+
+// nullString stores empty strings as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
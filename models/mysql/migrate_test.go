@@ -0,0 +1,169 @@
+package mysql
+
+import (
+	"database/sql"
+	"os"
+	"rest/config"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// TestMigrations tests that embedded migrations load and every script has statements
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+	for _, m := range migrations {
+		if len(splitStatements(m.Up)) == 0 || len(splitStatements(m.Down)) == 0 {
+			t.Errorf("migration %s has empty script", m)
+		}
+	}
+	if got := migrations[0].String(); got != "0001_create_users" {
+		t.Errorf("expected first migration %q but got %q", "0001_create_users", got)
+	}
+}
+
+var loadMigrationsTests = []struct {
+	number        int
+	files         []string
+	expectedError string
+}{
+	{0, []string{"0001_a.up.sql", "0001_a.down.sql", "0002_b.up.sql", "0002_b.down.sql"}, ""},
+	{1, []string{"0001_a.up.sql"}, "migration 0001_a needs both up and down statements"},
+	{2, []string{"0001_a.up.sql", "0001_a.down.sql", "0003_c.up.sql", "0003_c.down.sql"}, "migration 2 is missing"},
+	{3, []string{"0001_a.up.sql", "0001_b.down.sql"}, "migration 1 is named both a and b"},
+	{4, []string{"0001_a.up.sql", "0001_a.down.sql", "readme.md"}, "unexpected migration file readme.md"},
+	{5, []string{"01_a.up.sql", "1_a.up.sql"}, "duplicate migration 1_a.up.sql"},
+}
+
+// TestLoadMigrations tests that broken sets of migration files are rejected
+func TestLoadMigrations(t *testing.T) {
+	for _, testCase := range loadMigrationsTests {
+		fsys := fstest.MapFS{}
+		for _, name := range testCase.files {
+			fsys["m/"+name] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+		}
+		migrations, err := loadMigrations(fsys, "m")
+		if testCase.expectedError == "" {
+			if err != nil || len(migrations) != len(testCase.files)/2 {
+				t.Errorf("for test #%d, unexpected migrations %v, %v", testCase.number, migrations, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != testCase.expectedError {
+			t.Errorf("for test #%d, expected error %q but got %v", testCase.number, testCase.expectedError, err)
+		}
+	}
+}
+
+var splitStatementsTests = []struct {
+	number         int
+	script         string
+	expectedOutput string
+}{
+	{0, "SELECT 1;", "SELECT 1"},
+	{1, "-- comment\nCREATE TABLE t\n(\n    id int\n);\n\nDROP TABLE u;\n", "CREATE TABLE t\n(\n    id int\n)|DROP TABLE u"},
+	{2, "INSERT INTO t VALUES ('a;b');\nSELECT 2", "INSERT INTO t VALUES ('a;b')|SELECT 2"},
+	{3, "\n\n", ""},
+}
+
+// TestSplitStatements tests splitting of migration scripts
+func TestSplitStatements(t *testing.T) {
+	for _, testCase := range splitStatementsTests {
+		if got := strings.Join(splitStatements(testCase.script), "|"); got != testCase.expectedOutput {
+			t.Errorf("for test #%d, expected %q but got %q", testCase.number, testCase.expectedOutput, got)
+		}
+	}
+}
+
+// baselineUsers is users table created by init.sql before migrations were introduced
+const baselineUsers = `CREATE TABLE users
+(
+    id bigint auto_increment,
+    firstname varchar(255) NOT NULL,
+    lastname varchar(255) NOT NULL,
+    PRIMARY KEY (id)
+)`
+
+// TestMigrateBaseline tests that migrations upgrade users table of deployment created before them
+// It runs only when REST_TEST_MYSQL_DSN points to database that may be wiped, e.g. one from docker-compose
+func TestMigrateBaseline(t *testing.T) {
+	dsn := os.Getenv("REST_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("REST_TEST_MYSQL_DSN is not set")
+	}
+	db, err := open(config.MySQL{DSN: dsn, MaxOpenConns: 1, MaxIdleConns: 1, ConnMaxLifetime: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := newMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	for _, stmt := range []string{
+		"DROP TABLE IF EXISTS schema_migrations, users, hash_jobs, hash_job_deliveries, hash_batches, hash_batch_jobs",
+		baselineUsers,
+		"INSERT INTO users (firstname, lastname) VALUES ('Ann', 'Lee')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	done, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != len(m.migrations) {
+		t.Errorf("expected %d migrations applied but got %d", len(m.migrations), len(done))
+	}
+	expected := "id firstname lastname version email iin created_at updated_at deleted_at"
+	if got := strings.Join(columns(t, db, "users"), " "); got != expected {
+		t.Errorf("expected columns %q but got %q", expected, got)
+	}
+	var (
+		version   int64
+		createdAt time.Time
+		deletedAt sql.NullTime
+	)
+	if err := db.QueryRow("SELECT version, created_at, deleted_at FROM users WHERE firstname = 'Ann'").Scan(&version, &createdAt, &deletedAt); err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 || createdAt.IsZero() || deletedAt.Valid {
+		t.Errorf("unexpected existing user version %d, created %v, deleted %v", version, createdAt, deletedAt)
+	}
+	if _, err := m.Down(len(m.migrations) - 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(columns(t, db, "users"), " "); got != "id firstname lastname" {
+		t.Errorf("expected baseline columns after reverting but got %q", got)
+	}
+}
+
+// columns returns column names of table in their order
+func columns(t *testing.T, db *sql.DB, table string) []string {
+	t.Helper()
+	rows, err := db.Query("SELECT column_name FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? ORDER BY ordinal_position", table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
@@ -4,4 +4,4 @@ CREATE TABLE IF NOT EXISTS `users`
     firstname varchar(255) NOT NULL,
     lastname varchar(255) NOT NULL,
     PRIMARY KEY (`id`)
-);
\ No newline at end of file
+);
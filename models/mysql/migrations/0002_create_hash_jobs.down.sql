@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `hash_job_deliveries`;
+DROP TABLE IF EXISTS `hash_jobs`;
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS `hash_jobs`
+(
+    id varchar(36) NOT NULL,
+    input text NOT NULL,
+    algorithm varchar(16) NOT NULL DEFAULT 'bitcount',
+    duration bigint NOT NULL DEFAULT 0,
+    tick_interval bigint NOT NULL DEFAULT 0,
+    callback_url varchar(2048) NOT NULL DEFAULT '',
+    status varchar(16) NOT NULL,
+    created_at datetime(6) NOT NULL,
+    started_at datetime(6) NULL,
+    finished_at datetime(6) NULL,
+    result int NULL,
+    digest varchar(255) NULL,
+    error text NULL,
+    PRIMARY KEY (`id`),
+    INDEX (`status`, `created_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `hash_job_deliveries`
+(
+    id bigint NOT NULL AUTO_INCREMENT,
+    job_id varchar(36) NOT NULL,
+    attempt int NOT NULL,
+    url varchar(2048) NOT NULL,
+    sent_at datetime(6) NOT NULL,
+    status_code int NOT NULL DEFAULT 0,
+    error text NULL,
+    delivered boolean NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (`id`),
+    INDEX (`job_id`)
+);
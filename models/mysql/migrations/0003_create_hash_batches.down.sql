@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `hash_batch_jobs`;
+DROP TABLE IF EXISTS `hash_batches`;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `hash_batches`
+(
+    id varchar(36) NOT NULL,
+    created_at datetime(6) NOT NULL,
+    PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `hash_batch_jobs`
+(
+    batch_id varchar(36) NOT NULL,
+    position int NOT NULL,
+    job_id varchar(36) NOT NULL,
+    PRIMARY KEY (`batch_id`, `position`)
+);
@@ -0,0 +1 @@
+ALTER TABLE `users` DROP INDEX `firstname`, DROP INDEX `lastname`;
@@ -0,0 +1 @@
+ALTER TABLE `users` ADD INDEX `firstname` (`firstname`), ADD INDEX `lastname` (`lastname`);
@@ -0,0 +1 @@
+ALTER TABLE `users` ADD COLUMN email varchar(255) NULL;
@@ -0,0 +1 @@
+ALTER TABLE `users` ADD COLUMN iin char(12) NULL, ADD UNIQUE INDEX `iin` (`iin`);
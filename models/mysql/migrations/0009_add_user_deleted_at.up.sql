@@ -0,0 +1 @@
+ALTER TABLE `users` ADD COLUMN deleted_at datetime(6) NULL, ADD INDEX `deleted_at` (`deleted_at`);
@@ -0,0 +1,29 @@
+package mysql
+
+import (
+	"os"
+	"rest/config"
+	"rest/models"
+	"rest/models/storetest"
+	"testing"
+	"time"
+)
+
+// TestMySQL tests that MySQL conforms to MySQLInterface shared with other user stores
+// It runs only when REST_TEST_MYSQL_DSN points to database that may be wiped, e.g. one from docker-compose
+func TestMySQL(t *testing.T) {
+	dsn := os.Getenv("REST_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("REST_TEST_MYSQL_DSN is not set")
+	}
+	storetest.Users(t, func(t *testing.T) models.MySQLInterface {
+		db, err := NewMySQL(config.MySQL{DSN: dsn, MaxOpenConns: 5, MaxIdleConns: 5, ConnMaxLifetime: time.Minute})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.(*MySQL).db.Exec("TRUNCATE TABLE users"); err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
@@ -0,0 +1,258 @@
+package redis
+
+import (
+	"fmt"
+	"rest/models"
+	"rest/myerrors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	// counter is key of default counter and prefix of keys of other counters
+	counter = "counter"
+	// counterBounds prefixes hashes holding min, max and ttl in milliseconds of configured counters
+	counterBounds = "counter_bounds:"
+	// counterNames is set of names of counters, names of expired counters are removed by ListCounters
+	counterNames = "counters"
+)
+
+// counterKey returns key of counter value, default counter keeps the key it had before counters were named
+func counterKey(name string) string {
+	if name == models.DefaultCounter {
+		return counter
+	}
+	return counter + ":" + name
+}
+
+// counterKeys returns KEYS of counter scripts
+func counterKeys(name string) []string {
+	return []string{counterKey(name), counterBounds + name, counterNames}
+}
+
+// readBounds sets min, max and ttl of counter in KEYS[2], counter that was never configured has min 0 and ttl ARGV[1]
+const readBounds = `
+local min, max, ttl = '0', '', ARGV[1]
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	min = redis.call('HGET', KEYS[2], 'min') or ''
+	max = redis.call('HGET', KEYS[2], 'max') or ''
+	ttl = redis.call('HGET', KEYS[2], 'ttl') or '0'
+end
+`
+
+// expireCounter makes value and bounds of counter expire after ttl milliseconds, zero ttl keeps them forever
+const expireCounter = `
+for _, key in ipairs({KEYS[1], KEYS[2]}) do
+	if tonumber(ttl) > 0 then
+		redis.call('PEXPIRE', key, ttl)
+	else
+		redis.call('PERSIST', key)
+	end
+end
+`
+
+// getCounter returns value, min, max and ttl of counter, value is empty if counter does not exist
+var getCounter = redis.NewScript(readBounds + `
+local value = redis.call('GET', KEYS[1]) or ''
+if value ~= '' and not string.match(value, '^-?%d+$') then
+	return {err = 'non_numeric_counter'}
+end
+return {value, min, max, ttl}
+`)
+
+// addCounter adds ARGV[2] to counter named ARGV[3] unless it would leave its bounds.
+// Redis runs script atomically, so concurrent additions are not lost even when made by different instances of the API.
+// Bounds left by deleted value are dropped. Errors are codes of myerrors.
+var addCounter = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if not value then
+	redis.call('DEL', KEYS[2])
+	value = '0'
+end
+if not string.match(value, '^-?%d+$') then
+	return {err = 'non_numeric_counter'}
+end
+` + readBounds + `
+local res = tonumber(value) + tonumber(ARGV[2])
+if min ~= '' and res < tonumber(min) then
+	if tonumber(min) == 0 then
+		return {err = 'negative_counter'}
+	end
+	return {err = 'counter_out_of_bounds'}
+end
+if max ~= '' and res > tonumber(max) then
+	return {err = 'counter_out_of_bounds'}
+end
+value = redis.call('INCRBY', KEYS[1], ARGV[2])
+redis.call('SADD', KEYS[3], ARGV[3])
+` + expireCounter + `
+return {value, min, max, ttl}
+`)
+
+// setBounds replaces bounds of counter named ARGV[5] with min ARGV[2], max ARGV[3] and ttl ARGV[1], empty bound means there is none
+// Missing counter starts at ARGV[4].
+var setBounds = redis.NewScript(`
+local value = redis.call('GET', KEYS[1]) or ARGV[4]
+if not string.match(value, '^-?%d+$') then
+	return {err = 'non_numeric_counter'}
+end
+if ARGV[2] ~= '' and tonumber(value) < tonumber(ARGV[2]) or ARGV[3] ~= '' and tonumber(value) > tonumber(ARGV[3]) then
+	return {err = 'counter_out_of_bounds'}
+end
+local ttl = ARGV[1]
+redis.call('SET', KEYS[1], value)
+redis.call('DEL', KEYS[2])
+redis.call('HSET', KEYS[2], 'ttl', ttl)
+if ARGV[2] ~= '' then
+	redis.call('HSET', KEYS[2], 'min', ARGV[2])
+end
+if ARGV[3] ~= '' then
+	redis.call('HSET', KEYS[2], 'max', ARGV[3])
+end
+redis.call('SADD', KEYS[3], ARGV[5])
+` + expireCounter + `
+return {value, ARGV[2], ARGV[3], ttl}
+`)
+
+// GetCounter returns counter by name, missing counter reads as 0
+func (r *RedisCache) GetCounter(name string) (*models.Counter, error) {
+	cnt, err := r.getCounter(name)
+	if err != nil {
+		return nil, err
+	}
+	if cnt == nil {
+		c := models.NewCounter(name, r.expiration)
+		cnt = &c
+	}
+	return cnt, nil
+}
+
+// getCounter returns counter by name or nil if it does not exist
+func (r *RedisCache) getCounter(name string) (*models.Counter, error) {
+	reply, err := getCounter.Run(r.redisConn, counterKeys(name)[:2], r.expiration.Milliseconds()).Result()
+	if err != nil {
+		return nil, counterError(err)
+	}
+	if vals, ok := reply.([]interface{}); ok && len(vals) != 0 && vals[0] == "" {
+		return nil, nil
+	}
+	return counterReply(name, reply)
+}
+
+// SetCounter adds n to counter by name keeping it within its bounds
+func (r *RedisCache) SetCounter(name string, n int) (*models.Counter, error) {
+	reply, err := addCounter.Run(r.redisConn, counterKeys(name), r.expiration.Milliseconds(), n, name).Result()
+	if err != nil {
+		return nil, counterError(err)
+	}
+	return counterReply(name, reply)
+}
+
+// SetCounterBounds replaces bounds and TTL of counter b.Name keeping its value
+func (r *RedisCache) SetCounterBounds(b models.Counter) (*models.Counter, error) {
+	reply, err := setBounds.Run(r.redisConn, counterKeys(b.Name),
+		b.TTL.Milliseconds(), bound(b.Min), bound(b.Max), b.Clamp(0), b.Name).Result()
+	if err != nil {
+		return nil, counterError(err)
+	}
+	return counterReply(b.Name, reply)
+}
+
+// DelCounter removes counter by name with its bounds
+func (r *RedisCache) DelCounter(name string) error {
+	var del *redis.IntCmd
+	keys := counterKeys(name)
+	_, err := r.redisConn.TxPipelined(func(pipe redis.Pipeliner) error {
+		del = pipe.Del(keys[0])
+		pipe.Del(keys[1])
+		pipe.SRem(counterNames, name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if del.Val() == 0 {
+		return myerrors.ErrCounterNotFound
+	}
+	return nil
+}
+
+// ListCounters returns existing counters ordered by name, names of expired counters are removed on the way
+func (r *RedisCache) ListCounters() ([]models.Counter, error) {
+	names, err := r.redisConn.SMembers(counterNames).Result()
+	if err != nil {
+		return nil, err
+	}
+	// default counter may predate the set
+	names = append(names, models.DefaultCounter)
+	sort.Strings(names)
+	counters := []models.Counter{}
+	for i, name := range names {
+		if i > 0 && name == names[i-1] {
+			continue
+		}
+		cnt, err := r.getCounter(name)
+		if err != nil {
+			return nil, err
+		}
+		if cnt == nil {
+			if err := r.redisConn.SRem(counterNames, name).Err(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		counters = append(counters, *cnt)
+	}
+	return counters, nil
+}
+
+// counterError converts errors returned by counter scripts into errors of myerrors
+func counterError(err error) error {
+	for _, e := range []*myerrors.Error{myerrors.ErrNonNumericCounter, myerrors.ErrNegativeCounter, myerrors.ErrCounterBounds} {
+		if err.Error() == e.Code {
+			return e
+		}
+	}
+	return err
+}
+
+// counterReply converts {value, min, max, ttl} returned by counter scripts into counter, empty bound means there is none
+func counterReply(name string, reply interface{}) (*models.Counter, error) {
+	vals, ok := reply.([]interface{})
+	if !ok || len(vals) != 4 {
+		return nil, fmt.Errorf("unexpected reply of counter script: %v", reply)
+	}
+	var fields [4]*int64
+	for i, v := range vals {
+		s := fmt.Sprint(v)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected reply of counter script: %v", reply)
+		}
+		fields[i] = &n
+	}
+	if fields[0] == nil || fields[3] == nil {
+		return nil, fmt.Errorf("unexpected reply of counter script: %v", reply)
+	}
+	return &models.Counter{
+		Name:  name,
+		Value: *fields[0],
+		Min:   fields[1],
+		Max:   fields[2],
+		TTL:   time.Duration(*fields[3]) * time.Millisecond,
+	}, nil
+}
+
+// bound returns argument of setBounds for bound, nil bound is empty
+func bound(b *int64) string {
+	if b == nil {
+		return ""
+	}
+	return strconv.FormatInt(*b, 10)
+}
@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"encoding/json"
+	"log"
+	"rest/config"
+	"rest/models"
+
+	"github.com/go-redis/redis"
+)
+
+// eventsChannel is the pub/sub channel job status transitions are published to
+const eventsChannel = "job_events"
+
+type JobEvents struct {
+	redisConn *redis.Client
+	pubsub    *redis.PubSub
+}
+
+// NewJobEvents returns job events fanned out with redis pub/sub
+func NewJobEvents(cfg config.Redis) (models.JobEvents, error) {
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &JobEvents{redisConn: client}, nil
+}
+
+// Publish sends job to every subscribed instance
+func (r *JobEvents) Publish(j *models.Job) error {
+	body, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return r.redisConn.Publish(eventsChannel, body).Err()
+}
+
+// Subscribe returns jobs published to events channel
+// Messages that cannot be decoded are skipped
+func (r *JobEvents) Subscribe() (<-chan models.Job, error) {
+	r.pubsub = r.redisConn.Subscribe(eventsChannel)
+	// wait for confirmation so that no message published afterwards is missed
+	if _, err := r.pubsub.Receive(); err != nil {
+		return nil, err
+	}
+	jobs := make(chan models.Job)
+	go func() {
+		defer close(jobs)
+		for msg := range r.pubsub.Channel() {
+			var j models.Job
+			if err := json.Unmarshal([]byte(msg.Payload), &j); err != nil {
+				log.Println("JobEvents err:", err)
+				continue
+			}
+			jobs <- j
+		}
+	}()
+	return jobs, nil
+}
+
+// Close unsubscribes and closes redis client
+func (r *JobEvents) Close() error {
+	if r.pubsub != nil {
+		if err := r.pubsub.Close(); err != nil {
+			return err
+		}
+	}
+	return r.redisConn.Close()
+}
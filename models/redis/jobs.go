@@ -0,0 +1,368 @@
+package redis
+
+import (
+	"encoding/json"
+	"rest/config"
+	"rest/models"
+	"rest/myerrors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	// jobsKey defines sorted set of job IDs scored by creation time
+	jobsKey = "jobs"
+	// statusPrefix prefixes sorted sets of IDs of jobs in each status scored by creation time
+	statusPrefix = "jobs:"
+	// statusesIndexed marks that jobs created before status sets were introduced are added to them
+	statusesIndexed = "jobs_indexed"
+	// jobPrefix prefixes keys under which jobs are stored
+	jobPrefix = "job:"
+	// deliveriesPrefix prefixes lists of callback deliveries of jobs
+	deliveriesPrefix = "deliveries:"
+	// batchPrefix prefixes keys under which batches are stored
+	batchPrefix = "batch:"
+)
+
+// jobStatuses are statuses having sorted sets, updateJob finds set of status by its position
+var jobStatuses = []models.JobStatus{models.JobQueued, models.JobRunning, models.JobDone, models.JobFailed, models.JobCancelled}
+
+// statusKey returns sorted set of jobs in status s
+func statusKey(s models.JobStatus) string {
+	return statusPrefix + string(s)
+}
+
+// statusKeys returns sorted sets of every status in order of jobStatuses
+func statusKeys() []string {
+	keys := make([]string, len(jobStatuses))
+	for i, s := range jobStatuses {
+		keys[i] = statusKey(s)
+	}
+	return keys
+}
+
+// updateJob overwrites job in KEYS[1] with ARGV[1] if it still exists and moves its ID to the set of its status.
+// KEYS[2:] are sets of jobStatuses, ARGV[2] is ttl in milliseconds, ARGV[3] is position of new status,
+// ARGV[4] is ID and ARGV[5] is its score. Returns 0 if job has expired.
+var updateJob = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+if tonumber(ARGV[2]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+for i = 2, #KEYS do
+	if i - 1 == tonumber(ARGV[3]) then
+		redis.call('ZADD', KEYS[i], ARGV[5], ARGV[4])
+	else
+		redis.call('ZREM', KEYS[i], ARGV[4])
+	end
+end
+return 1
+`)
+
+type JobStore struct {
+	redisConn  *redis.Client
+	expiration time.Duration
+}
+
+// NewJobStore returns job store kept in redis
+func NewJobStore(cfg config.Redis) (models.JobStore, error) {
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	r := &JobStore{
+		redisConn:  client,
+		expiration: cfg.Expiration,
+	}
+	if err := r.indexStatuses(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// indexStatuses adds jobs kept before status sets were introduced to the sets of their statuses
+func (r *JobStore) indexStatuses() error {
+	ok, err := r.redisConn.SetNX(statusesIndexed, 1, 0).Result()
+	if err != nil || !ok {
+		return err
+	}
+	all, err := r.jobs(jobsKey, 0, -1)
+	if err != nil || len(all) == 0 {
+		return err
+	}
+	pipe := r.redisConn.TxPipeline()
+	for _, j := range all {
+		pipe.ZAdd(statusKey(j.Status), score(j))
+	}
+	_, err = pipe.Exec()
+	return err
+}
+
+// score returns member of sorted sets of jobs for j
+func score(j models.Job) redis.Z {
+	return redis.Z{Score: float64(j.CreatedAt.UnixNano()), Member: j.ID}
+}
+
+// prune removes IDs of jobs created longer than expiration ago from the sets of all jobs and finished jobs.
+// Unfinished jobs are kept until they change status since they are stored again on every change,
+// those which expire anyway are removed when they are found missing.
+func (r *JobStore) prune(pipe redis.Pipeliner) {
+	if r.expiration <= 0 {
+		return
+	}
+	max := "(" + strconv.FormatInt(time.Now().Add(-r.expiration).UnixNano(), 10)
+	pipe.ZRemRangeByScore(jobsKey, "-inf", max)
+	for _, s := range jobStatuses {
+		if s.Finished() {
+			pipe.ZRemRangeByScore(statusKey(s), "-inf", max)
+		}
+	}
+}
+
+// CreateJob saves new job
+func (r *JobStore) CreateJob(j *models.Job) error {
+	body, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	pipe := r.redisConn.TxPipeline()
+	r.prune(pipe)
+	pipe.Set(jobPrefix+j.ID, body, r.expiration)
+	pipe.ZAdd(jobsKey, score(*j))
+	pipe.ZAdd(statusKey(j.Status), score(*j))
+	_, err = pipe.Exec()
+	return err
+}
+
+// UpdateJob overwrites existing job moving it to the set of its status
+func (r *JobStore) UpdateJob(j *models.Job) error {
+	body, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	position := 0
+	for i, s := range jobStatuses {
+		if s == j.Status {
+			position = i + 1
+		}
+	}
+	z := score(*j)
+	keys := append([]string{jobPrefix + j.ID}, statusKeys()...)
+	ok, err := updateJob.Run(r.redisConn, keys, body, r.expiration.Milliseconds(), position, j.ID, z.Score).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return myerrors.ErrJobNotFound
+	}
+	return nil
+}
+
+// GetJob retrieves job by ID
+func (r *JobStore) GetJob(ID string) (*models.Job, error) {
+	body, err := r.redisConn.Get(jobPrefix + ID).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, myerrors.ErrJobNotFound
+		}
+		return nil, err
+	}
+	j := new(models.Job)
+	return j, json.Unmarshal(body, j)
+}
+
+// DeleteJob removes job and its deliveries in one transaction
+func (r *JobStore) DeleteJob(ID string) error {
+	pipe := r.redisConn.TxPipeline()
+	r.deleteJob(pipe, ID)
+	_, err := pipe.Exec()
+	return err
+}
+
+// ListJobs returns page of jobs matching filter, jobs in given status are read from the set of that status
+func (r *JobStore) ListJobs(f models.JobFilter) ([]models.Job, int, error) {
+	key := jobsKey
+	if f.Status != "" {
+		key = statusKey(f.Status)
+	}
+	pipe := r.redisConn.TxPipeline()
+	r.prune(pipe)
+	total := pipe.ZCard(key)
+	if _, err := pipe.Exec(); err != nil {
+		return nil, 0, err
+	}
+	jobs, err := r.jobs(key, int64(f.Offset), int64(f.Offset+f.Limit-1))
+	return jobs, int(total.Val()), err
+}
+
+// UnfinishedJobs returns queued and running jobs in order of creation
+func (r *JobStore) UnfinishedJobs() ([]models.Job, error) {
+	jobs := []models.Job{}
+	for _, s := range jobStatuses {
+		if s.Finished() {
+			continue
+		}
+		some, err := r.jobs(statusKey(s), 0, -1)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, some...)
+	}
+	sort.SliceStable(jobs, func(i, k int) bool {
+		return jobs[i].CreatedAt.Before(jobs[k].CreatedAt)
+	})
+	return jobs, nil
+}
+
+// AddDelivery appends delivery to the list kept next to job
+func (r *JobStore) AddDelivery(d *models.Delivery) error {
+	body, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	pipe := r.redisConn.TxPipeline()
+	pipe.RPush(deliveriesPrefix+d.JobID, body)
+	if r.expiration > 0 {
+		pipe.Expire(deliveriesPrefix+d.JobID, r.expiration)
+	}
+	_, err = pipe.Exec()
+	return err
+}
+
+// ListDeliveries returns delivery attempts of job
+func (r *JobStore) ListDeliveries(jobID string) ([]models.Delivery, error) {
+	vals, err := r.redisConn.LRange(deliveriesPrefix+jobID, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]models.Delivery, len(vals))
+	for i, v := range vals {
+		if err := json.Unmarshal([]byte(v), &deliveries[i]); err != nil {
+			return nil, err
+		}
+	}
+	return deliveries, nil
+}
+
+// CreateBatch saves batch and its jobs in one transaction
+func (r *JobStore) CreateBatch(b *models.Batch, jobs []models.Job) error {
+	body, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	pipe := r.redisConn.TxPipeline()
+	pipe.Set(batchPrefix+b.ID, body, r.expiration)
+	r.prune(pipe)
+	for _, j := range jobs {
+		body, err := json.Marshal(j)
+		if err != nil {
+			return err
+		}
+		pipe.Set(jobPrefix+j.ID, body, r.expiration)
+		pipe.ZAdd(jobsKey, score(j))
+		pipe.ZAdd(statusKey(j.Status), score(j))
+	}
+	_, err = pipe.Exec()
+	return err
+}
+
+// GetBatch retrieves batch and its jobs in order of submission, expired jobs are skipped
+func (r *JobStore) GetBatch(ID string) (*models.Batch, []models.Job, error) {
+	body, err := r.redisConn.Get(batchPrefix + ID).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil, myerrors.ErrBatchNotFound
+		}
+		return nil, nil, err
+	}
+	b := new(models.Batch)
+	if err := json.Unmarshal(body, b); err != nil {
+		return nil, nil, err
+	}
+	jobs, err := r.load(b.JobIDs)
+	return b, jobs, err
+}
+
+// DeleteBatch removes batch and its jobs with their deliveries in one transaction
+func (r *JobStore) DeleteBatch(b *models.Batch) error {
+	pipe := r.redisConn.TxPipeline()
+	pipe.Del(batchPrefix + b.ID)
+	for _, ID := range b.JobIDs {
+		r.deleteJob(pipe, ID)
+	}
+	_, err := pipe.Exec()
+	return err
+}
+
+// deleteJob removes job with ID from its key, sorted sets and deliveries
+func (r *JobStore) deleteJob(pipe redis.Pipeliner, ID string) {
+	pipe.Del(jobPrefix+ID, deliveriesPrefix+ID)
+	pipe.ZRem(jobsKey, ID)
+	for _, key := range statusKeys() {
+		pipe.ZRem(key, ID)
+	}
+}
+
+// Close closes redis client
+func (r *JobStore) Close() error {
+	return r.redisConn.Close()
+}
+
+// jobs loads jobs in range of sorted set key, expired jobs are skipped and removed from the set
+func (r *JobStore) jobs(key string, start, stop int64) ([]models.Job, error) {
+	IDs, err := r.redisConn.ZRange(key, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := r.load(IDs)
+	if err != nil || len(jobs) == len(IDs) {
+		return jobs, err
+	}
+	loaded := make(map[string]bool, len(jobs))
+	for _, j := range jobs {
+		loaded[j.ID] = true
+	}
+	var expired []interface{}
+	for _, ID := range IDs {
+		if !loaded[ID] {
+			expired = append(expired, ID)
+		}
+	}
+	return jobs, r.redisConn.ZRem(key, expired...).Err()
+}
+
+// load loads jobs with IDs keeping their order, expired jobs are skipped
+func (r *JobStore) load(IDs []string) ([]models.Job, error) {
+	jobs := []models.Job{}
+	if len(IDs) == 0 {
+		return jobs, nil
+	}
+	keys := make([]string, len(IDs))
+	for i, ID := range IDs {
+		keys[i] = jobPrefix + ID
+	}
+	vals, err := r.redisConn.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vals {
+		body, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var j models.Job
+		if err := json.Unmarshal([]byte(body), &j); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
@@ -2,76 +2,46 @@ package redis
 
 import (
 	"fmt"
+	"rest/config"
 	"rest/models"
 	"rest/myerrors"
-	"strconv"
-	"sync"
 	"time"
 
 	"github.com/go-redis/redis"
 )
 
-// counter defines key under which counter is stored in redis
-const counter = "counter"
-
 type RedisCache struct {
 	redisConn  *redis.Client
 	expiration time.Duration
-	mx         *sync.Mutex
 }
 
-// NewredisCache returns new redis client built upon expiration time passed.
-// Users should pass in zero to indicate no expiration time.
-func NewRedisCache(exp time.Duration) (models.RedisInterface, error) {
-
-	client := redis.NewClient(&redis.Options{
-		Addr:     "redis:6379",
-		Password: "",
-		DB:       0,
-	})
-	pong, err := client.Ping().Result()
+// NewRedisCache returns new redis client built upon provided config.
+// Zero expiration time indicates no expiration.
+func NewRedisCache(cfg config.Redis) (models.RedisInterface, error) {
+	client, err := newClient(cfg)
 	if err != nil {
 		return nil, err
 	}
-
-	fmt.Println(pong)
 	return &RedisCache{
 		redisConn:  client,
-		expiration: exp,
-		mx:         &sync.Mutex{},
+		expiration: cfg.Expiration,
 	}, nil
 }
 
-// GetCounter retrieves current value of counter
-func (r *RedisCache) GetCounter() (string, error) {
-	value, err := r.redisConn.Get(counter).Result()
+// newClient returns redis client connected to the server from config
+func newClient(cfg config.Redis) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	pong, err := client.Ping().Result()
 	if err != nil {
-		if err == redis.Nil {
-			return "0", r.redisConn.Set(counter, "0", r.expiration).Err()
-		}
-		return "", err
+		return nil, err
 	}
-	return value, nil
-}
 
-// SetCounter increments counter by value passed as argument
-func (r *RedisCache) SetCounter(n int) (string, error) {
-	r.mx.Lock()
-	defer r.mx.Unlock()
-	val, err := r.GetCounter()
-	if err != nil {
-		return "", err
-	}
-	curr, err := strconv.Atoi(val)
-	if err != nil {
-		return "", myerrors.ErrNonNumericCounter
-	}
-	add := curr + n
-	if add < 0 {
-		return "", myerrors.ErrNegativeCounter
-	}
-	res := strconv.Itoa(add)
-	return res, r.redisConn.Set(counter, res, r.expiration).Err()
+	fmt.Println(pong)
+	return client, nil
 }
 
 // Set sets value in redis for given key
@@ -90,3 +60,23 @@ func (r *RedisCache) Get(key string) (string, error) {
 	}
 	return value, nil
 }
+
+// SetNX sets value for key unless it already exists
+func (r *RedisCache) SetNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	return r.redisConn.SetNX(key, value, ttl).Result()
+}
+
+// SetEX sets value for key expiring after ttl regardless of configured expiration
+func (r *RedisCache) SetEX(key string, value interface{}, ttl time.Duration) error {
+	return r.redisConn.Set(key, value, ttl).Err()
+}
+
+// Del removes key
+func (r *RedisCache) Del(key string) error {
+	return r.redisConn.Del(key).Err()
+}
+
+// Close closes redis client
+func (r *RedisCache) Close() error {
+	return r.redisConn.Close()
+}
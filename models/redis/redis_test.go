@@ -0,0 +1,192 @@
+package redis
+
+import (
+	"rest/config"
+	"rest/models"
+	"rest/models/storetest"
+	"rest/myerrors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+)
+
+// TestRedisCache tests that RedisCache conforms to RedisInterface shared with in-memory cache
+func TestRedisCache(t *testing.T) {
+	storetest.Cache(t, func(t *testing.T, expiration time.Duration) (models.RedisInterface, func(d time.Duration)) {
+		mr, err := miniredis.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(mr.Close)
+		c, err := NewRedisCache(config.Redis{Addr: mr.Addr(), Expiration: expiration})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c, mr.FastForward
+	})
+}
+
+// newTestJobStore returns job store on fresh miniredis
+func newTestJobStore(t *testing.T, expiration time.Duration) (*JobStore, *miniredis.Miniredis) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mr.Close)
+	store, err := NewJobStore(config.Redis{Addr: mr.Addr(), Expiration: expiration})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		store.Close()
+	})
+	return store.(*JobStore), mr
+}
+
+// jobIDs returns IDs of jobs
+func jobIDs(jobs []models.Job) string {
+	IDs := make([]string, len(jobs))
+	for i, j := range jobs {
+		IDs[i] = j.ID
+	}
+	return strings.Join(IDs, ",")
+}
+
+// TestJobStoreStatuses tests that jobs are listed by status from sets kept up to date by UpdateJob
+func TestJobStoreStatuses(t *testing.T) {
+	store, _ := newTestJobStore(t, time.Hour)
+	now := time.Now().UTC()
+	for i, ID := range []string{"a", "b", "c"} {
+		if err := store.CreateJob(&models.Job{ID: ID, Status: models.JobQueued, CreatedAt: now.Add(time.Second * time.Duration(i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, _ := store.GetJob("b")
+	b.Status = models.JobRunning
+	if err := store.UpdateJob(b); err != nil {
+		t.Fatal(err)
+	}
+	c, _ := store.GetJob("c")
+	c.Status = models.JobDone
+	if err := store.UpdateJob(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateJob(&models.Job{ID: "x", Status: models.JobDone}); err != myerrors.ErrJobNotFound {
+		t.Errorf("expected %v for unknown job but got %v", myerrors.ErrJobNotFound, err)
+	}
+	for status, expected := range map[models.JobStatus]string{models.JobQueued: "a", models.JobRunning: "b", models.JobDone: "c", models.JobFailed: "", "": "a,b,c"} {
+		jobs, total, err := store.ListJobs(models.JobFilter{Status: status, Limit: 10})
+		if err != nil || jobIDs(jobs) != expected || total != len(jobs) {
+			t.Errorf("for status %q, expected jobs %q but got %q of %d, %v", status, expected, jobIDs(jobs), total, err)
+		}
+	}
+	if jobs, _, _ := store.ListJobs(models.JobFilter{Offset: 1, Limit: 1}); jobIDs(jobs) != "b" {
+		t.Errorf("expected page with job %q but got %q", "b", jobIDs(jobs))
+	}
+	if jobs, err := store.UnfinishedJobs(); err != nil || jobIDs(jobs) != "a,b" {
+		t.Errorf("expected unfinished jobs %q but got %q, %v", "a,b", jobIDs(jobs), err)
+	}
+}
+
+// TestJobStorePrune tests that IDs of expired jobs do not stay in sorted sets
+func TestJobStorePrune(t *testing.T) {
+	store, mr := newTestJobStore(t, time.Hour)
+	old := time.Now().UTC().Add(-time.Hour * 2)
+	for _, j := range []models.Job{
+		{ID: "done", Status: models.JobDone, CreatedAt: old},
+		{ID: "queued", Status: models.JobQueued, CreatedAt: old},
+	} {
+		j := j
+		if err := store.CreateJob(&j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mr.FastForward(time.Hour * 2)
+	if err := store.CreateJob(&models.Job{ID: "new", Status: models.JobQueued, CreatedAt: time.Now().UTC()}); err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[string]int{jobsKey: 1, statusKey(models.JobDone): 0, statusKey(models.JobQueued): 2} {
+		if n, _ := store.redisConn.ZCard(key).Result(); n != int64(expected) {
+			t.Errorf("expected %d IDs in %s but got %d", expected, key, n)
+		}
+	}
+	// expired unfinished job is dropped once it is found missing
+	if jobs, err := store.UnfinishedJobs(); err != nil || jobIDs(jobs) != "new" {
+		t.Errorf("expected unfinished job %q but got %q, %v", "new", jobIDs(jobs), err)
+	}
+	if n, _ := store.redisConn.ZCard(statusKey(models.JobQueued)).Result(); n != 1 {
+		t.Errorf("expected expired job to be removed from queued set but got %d IDs", n)
+	}
+}
+
+// TestJobStoreIndexStatuses tests that jobs kept before status sets existed are added to them on start
+func TestJobStoreIndexStatuses(t *testing.T) {
+	store, mr := newTestJobStore(t, 0)
+	if err := store.CreateJob(&models.Job{ID: "a", Status: models.JobRunning, CreatedAt: time.Now().UTC()}); err != nil {
+		t.Fatal(err)
+	}
+	mr.Del(statusKey(models.JobRunning))
+	mr.Del(statusesIndexed)
+	reopened, err := NewJobStore(config.Redis{Addr: mr.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if jobs, err := reopened.UnfinishedJobs(); err != nil || jobIDs(jobs) != "a" {
+		t.Errorf("expected unfinished job %q but got %q, %v", "a", jobIDs(jobs), err)
+	}
+}
+
+// TestJobStoreDeleteBatch tests that deleted batch leaves neither its jobs nor their IDs in sorted sets
+func TestJobStoreDeleteBatch(t *testing.T) {
+	store, _ := newTestJobStore(t, time.Hour)
+	now := time.Now().UTC()
+	b := &models.Batch{ID: "b", JobIDs: []string{"x", "y"}, CreatedAt: now}
+	if err := store.CreateBatch(b, []models.Job{{ID: "x", Status: models.JobQueued, CreatedAt: now}, {ID: "y", Status: models.JobQueued, CreatedAt: now}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateJob(&models.Job{ID: "z", Status: models.JobQueued, CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteBatch(b); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.GetBatch("b"); err != myerrors.ErrBatchNotFound {
+		t.Errorf("expected %v but got %v", myerrors.ErrBatchNotFound, err)
+	}
+	if _, err := store.GetJob("x"); err != myerrors.ErrJobNotFound {
+		t.Errorf("expected %v but got %v", myerrors.ErrJobNotFound, err)
+	}
+	for _, key := range []string{jobsKey, statusKey(models.JobQueued)} {
+		if IDs, _ := store.redisConn.ZRange(key, 0, -1).Result(); strings.Join(IDs, ",") != "z" {
+			t.Errorf("expected only %q in %s but got %v", "z", key, IDs)
+		}
+	}
+}
+
+// TestJobStoreDeleteJob tests that deleted job is gone with its deliveries and sorted set entries
+func TestJobStoreDeleteJob(t *testing.T) {
+	store, _ := newTestJobStore(t, time.Hour)
+	if err := store.CreateJob(&models.Job{ID: "x", Status: models.JobQueued, CreatedAt: time.Now().UTC()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddDelivery(&models.Delivery{JobID: "x", Attempt: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteJob("x"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetJob("x"); err != myerrors.ErrJobNotFound {
+		t.Errorf("expected %v but got %v", myerrors.ErrJobNotFound, err)
+	}
+	if deliveries, err := store.ListDeliveries("x"); err != nil || len(deliveries) != 0 {
+		t.Errorf("expected no deliveries but got %+v, %v", deliveries, err)
+	}
+	for _, key := range []string{jobsKey, statusKey(models.JobQueued)} {
+		if n, _ := store.redisConn.ZCard(key).Result(); n != 0 {
+			t.Errorf("expected no IDs in %s but got %d", key, n)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+// Package sqlite keeps users in SQLite file so that the API runs without MySQL
+// Driver is written in pure Go, so the binary still builds without cgo
+package sqlite
+
+import (
+	"database/sql"
+	"log"
+	"rest/config"
+	"rest/models"
+	"rest/models/sqlutil"
+	"rest/myerrors"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// driverName is the name pure-Go driver registers with database/sql
+const driverName = "sqlite"
+
+// timeFormat keeps timestamps as text that sorts in time order
+const timeFormat = "2006-01-02 15:04:05.000000000"
+
+// eachPage is number of users EachUser reads at once
+const eachPage = 500
+
+// schema creates tables on open, names compare case-insensitively like in MySQL
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS users
+(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    firstname TEXT NOT NULL COLLATE NOCASE,
+    lastname TEXT NOT NULL COLLATE NOCASE,
+    email TEXT NULL,
+    iin TEXT NULL UNIQUE,
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    deleted_at TEXT NULL
+)`,
+	"CREATE INDEX IF NOT EXISTS users_firstname ON users (firstname)",
+	"CREATE INDEX IF NOT EXISTS users_lastname ON users (lastname)",
+	"CREATE INDEX IF NOT EXISTS users_deleted_at ON users (deleted_at)",
+}
+
+// SQLite is MySQLInterface kept in SQLite database
+type SQLite struct {
+	db *sql.DB
+}
+
+// NewSQLite opens database file from config creating its tables
+func NewSQLite(cfg config.SQLite) (models.MySQLInterface, error) {
+	db, err := sql.Open(driverName, cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	// single connection serializes writes, which SQLite does anyway, and keeps :memory: database shared
+	db.SetMaxOpenConns(1)
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	log.Println("INFO|Opened SQLite database", cfg.Path)
+	return &SQLite{db: db}, nil
+}
+
+// CreateUser adds user and returns their ID, timestamps of u are set to the time of creation
+func (s *SQLite) CreateUser(u *models.User) (int64, error) {
+	if err := createUser(s.db, u); err != nil {
+		return 0, err
+	}
+	return u.ID, nil
+}
+
+// CreateUsers saves users in one transaction setting their IDs and timestamps
+func (s *SQLite) CreateUsers(users []models.User) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for i := range users {
+		if err := createUser(tx, &users[i]); err != nil {
+			if err == myerrors.ErrIINTaken {
+				return &models.UserError{Index: i, Err: err}
+			}
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// execer is either database or transaction
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// createUser inserts u
+func createUser(db execer, u *models.User) error {
+	now := time.Now().UTC()
+	res, err := db.Exec("INSERT INTO users (firstname, lastname, email, iin, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?)",
+		u.FirstName, u.LastName, sqlutil.NullString(u.Email), sqlutil.NullString(u.IIN), now.Format(timeFormat), now.Format(timeFormat))
+	if err != nil {
+		return userError(err)
+	}
+	ID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	u.ID, u.Version, u.CreatedAt, u.UpdatedAt, u.DeletedAt = ID, 1, now, now, nil
+	return nil
+}
+
+// GetUser retrieves user by ID, deleted users are not found
+func (s *SQLite) GetUser(ID string) (*models.User, error) {
+	user, err := scanUser(s.db.QueryRow("SELECT "+sqlutil.UserColumns+" FROM users WHERE id = ? AND deleted_at IS NULL", ID))
+	if err == sql.ErrNoRows {
+		return new(models.User), myerrors.ErrUserNotFound
+	}
+	return user, err
+}
+
+// UpdateUser replaces user by ID with u and returns user as stored
+// If u.Version is set, user is updated only if it is still at that version, otherwise ErrVersionMismatch is returned.
+func (s *SQLite) UpdateUser(ID string, u models.User) (*models.User, error) {
+	query := "UPDATE users SET firstname = ?, lastname = ?, email = ?, iin = ?, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL"
+	args := []interface{}{u.FirstName, u.LastName, sqlutil.NullString(u.Email), sqlutil.NullString(u.IIN), time.Now().UTC().Format(timeFormat), ID}
+	if u.Version != 0 {
+		query, args = query+" AND version = ?", append(args, u.Version)
+	}
+	user, err := scanUser(s.db.QueryRow(query+" RETURNING "+sqlutil.UserColumns, args...))
+	if err == sql.ErrNoRows {
+		if u.Version == 0 {
+			return nil, myerrors.ErrUserNotFound
+		}
+		if _, err := s.GetUser(ID); err != nil {
+			return nil, err
+		}
+		return nil, myerrors.ErrVersionMismatch
+	}
+	if err != nil {
+		return nil, userError(err)
+	}
+	return user, nil
+}
+
+// DeleteUser marks user by ID as deleted
+func (s *SQLite) DeleteUser(ID string) error {
+	now := time.Now().UTC().Format(timeFormat)
+	return s.exec("UPDATE users SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL", now, now, ID)
+}
+
+// RestoreUser brings back user deleted by DeleteUser
+func (s *SQLite) RestoreUser(ID string) error {
+	err := s.exec("UPDATE users SET deleted_at = NULL, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL", time.Now().UTC().Format(timeFormat), ID)
+	if err != myerrors.ErrUserNotFound {
+		return err
+	}
+	if _, err := s.GetUser(ID); err != nil {
+		return err
+	}
+	return myerrors.ErrUserNotDeleted
+}
+
+// PurgeUsers permanently removes users deleted before given time
+func (s *SQLite) PurgeUsers(before time.Time) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM users WHERE deleted_at < ?", before.UTC().Format(timeFormat))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ListUsers returns page of users matching filter
+func (s *SQLite) ListUsers(f models.UserFilter) ([]models.User, int, error) {
+	where, args := sqlutil.UserFilter(f)
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM users"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	query, args := sqlutil.UserPage(f)
+	users, err := s.query(query, args...)
+	return users, total, err
+}
+
+// EachUser reads live users page by page so that the connection is not held while fn runs
+func (s *SQLite) EachUser(fn func(u models.User) error) error {
+	var last int64
+	for {
+		users, err := s.query("SELECT "+sqlutil.UserColumns+" FROM users WHERE deleted_at IS NULL AND id > ? ORDER BY id LIMIT ?", last, eachPage)
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			if err := fn(u); err != nil {
+				return err
+			}
+			last = u.ID
+		}
+		if len(users) < eachPage {
+			return nil
+		}
+	}
+}
+
+// Close closes database
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+// query returns users selected with sqlutil.UserColumns
+func (s *SQLite) query(query string, args ...interface{}) ([]models.User, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+// exec runs statement changing single user, ErrUserNotFound is returned if no row was affected
+func (s *SQLite) exec(query string, args ...interface{}) error {
+	res, err := s.db.Exec(query, args...)
+	if err != nil {
+		return userError(err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return myerrors.ErrUserNotFound
+	}
+	return nil
+}
+
+// userError converts violation of unique IIN into ErrIINTaken
+func userError(err error) error {
+	if strings.Contains(err.Error(), "UNIQUE constraint failed: users.iin") {
+		return myerrors.ErrIINTaken
+	}
+	return err
+}
+
+// scanUser reads user from row selected with sqlutil.UserColumns
+func scanUser(row sqlutil.Scanner) (*models.User, error) {
+	var (
+		u                models.User
+		email, iin       sql.NullString
+		created, updated string
+		deleted          sql.NullString
+	)
+	if err := row.Scan(&u.ID, &u.FirstName, &u.LastName, &email, &iin, &u.Version, &created, &updated, &deleted); err != nil {
+		return nil, err
+	}
+	u.Email, u.IIN = email.String, iin.String
+	var err error
+	if u.CreatedAt, err = time.Parse(timeFormat, created); err != nil {
+		return nil, err
+	}
+	if u.UpdatedAt, err = time.Parse(timeFormat, updated); err != nil {
+		return nil, err
+	}
+	if deleted.Valid {
+		at, err := time.Parse(timeFormat, deleted.String)
+		if err != nil {
+			return nil, err
+		}
+		u.DeletedAt = &at
+	}
+	return &u, nil
+}
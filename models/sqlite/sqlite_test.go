@@ -0,0 +1,19 @@
+package sqlite
+
+import (
+	"rest/config"
+	"rest/models"
+	"rest/models/storetest"
+	"testing"
+)
+
+// TestSQLite tests that SQLite conforms to MySQLInterface
+func TestSQLite(t *testing.T) {
+	storetest.Users(t, func(t *testing.T) models.MySQLInterface {
+		db, err := NewSQLite(config.SQLite{Path: ":memory:"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
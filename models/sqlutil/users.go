@@ -0,0 +1,72 @@
+// Package sqlutil builds user queries shared by SQL user stores
+package sqlutil
+
+import (
+	"database/sql"
+	"rest/models"
+	"strings"
+)
+
+// UserColumns are selected by every query returning users, stores scan them in this order
+const UserColumns = "id, firstname, lastname, email, iin, version, created_at, updated_at, deleted_at"
+
+// Scanner is either *sql.Row or *sql.Rows
+type Scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// NullString stores empty optional field as NULL so that unique IIN allows many users without it
+func NullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// sortColumns maps sort fields to columns, only these columns get into ORDER BY
+var sortColumns = map[models.UserSort]string{
+	models.SortByID:        "id",
+	models.SortByFirstName: "firstname",
+	models.SortByLastName:  "lastname",
+}
+
+// UserFilter returns WHERE clause matching live or deleted users by name prefix
+func UserFilter(f models.UserFilter) (string, []interface{}) {
+	where := " WHERE deleted_at IS NULL"
+	if f.Deleted {
+		where = " WHERE deleted_at IS NOT NULL"
+	}
+	if f.Query == "" {
+		return where, nil
+	}
+	prefix := likeEscaper.Replace(f.Query) + "%"
+	return where + " AND (firstname LIKE ? ESCAPE '!' OR lastname LIKE ? ESCAPE '!')", []interface{}{prefix, prefix}
+}
+
+// likeEscaper escapes LIKE wildcards with '!'
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// UserPage returns query selecting page of users after cursor
+func UserPage(f models.UserFilter) (string, []interface{}) {
+	where, args := UserFilter(f)
+	column, ok := sortColumns[f.Sort]
+	if !ok {
+		column = "id"
+	}
+	op, dir := ">", "ASC"
+	if f.Desc {
+		op, dir = "<", "DESC"
+	}
+	if c := f.After; c != nil {
+		cond := "id " + op + " ?"
+		if column == "id" {
+			args = append(args, c.ID)
+		} else {
+			cond = "(" + column + " " + op + " ? OR " + column + " = ? AND id " + op + " ?)"
+			args = append(args, c.Key, c.Key, c.ID)
+		}
+		where += " AND " + cond
+	}
+	order := " ORDER BY " + column + " " + dir
+	if column != "id" {
+		order += ", id " + dir
+	}
+	return "SELECT " + UserColumns + " FROM users" + where + order + " LIMIT ? OFFSET ?", append(args, f.Limit, f.Offset)
+}
@@ -0,0 +1,40 @@
+package sqlutil
+
+import (
+	"fmt"
+	"rest/models"
+	"testing"
+)
+
+var userPageTests = []struct {
+	number        int
+	filter        models.UserFilter
+	expectedQuery string
+	expectedArgs  string
+}{
+	{0, models.UserFilter{Limit: 10}, "SELECT id, firstname, lastname, email, iin, version, created_at, updated_at, deleted_at FROM users WHERE deleted_at IS NULL ORDER BY id ASC LIMIT ? OFFSET ?", "[10 0]"},
+	{1, models.UserFilter{Sort: models.SortByLastName, Desc: true, Offset: 5, Limit: 10},
+		"SELECT id, firstname, lastname, email, iin, version, created_at, updated_at, deleted_at FROM users WHERE deleted_at IS NULL ORDER BY lastname DESC, id DESC LIMIT ? OFFSET ?", "[10 5]"},
+	{2, models.UserFilter{Query: "a_b%!", Limit: 10},
+		"SELECT id, firstname, lastname, email, iin, version, created_at, updated_at, deleted_at FROM users WHERE deleted_at IS NULL AND (firstname LIKE ? ESCAPE '!' OR lastname LIKE ? ESCAPE '!') ORDER BY id ASC LIMIT ? OFFSET ?", "[a!_b!%!!% a!_b!%!!% 10 0]"},
+	{3, models.UserFilter{After: &models.UserCursor{ID: 7}, Desc: true, Deleted: true, Limit: 10},
+		"SELECT id, firstname, lastname, email, iin, version, created_at, updated_at, deleted_at FROM users WHERE deleted_at IS NOT NULL AND id < ? ORDER BY id DESC LIMIT ? OFFSET ?", "[7 10 0]"},
+	{4, models.UserFilter{Query: "A", Sort: models.SortByFirstName, After: &models.UserCursor{Key: "Ann", ID: 7}, Limit: 10},
+		"SELECT id, firstname, lastname, email, iin, version, created_at, updated_at, deleted_at FROM users WHERE deleted_at IS NULL AND (firstname LIKE ? ESCAPE '!' OR lastname LIKE ? ESCAPE '!') AND (firstname > ? OR firstname = ? AND id > ?) ORDER BY firstname ASC, id ASC LIMIT ? OFFSET ?",
+		"[A% A% Ann Ann 7 10 0]"},
+	// unknown sort never gets into query
+	{5, models.UserFilter{Sort: "id; DROP TABLE users", Limit: 10}, "SELECT id, firstname, lastname, email, iin, version, created_at, updated_at, deleted_at FROM users WHERE deleted_at IS NULL ORDER BY id ASC LIMIT ? OFFSET ?", "[10 0]"},
+}
+
+// TestUserPage tests that users are selected with parameterised queries
+func TestUserPage(t *testing.T) {
+	for _, testCase := range userPageTests {
+		query, args := UserPage(testCase.filter)
+		if query != testCase.expectedQuery {
+			t.Errorf("for test #%d, expected %q but got %q", testCase.number, testCase.expectedQuery, query)
+		}
+		if got := fmt.Sprint(args); got != testCase.expectedArgs {
+			t.Errorf("for test #%d, expected args %s but got %s", testCase.number, testCase.expectedArgs, got)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"rest/models"
+	"rest/myerrors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// expiration is passed to open of Cache as expiration of Set and counter
+const expiration = time.Minute
+
+// Cache tests that empty cache returned by open behaves like redis
+// open must apply expiration to Set and counter and return function moving time of the cache forward.
+func Cache(t *testing.T, open func(t *testing.T, expiration time.Duration) (models.RedisInterface, func(d time.Duration))) {
+	for _, test := range []struct {
+		name string
+		run  func(t *testing.T, c models.RedisInterface, advance func(d time.Duration))
+	}{
+		{"Counter", testCounter},
+		{"CounterConcurrent", testCounterConcurrent},
+		{"NamedCounters", testNamedCounters},
+		{"Values", testValues},
+		{"Expiry", testExpiry},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c, advance := open(t, expiration)
+			defer c.Close()
+			test.run(t, c, advance)
+		})
+	}
+}
+
+func testCounter(t *testing.T, c models.RedisInterface, _ func(d time.Duration)) {
+	if cnt, err := c.GetCounter(models.DefaultCounter); err != nil || cnt.Value != 0 || cnt.Min == nil || *cnt.Min != 0 || cnt.Max != nil {
+		t.Errorf("expected new counter at 0 with min 0 but got %+v, %v", cnt, err)
+	}
+	for _, step := range []struct {
+		n        int
+		expected int64
+		err      error
+	}{
+		{5, 5, nil},
+		{-2, 3, nil},
+		{-4, 0, myerrors.ErrNegativeCounter},
+		{0, 3, nil},
+		{-3, 0, nil},
+	} {
+		cnt, err := c.SetCounter(models.DefaultCounter, step.n)
+		if err != step.err || err == nil && cnt.Value != step.expected {
+			t.Errorf("adding %d expected %d, %v but got %+v, %v", step.n, step.expected, step.err, cnt, err)
+		}
+	}
+	if cnt, err := c.GetCounter(models.DefaultCounter); err != nil || cnt.Value != 0 {
+		t.Errorf("expected counter 0 but got %+v, %v", cnt, err)
+	}
+	// default counter keeps its key
+	if err := c.Set("counter", "ten"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetCounter(models.DefaultCounter, 1); err != myerrors.ErrNonNumericCounter {
+		t.Errorf("expected %v but got %v", myerrors.ErrNonNumericCounter, err)
+	}
+}
+
+func testCounterConcurrent(t *testing.T, c models.RedisInterface, _ func(d time.Duration)) {
+	const workers, adds = 8, 25
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < adds; k++ {
+				if _, err := c.SetCounter(models.DefaultCounter, 1); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if cnt, err := c.GetCounter(models.DefaultCounter); err != nil || cnt.Value != workers*adds {
+		t.Errorf("expected counter %d but got %+v, %v", workers*adds, cnt, err)
+	}
+}
+
+func testNamedCounters(t *testing.T, c models.RedisInterface, _ func(d time.Duration)) {
+	min, max := int64(-5), int64(10)
+	cnt, err := c.SetCounterBounds(models.Counter{Name: "temp", Min: &min, Max: &max})
+	if err != nil || cnt.Value != 0 {
+		t.Fatalf("expected new counter at 0 but got %+v, %v", cnt, err)
+	}
+	for _, step := range []struct {
+		n        int
+		expected int64
+		err      error
+	}{
+		{-5, -5, nil},
+		{-1, 0, myerrors.ErrCounterBounds},
+		{15, 10, nil},
+		{1, 0, myerrors.ErrCounterBounds},
+	} {
+		cnt, err := c.SetCounter("temp", step.n)
+		if !errors.Is(err, step.err) || err == nil && cnt.Value != step.expected {
+			t.Errorf("adding %d expected %d, %v but got %+v, %v", step.n, step.expected, step.err, cnt, err)
+		}
+	}
+	if cnt, err := c.GetCounter("temp"); err != nil || cnt.Value != 10 || cnt.Min == nil || *cnt.Min != min || cnt.Max == nil || *cnt.Max != max {
+		t.Errorf("expected counter 10 within [%d, %d] but got %+v, %v", min, max, cnt, err)
+	}
+	// value 10 does not fit new bounds
+	if _, err := c.SetCounterBounds(models.Counter{Name: "temp", Max: &min}); !errors.Is(err, myerrors.ErrCounterBounds) {
+		t.Errorf("expected %v but got %v", myerrors.ErrCounterBounds, err)
+	}
+	// without bounds counter may go negative
+	if _, err := c.SetCounterBounds(models.Counter{Name: "temp"}); err != nil {
+		t.Fatal(err)
+	}
+	if cnt, err := c.SetCounter("temp", -100); err != nil || cnt.Value != -90 || cnt.Min != nil || cnt.Max != nil {
+		t.Errorf("expected unbounded counter -90 but got %+v, %v", cnt, err)
+	}
+	// new counter starts at bound nearest to 0
+	low := int64(3)
+	if cnt, err := c.SetCounterBounds(models.Counter{Name: "low", Min: &low}); err != nil || cnt.Value != 3 {
+		t.Errorf("expected counter to start at 3 but got %+v, %v", cnt, err)
+	}
+	if _, err := c.SetCounter("plain", 2); err != nil {
+		t.Fatal(err)
+	}
+	if cnt, err := c.GetCounter("missing"); err != nil || cnt.Value != 0 {
+		t.Errorf("expected missing counter to read as 0 but got %+v, %v", cnt, err)
+	}
+	expected := []string{"low", "plain", "temp"}
+	if got := counterNames(t, c); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("expected counters %v but got %v", expected, got)
+	}
+	if err := c.DelCounter("temp"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DelCounter("temp"); !errors.Is(err, myerrors.ErrCounterNotFound) {
+		t.Errorf("expected %v for deleted counter but got %v", myerrors.ErrCounterNotFound, err)
+	}
+	// bounds are deleted with counter
+	if cnt, err := c.SetCounter("temp", -1); !errors.Is(err, myerrors.ErrNegativeCounter) {
+		t.Errorf("expected %v for recreated counter but got %+v, %v", myerrors.ErrNegativeCounter, cnt, err)
+	}
+	expected = []string{"low", "plain"}
+	if got := counterNames(t, c); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("expected counters %v but got %v", expected, got)
+	}
+}
+
+// counterNames returns names of listed counters
+func counterNames(t *testing.T, c models.RedisInterface) []string {
+	counters, err := c.ListCounters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, cnt := range counters {
+		names = append(names, cnt.Name)
+	}
+	return names
+}
+
+func testValues(t *testing.T, c models.RedisInterface, _ func(d time.Duration)) {
+	if _, err := c.Get("key"); err != myerrors.ErrNotFound {
+		t.Errorf("expected %v for missing key but got %v", myerrors.ErrNotFound, err)
+	}
+	if err := c.Set("key", 42); err != nil {
+		t.Fatal(err)
+	}
+	if val, err := c.Get("key"); err != nil || val != "42" {
+		t.Errorf("expected %q but got %q, %v", "42", val, err)
+	}
+	if ok, err := c.SetNX("key", "other", 0); err != nil || ok {
+		t.Errorf("expected existing key to be kept but got %t, %v", ok, err)
+	}
+	if ok, err := c.SetNX("new", "value", 0); err != nil || !ok {
+		t.Errorf("expected missing key to be set but got %t, %v", ok, err)
+	}
+	if err := c.SetEX("key", "replaced", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if val, err := c.Get("key"); err != nil || val != "replaced" {
+		t.Errorf("expected %q but got %q, %v", "replaced", val, err)
+	}
+	if err := c.Del("key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("key"); err != myerrors.ErrNotFound {
+		t.Errorf("expected %v for deleted key but got %v", myerrors.ErrNotFound, err)
+	}
+	if err := c.Del("key"); err != nil {
+		t.Errorf("expected deleting missing key to succeed but got %v", err)
+	}
+}
+
+func testExpiry(t *testing.T, c models.RedisInterface, advance func(d time.Duration)) {
+	if err := c.Set("set", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetEX("short", "value", time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetEX("long", "value", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetNX("nx", "value", time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetCounter(models.DefaultCounter, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetCounterBounds(models.Counter{Name: "short", TTL: time.Second * 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetCounterBounds(models.Counter{Name: "kept"}); err != nil {
+		t.Fatal(err)
+	}
+	advance(time.Second * 11)
+	for key, expected := range map[string]error{"set": nil, "short": myerrors.ErrNotFound, "long": nil, "nx": myerrors.ErrNotFound} {
+		if _, err := c.Get(key); err != expected {
+			t.Errorf("after 11s expected %v for %q but got %v", expected, key, err)
+		}
+	}
+	if got := counterNames(t, c); fmt.Sprint(got) != "[default kept]" {
+		t.Errorf("expected counters %v after 11s but got %v", "[default kept]", got)
+	}
+	if ok, err := c.SetNX("nx", "again", 0); err != nil || !ok {
+		t.Errorf("expected expired key to be set again but got %t, %v", ok, err)
+	}
+	advance(expiration)
+	if _, err := c.Get("set"); err != myerrors.ErrNotFound {
+		t.Errorf("expected %v after expiration but got %v", myerrors.ErrNotFound, err)
+	}
+	if got := counterNames(t, c); fmt.Sprint(got) != "[kept]" {
+		t.Errorf("expected counters %v after expiration but got %v", "[kept]", got)
+	}
+	if _, err := c.Get("long"); err != nil {
+		t.Errorf("expected key with own ttl to be kept but got %v", err)
+	}
+}
@@ -0,0 +1,240 @@
+// Package storetest holds conformance tests shared by implementations of store interfaces of package models
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"rest/models"
+	"rest/myerrors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Users tests that empty store returned by open behaves like MySQL keeping users
+func Users(t *testing.T, open func(t *testing.T) models.MySQLInterface) {
+	for _, test := range []struct {
+		name string
+		run  func(t *testing.T, db models.MySQLInterface)
+	}{
+		{"Create", testCreateUser},
+		{"Update", testUpdateUser},
+		{"Delete", testDeleteUser},
+		{"List", testListUsers},
+		{"CreateMany", testCreateUsers},
+		{"Each", testEachUser},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			db := open(t)
+			defer db.Close()
+			test.run(t, db)
+		})
+	}
+}
+
+// create saves users with given names and returns their IDs
+func create(t *testing.T, db models.MySQLInterface, names ...string) []string {
+	var IDs []string
+	for i := 0; i+1 < len(names); i += 2 {
+		u := models.User{FirstName: names[i], LastName: names[i+1]}
+		ID, err := db.CreateUser(&u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		IDs = append(IDs, strconv.FormatInt(ID, 10))
+	}
+	return IDs
+}
+
+func testCreateUser(t *testing.T, db models.MySQLInterface) {
+	before := time.Now().UTC().Add(-time.Second)
+	u := models.User{FirstName: "Ann", LastName: "Lee", Email: "ann@mail.kz", IIN: "980124450084"}
+	ID, err := db.CreateUser(&u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != ID || ID < 1 || u.Version != 1 || u.CreatedAt.Before(before) || !u.UpdatedAt.Equal(u.CreatedAt) {
+		t.Errorf("unexpected created user %+v", u)
+	}
+	got, err := db.GetUser(strconv.FormatInt(ID, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != ID || got.FirstName != "Ann" || got.LastName != "Lee" || got.Email != "ann@mail.kz" || got.IIN != "980124450084" ||
+		got.Version != 1 || !near(got.CreatedAt, u.CreatedAt) || got.DeletedAt != nil {
+		t.Errorf("expected %+v but got %+v", u, got)
+	}
+	second := models.User{FirstName: "Bob", LastName: "Lee"}
+	if next, err := db.CreateUser(&second); err != nil || next <= ID {
+		t.Errorf("expected ID greater than %d but got %d, %v", ID, next, err)
+	}
+	dup := models.User{FirstName: "Eve", LastName: "Ray", IIN: "980124450084"}
+	if _, err := db.CreateUser(&dup); err != myerrors.ErrIINTaken {
+		t.Errorf("expected %v for taken IIN but got %v", myerrors.ErrIINTaken, err)
+	}
+	if _, err := db.GetUser("1000"); err != myerrors.ErrUserNotFound {
+		t.Errorf("expected %v for unknown user but got %v", myerrors.ErrUserNotFound, err)
+	}
+}
+
+func testUpdateUser(t *testing.T, db models.MySQLInterface) {
+	IDs := create(t, db, "Ann", "Lee", "Bob", "Ray")
+	if _, err := db.UpdateUser(IDs[1], models.User{FirstName: "Bob", LastName: "Ray", IIN: "980124450084"}); err != nil {
+		t.Fatal(err)
+	}
+	u, err := db.UpdateUser(IDs[0], models.User{FirstName: "Anna", LastName: "Li", Email: "ann@mail.kz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.FirstName != "Anna" || u.LastName != "Li" || u.Email != "ann@mail.kz" || u.Version != 2 || u.UpdatedAt.Before(u.CreatedAt) {
+		t.Errorf("unexpected updated user %+v", u)
+	}
+	// omitted fields are cleared
+	if u, err = db.UpdateUser(IDs[0], models.User{FirstName: "Anna", LastName: "Li", Version: 2}); err != nil || u.Email != "" || u.Version != 3 {
+		t.Errorf("expected email to be cleared at version 3 but got %+v, %v", u, err)
+	}
+	if _, err := db.UpdateUser(IDs[0], models.User{FirstName: "Al", LastName: "Li", Version: 2}); err != myerrors.ErrVersionMismatch {
+		t.Errorf("expected %v for stale version but got %v", myerrors.ErrVersionMismatch, err)
+	}
+	if _, err := db.UpdateUser(IDs[0], models.User{FirstName: "Al", LastName: "Li", IIN: "980124450084"}); err != myerrors.ErrIINTaken {
+		t.Errorf("expected %v for taken IIN but got %v", myerrors.ErrIINTaken, err)
+	}
+	if _, err := db.UpdateUser("1000", models.User{FirstName: "Al", LastName: "Li", Version: 1}); err != myerrors.ErrUserNotFound {
+		t.Errorf("expected %v for unknown user but got %v", myerrors.ErrUserNotFound, err)
+	}
+	if got, _ := db.GetUser(IDs[0]); got.FirstName != "Anna" || got.Version != 3 {
+		t.Errorf("expected rejected updates to change nothing but got %+v", got)
+	}
+}
+
+func testDeleteUser(t *testing.T, db models.MySQLInterface) {
+	IDs := create(t, db, "Ann", "Lee", "Bob", "Ray")
+	if err := db.RestoreUser(IDs[0]); err != myerrors.ErrUserNotDeleted {
+		t.Errorf("expected %v for live user but got %v", myerrors.ErrUserNotDeleted, err)
+	}
+	if err := db.DeleteUser(IDs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetUser(IDs[0]); err != myerrors.ErrUserNotFound {
+		t.Errorf("expected deleted user to be not found but got %v", err)
+	}
+	for _, err := range []error{db.DeleteUser(IDs[0]), db.DeleteUser("1000"), db.RestoreUser("1000")} {
+		if err != myerrors.ErrUserNotFound {
+			t.Errorf("expected %v but got %v", myerrors.ErrUserNotFound, err)
+		}
+	}
+	if _, err := db.UpdateUser(IDs[0], models.User{FirstName: "Al", LastName: "Li"}); err != myerrors.ErrUserNotFound {
+		t.Errorf("expected deleted user not to be updated but got %v", err)
+	}
+	deleted, total, err := db.ListUsers(models.UserFilter{Sort: models.SortByID, Deleted: true, Limit: 10})
+	if err != nil || total != 1 || len(deleted) != 1 || deleted[0].DeletedAt == nil {
+		t.Errorf("expected one deleted user but got %+v, %d, %v", deleted, total, err)
+	}
+	if err := db.RestoreUser(IDs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := db.GetUser(IDs[0]); err != nil || u.DeletedAt != nil || u.Version != 3 {
+		t.Errorf("expected restored user at version 3 but got %+v, %v", u, err)
+	}
+
+	db.DeleteUser(IDs[0])
+	if n, err := db.PurgeUsers(time.Now().UTC().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("expected no users deleted hour ago but purged %d, %v", n, err)
+	}
+	if n, err := db.PurgeUsers(time.Now().UTC().Add(time.Second)); err != nil || n != 1 {
+		t.Errorf("expected to purge %d user but purged %d, %v", 1, n, err)
+	}
+	if err := db.RestoreUser(IDs[0]); err != myerrors.ErrUserNotFound {
+		t.Errorf("expected purged user to be gone but got %v", err)
+	}
+	if _, err := db.GetUser(IDs[1]); err != nil {
+		t.Errorf("expected live user to stay but got %v", err)
+	}
+}
+
+func testListUsers(t *testing.T, db models.MySQLInterface) {
+	IDs := create(t, db, "Ann", "Lee", "Bob", "Smith", "Anna", "Brown", "Carl", "Anders", "Bob", "Abbot", "Dan", "Ray")
+	db.DeleteUser(IDs[5])
+	for _, testCase := range []struct {
+		number   int
+		filter   models.UserFilter
+		expected string
+		total    int
+	}{
+		{0, models.UserFilter{Sort: models.SortByID, Limit: 10}, "[Ann Lee Bob Smith Anna Brown Carl Anders Bob Abbot]", 5},
+		{1, models.UserFilter{Sort: models.SortByID, Offset: 1, Limit: 2}, "[Bob Smith Anna Brown]", 5},
+		{2, models.UserFilter{Sort: models.SortByLastName, Limit: 10}, "[Bob Abbot Carl Anders Anna Brown Ann Lee Bob Smith]", 5},
+		{3, models.UserFilter{Sort: models.SortByFirstName, Desc: true, Limit: 10}, "[Carl Anders Bob Abbot Bob Smith Anna Brown Ann Lee]", 5},
+		{4, models.UserFilter{Query: "an", Sort: models.SortByID, Limit: 10}, "[Ann Lee Anna Brown Carl Anders]", 3},
+		{5, models.UserFilter{Query: "Ann", Sort: models.SortByID, Limit: 10}, "[Ann Lee Anna Brown]", 2},
+		{6, models.UserFilter{Query: "%", Sort: models.SortByID, Limit: 10}, "[]", 0},
+		{7, models.UserFilter{Sort: models.SortByFirstName, After: &models.UserCursor{Key: "Bob", ID: mustID(IDs[1])}, Limit: 10}, "[Bob Abbot Carl Anders]", 5},
+		{8, models.UserFilter{Sort: models.SortByID, Desc: true, After: &models.UserCursor{ID: mustID(IDs[2])}, Limit: 10}, "[Bob Smith Ann Lee]", 5},
+		{9, models.UserFilter{Sort: models.SortByID, Deleted: true, Limit: 10}, "[Dan Ray]", 1},
+	} {
+		users, total, err := db.ListUsers(testCase.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, u := range users {
+			names = append(names, u.FirstName, u.LastName)
+		}
+		if got := fmt.Sprint(names); got != testCase.expected || total != testCase.total {
+			t.Errorf("for test #%d, expected %s of %d but got %s of %d", testCase.number, testCase.expected, testCase.total, got, total)
+		}
+	}
+}
+
+func testCreateUsers(t *testing.T, db models.MySQLInterface) {
+	create(t, db, "Ann", "Lee")
+	users := []models.User{{FirstName: "Bob", LastName: "Ray", IIN: "980124450084"}, {FirstName: "Eve", LastName: "Ray", IIN: "980124450084"}}
+	err := db.CreateUsers(users)
+	var ue *models.UserError
+	if !errors.As(err, &ue) || ue.Index != 1 || ue.Err != myerrors.ErrIINTaken {
+		t.Errorf("expected second user to be rejected with %v but got %v", myerrors.ErrIINTaken, err)
+	}
+	if _, total, _ := db.ListUsers(models.UserFilter{Sort: models.SortByID, Limit: 10}); total != 1 {
+		t.Errorf("expected rejected batch not to be saved but got %d users", total)
+	}
+	users = users[:1]
+	if err := db.CreateUsers(users); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := db.GetUser(strconv.FormatInt(users[0].ID, 10)); err != nil || u.IIN != "980124450084" || u.Version != 1 {
+		t.Errorf("expected saved user but got %+v, %v", u, err)
+	}
+}
+
+func testEachUser(t *testing.T, db models.MySQLInterface) {
+	IDs := create(t, db, "Ann", "Lee", "Bob", "Ray", "Eve", "Ng")
+	db.DeleteUser(IDs[1])
+	var names []string
+	err := db.EachUser(func(u models.User) error {
+		names = append(names, u.FirstName)
+		return nil
+	})
+	if got := fmt.Sprint(names); err != nil || got != "[Ann Eve]" {
+		t.Errorf("expected live users [Ann Eve] but got %s, %v", got, err)
+	}
+	stop := errors.New("stop")
+	n := 0
+	err = db.EachUser(func(u models.User) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("expected iteration to stop with %v after one user but got %v after %d", stop, err, n)
+	}
+}
+
+// near reports whether times differ less than millisecond, databases may keep only microseconds
+func near(a, b time.Time) bool {
+	d := a.Sub(b)
+	return d > -time.Millisecond && d < time.Millisecond
+}
+
+func mustID(ID string) int64 {
+	n, _ := strconv.ParseInt(ID, 10, 64)
+	return n
+}
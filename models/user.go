@@ -1,7 +1,87 @@
 package models
 
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// User is a person kept in MySQL
+// Email and IIN are optional, IIN is unique among all users including deleted ones
+// Timestamps are set by the store, deleted users keep DeletedAt until they are restored or purged
+// Version starts at 1 and is incremented by the store on every change
 type User struct {
-	ID        int64  `json:"id"`
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
+	ID        int64      `json:"id"`
+	FirstName string     `json:"first_name"`
+	LastName  string     `json:"last_name"`
+	Email     string     `json:"email,omitempty"`
+	IIN       string     `json:"iin,omitempty"`
+	Version   int64      `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// UserSort is the field users are ordered by, ties are broken by ID
+type UserSort string
+
+const (
+	SortByID        UserSort = "id"
+	SortByFirstName UserSort = "first_name"
+	SortByLastName  UserSort = "last_name"
+)
+
+// Valid reports whether users can be sorted by s
+func (s UserSort) Valid() bool {
+	switch s {
+	case SortByID, SortByFirstName, SortByLastName:
+		return true
+	}
+	return false
+}
+
+// Key returns value of the sort field of u
+func (s UserSort) Key(u User) string {
+	switch s {
+	case SortByFirstName:
+		return u.FirstName
+	case SortByLastName:
+		return u.LastName
+	}
+	return strconv.FormatInt(u.ID, 10)
+}
+
+// UserCursor points at the last user of previous page
+// Key is ignored when users are sorted by ID
+type UserCursor struct {
+	Key string
+	ID  int64
+}
+
+// UserFilter selects page of users
+// Deleted selects soft-deleted users instead of live ones
+// Query matches users whose first or last name starts with it, case sensitivity follows the store's collation
+// Page starts after After if it is set, Offset is applied after that
+type UserFilter struct {
+	Query   string
+	Sort    UserSort
+	Desc    bool
+	Deleted bool
+	After   *UserCursor
+	Offset  int
+	Limit   int
+}
+
+// UserError reports which of users passed to CreateUsers could not be saved
+type UserError struct {
+	Index int
+	Err   error
+}
+
+func (e *UserError) Error() string {
+	return fmt.Sprintf("user %d: %v", e.Index, e.Err)
+}
+
+func (e *UserError) Unwrap() error {
+	return e.Err
 }
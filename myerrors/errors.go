@@ -1,13 +1,146 @@
 package myerrors
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+)
+
+// Kind classifies errors by the way they are reported to clients
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindInvalid
+	KindNotFound
+	KindConflict
+	KindUnavailable
+	KindExhausted
+	KindUnprocessable
+	KindUnsupported
+	KindPrecondition
+)
+
+// Status returns HTTP status code for errors of kind
+func (k Kind) Status() int {
+	switch k {
+	case KindInvalid:
+		return http.StatusBadRequest
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindUnavailable:
+		return http.StatusServiceUnavailable
+	case KindExhausted:
+		return http.StatusTooManyRequests
+	case KindUnprocessable:
+		return http.StatusUnprocessableEntity
+	case KindUnsupported:
+		return http.StatusUnsupportedMediaType
+	case KindPrecondition:
+		return http.StatusPreconditionFailed
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is an error with stable code and message safe to show to clients.
+// Copies made by Wrap and WithDetail match their origin with errors.Is.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Err     error
+	origin  *Error
+}
+
+// New returns new error of kind
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether e was derived from target
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.root() == e.root()
+}
+
+// Status returns HTTP status code of e
+func (e *Error) Status() int {
+	return e.Kind.Status()
+}
+
+// Wrap returns copy of e caused by err
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.Err = err
+	c.origin = e.root()
+	return &c
+}
+
+// WithDetail returns copy of e with detail appended to its message
+func (e *Error) WithDetail(detail string) *Error {
+	c := *e
+	c.Message += ", " + detail
+	c.origin = e.root()
+	return &c
+}
+
+func (e *Error) root() *Error {
+	if e.origin != nil {
+		return e.origin
+	}
+	return e
+}
 
 var (
-	ErrBodyNotFound      = errors.New("couldn't get body")
-	ErrCtxValue          = errors.New("failed to retrieve value from context")
-	ErrInvalidInput      = errors.New("invalid input")
-	ErrNegativeCounter   = errors.New("input exceeds counter: counter cannot be negative")
-	ErrNonNumericCounter = errors.New("counter is non-numeric")
-	ErrNotFound          = errors.New("failed to retrieve data")
-	ErrUserNotFound      = errors.New("user not found")
+	ErrBatchNotFound     = New(KindNotFound, "batch_not_found", "batch not found")
+	ErrBodyNotFound      = New(KindInvalid, "body_not_found", "couldn't get body")
+	ErrCounterBounds     = New(KindInvalid, "counter_out_of_bounds", "counter cannot go below its min or above its max")
+	ErrCounterNotFound   = New(KindNotFound, "counter_not_found", "counter not found")
+	ErrCtxValue          = New(KindInternal, "context_value", "failed to retrieve value from context")
+	ErrIINTaken          = New(KindConflict, "iin_taken", "user with this IIN already exists")
+	ErrIdempotencyBusy   = New(KindConflict, "idempotency_key_in_use", "request with this idempotency key is still being processed")
+	ErrIdempotencyKey    = New(KindUnprocessable, "idempotency_key_reused", "idempotency key was already used with different request")
+	ErrInternal          = New(KindInternal, "internal_error", "internal error")
+	ErrInvalidInput      = New(KindInvalid, "invalid_input", "invalid input")
+	ErrJobFinished       = New(KindConflict, "job_finished", "job is already finished")
+	ErrJobNotFound       = New(KindNotFound, "job_not_found", "job not found")
+	ErrJobNotRetriable   = New(KindConflict, "job_not_retriable", "only failed or cancelled jobs can be retried")
+	ErrNegativeCounter   = New(KindInvalid, "negative_counter", "input exceeds counter: counter cannot be negative")
+	ErrNoMatch           = New(KindNotFound, "no_match", "no match found")
+	ErrNonNumericCounter = New(KindInternal, "non_numeric_counter", "counter is non-numeric")
+	ErrNotFound          = New(KindNotFound, "not_found", "failed to retrieve data")
+	ErrPatchFailed       = New(KindUnprocessable, "patch_failed", "patch cannot be applied")
+	ErrQueueClosed       = New(KindUnavailable, "queue_closed", "job queue is closed")
+	ErrQueueFull         = New(KindExhausted, "queue_full", "job queue is full, try again later")
+	ErrShuttingDown      = New(KindUnavailable, "shutting_down", "server is shutting down")
+	ErrVersionMismatch   = New(KindPrecondition, "version_mismatch", "user was modified by another request, fetch it again")
+	ErrUnsupportedMedia  = New(KindUnsupported, "unsupported_media_type", "unsupported content type")
+	ErrUserNotDeleted    = New(KindConflict, "user_not_deleted", "user is not deleted")
+	ErrUserNotFound      = New(KindNotFound, "user_not_found", "user not found")
 )
+
+// As returns err as *Error, errors of unknown type are wrapped into ErrInternal
+func As(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return ErrInternal.Wrap(err)
+}
+
+// Code returns machine-readable code of err
+func Code(err error) string {
+	return As(err).Code
+}
@@ -0,0 +1,60 @@
+package myerrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+var errorTests = []struct {
+	number         int
+	err            error
+	target         *Error
+	expectedStatus int
+	expectedCode   string
+}{
+	{0, ErrInvalidInput, ErrInvalidInput, http.StatusBadRequest, "invalid_input"},
+	{1, ErrInvalidInput.WithDetail("number is too large"), ErrInvalidInput, http.StatusBadRequest, "invalid_input"},
+	{2, fmt.Errorf("update: %w", ErrUserNotFound), ErrUserNotFound, http.StatusNotFound, "user_not_found"},
+	{3, ErrShuttingDown.Wrap(errors.New("closed")), ErrShuttingDown, http.StatusServiceUnavailable, "shutting_down"},
+	{4, errors.New("some error"), ErrInternal, http.StatusInternalServerError, "internal_error"},
+	{5, ErrNoMatch, ErrNoMatch, http.StatusNotFound, "no_match"},
+	{6, ErrQueueFull, ErrQueueFull, http.StatusTooManyRequests, "queue_full"},
+	{7, ErrIdempotencyKey, ErrIdempotencyKey, http.StatusUnprocessableEntity, "idempotency_key_reused"},
+	{10, ErrPatchFailed.WithDetail("operation 0: test failed"), ErrPatchFailed, http.StatusUnprocessableEntity, "patch_failed"},
+	{9, ErrVersionMismatch, ErrVersionMismatch, http.StatusPreconditionFailed, "version_mismatch"},
+	{8, ErrUnsupportedMedia.WithDetail("use text/csv"), ErrUnsupportedMedia, http.StatusUnsupportedMediaType, "unsupported_media_type"},
+	{11, ErrCounterBounds, ErrCounterBounds, http.StatusBadRequest, "counter_out_of_bounds"},
+	{12, fmt.Errorf("delete: %w", ErrCounterNotFound), ErrCounterNotFound, http.StatusNotFound, "counter_not_found"},
+}
+
+// TestAs tests that errors are resolved to their status and code
+func TestAs(t *testing.T) {
+	for _, testCase := range errorTests {
+		e := As(testCase.err)
+		if !errors.Is(e, testCase.target) {
+			t.Errorf("for test #%d, expected %v to match %v", testCase.number, e, testCase.target)
+		}
+		if e.Status() != testCase.expectedStatus {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatus, e.Status())
+		}
+		if e.Code != testCase.expectedCode {
+			t.Errorf("for test #%d, expected %q but got %q", testCase.number, testCase.expectedCode, e.Code)
+		}
+	}
+}
+
+// TestIs tests that derived errors do not match other sentinels
+func TestIs(t *testing.T) {
+	err := ErrInvalidInput.WithDetail("detail").Wrap(errors.New("cause"))
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected %v to match %v", err, ErrInvalidInput)
+	}
+	if errors.Is(err, ErrBodyNotFound) {
+		t.Errorf("expected %v not to match %v", err, ErrBodyNotFound)
+	}
+	if exp := "invalid input, detail: cause"; err.Error() != exp {
+		t.Errorf("expected %q but got %q", exp, err.Error())
+	}
+}
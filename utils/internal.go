@@ -9,7 +9,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"rest/models"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -39,13 +39,14 @@ func IsLatin(s string) bool {
 	return true
 }
 
-// ValidateUser validates user info
-func ValidateUser(u models.User) bool {
-	firstName, lastName := u.FirstName, u.LastName
-	if firstName == "" || lastName == "" || !IsLatin(firstName) || !IsLatin(lastName) {
-		return false
-	}
-	return true
+// EmailPattern matches email address accepted by /rest/email/check
+const EmailPattern = `[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}`
+
+var emailRe = regexp.MustCompile(`^` + EmailPattern + `$`)
+
+// ValidateEmail checks that s is a single email address
+func ValidateEmail(s string) bool {
+	return emailRe.MatchString(s)
 }
 
 // ValidateID validates ID
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"rest/config"
+	"rest/models"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// nameScripts tells whether letter belongs to alphabet allowed by config.Names
+var nameScripts = map[string]func(r rune) bool{
+	"latin": func(r rune) bool {
+		return unicode.Is(unicode.Latin, r)
+	},
+	"cyrillic": func(r rune) bool {
+		return unicode.Is(unicode.Cyrillic, r)
+	},
+	// kazakh is Russian alphabet with nine letters of its own
+	"kazakh": func(r rune) bool {
+		return r >= 'А' && r <= 'я' || strings.ContainsRune("ЁёӘәҒғҚқҢңӨөҰұҮүҺһІі", r)
+	},
+}
+
+// nameSeparators are characters allowed between letters by config.Names
+var nameSeparators = map[string]string{
+	"hyphen":     "-",
+	"apostrophe": "'’ʼ",
+	"space":      " ",
+}
+
+// NormalizeName returns name in NFC with surrounding spaces trimmed
+func NormalizeName(name string) string {
+	return norm.NFC.String(strings.TrimSpace(name))
+}
+
+// ValidateName checks name normalized by NormalizeName against policy
+// Letters may be followed by combining marks, separators are allowed only between letters.
+// Error message starts with field.
+func ValidateName(field, name string, p config.Names) error {
+	if name == "" {
+		return fmt.Errorf("%s is required", field)
+	}
+	if n := utf8.RuneCountInString(name); n < p.MinLength || n > p.MaxLength {
+		return fmt.Errorf("%s must be from %d to %d characters long", field, p.MinLength, p.MaxLength)
+	}
+	separators := ""
+	for _, s := range p.Separators {
+		separators += nameSeparators[s]
+	}
+	prev := ' '
+	for i, r := range name {
+		switch {
+		case isNameLetter(r, p.Scripts):
+		case unicode.Is(unicode.Mn, r) && i > 0 && !strings.ContainsRune(separators, prev):
+			// combining mark of letter without precomposed form
+		case strings.ContainsRune(separators, r):
+			if i == 0 || i+utf8.RuneLen(r) == len(name) {
+				return fmt.Errorf("%s must start and end with a letter", field)
+			}
+			if strings.ContainsRune(separators, prev) {
+				return fmt.Errorf("%s cannot have %q after %q", field, r, prev)
+			}
+		default:
+			return fmt.Errorf("%s cannot contain %q", field, r)
+		}
+		prev = r
+	}
+	return nil
+}
+
+// ValidateUser normalizes names of u and checks them against policy
+// Error lists every invalid name separated by semicolons
+func ValidateUser(u *models.User, p config.Names) error {
+	u.FirstName, u.LastName = NormalizeName(u.FirstName), NormalizeName(u.LastName)
+	var errs []string
+	for _, f := range []struct {
+		field, name string
+	}{{"first_name", u.FirstName}, {"last_name", u.LastName}} {
+		if err := ValidateName(f.field, f.name, p); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// isNameLetter reports whether r is a letter of one of scripts
+func isNameLetter(r rune, scripts []string) bool {
+	if !unicode.IsLetter(r) {
+		return false
+	}
+	for _, s := range scripts {
+		if is, ok := nameScripts[s]; ok && is(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,319 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PatchError is returned when well-formed patch cannot be applied to document,
+// e.g. its path does not exist or test operation fails
+type PatchError struct {
+	// Op is index of failed operation of JSON Patch
+	Op  int
+	Msg string
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("operation %d: %s", e.Op, e.Msg)
+}
+
+// MergePatch applies JSON Merge Patch (RFC 7396) to JSON document
+// Error is returned only if doc or patch is not valid JSON
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target, p interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatch(target, p))
+}
+
+// mergePatch merges patch into target, null members of patch remove members of target
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// JSONPatch applies operations of JSON Patch (RFC 6902) to JSON document in order
+// Operations are applied all or none, *PatchError tells which one failed.
+// Other errors mean that doc or patch is malformed.
+func JSONPatch(doc, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	var ops []map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, errors.New("patch must be array of operations")
+	}
+	for i, op := range ops {
+		var err error
+		if target, err = applyOp(target, op); err != nil {
+			var pe *PatchError
+			if errors.As(err, &pe) {
+				pe.Op = i
+				return nil, pe
+			}
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+// applyOp applies single operation to doc and returns result
+func applyOp(doc interface{}, op map[string]json.RawMessage) (interface{}, error) {
+	var name string
+	if err := json.Unmarshal(op["op"], &name); err != nil {
+		return nil, errors.New("op must be a string")
+	}
+	path, err := opPointer(op, "path")
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	switch name {
+	case "add", "replace", "test":
+		raw, ok := op["value"]
+		if !ok {
+			return nil, fmt.Errorf("%s needs value", name)
+		}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, err
+		}
+	case "move", "copy":
+		from, err := opPointer(op, "from")
+		if err != nil {
+			return nil, err
+		}
+		if value, err = lookup(doc, from); err != nil {
+			return nil, err
+		}
+		if name == "move" {
+			if isPrefix(from, path) {
+				return nil, &PatchError{Msg: "cannot move value into itself"}
+			}
+			if doc, err = remove(doc, from); err != nil {
+				return nil, err
+			}
+		} else {
+			value = deepCopy(value)
+		}
+		return add(doc, path, value)
+	case "remove":
+	default:
+		return nil, fmt.Errorf("unknown op %q", name)
+	}
+	switch name {
+	case "add":
+		return add(doc, path, value)
+	case "remove":
+		return remove(doc, path)
+	case "replace":
+		if _, err := lookup(doc, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		if doc, err = remove(doc, path); err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	}
+	got, err := lookup(doc, path)
+	if err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(got, value) {
+		return nil, &PatchError{Msg: fmt.Sprintf("test failed at %q", pointer(path))}
+	}
+	return doc, nil
+}
+
+// opPointer parses JSON Pointer (RFC 6901) in member of operation into unescaped reference tokens
+func opPointer(op map[string]json.RawMessage, member string) ([]string, error) {
+	var s string
+	if err := json.Unmarshal(op[member], &s); err != nil {
+		return nil, fmt.Errorf("%s must be a string", member)
+	}
+	if s == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("%s %q must start with /", member, s)
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// pointer returns JSON Pointer of reference tokens for messages
+func pointer(tokens []string) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		b.WriteString("/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(t))
+	}
+	return b.String()
+}
+
+// isPrefix reports whether pointer a is a proper prefix of pointer b
+func isPrefix(a, b []string) bool {
+	return len(a) < len(b) && reflect.DeepEqual(a, b[:len(a)])
+}
+
+// lookup returns value at path
+func lookup(doc interface{}, path []string) (interface{}, error) {
+	for i, t := range path {
+		switch c := doc.(type) {
+		case map[string]interface{}:
+			v, ok := c[t]
+			if !ok {
+				return nil, notFound(path[:i+1])
+			}
+			doc = v
+		case []interface{}:
+			idx, err := index(t, len(c)-1)
+			if err != nil {
+				return nil, notFound(path[:i+1])
+			}
+			doc = c[idx]
+		default:
+			return nil, notFound(path[:i+1])
+		}
+	}
+	return doc, nil
+}
+
+// add sets value at path, inserting it into array if path ends with array index
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(doc, path, func(parent interface{}, t string) (interface{}, error) {
+		switch c := parent.(type) {
+		case map[string]interface{}:
+			c[t] = value
+			return c, nil
+		case []interface{}:
+			idx := len(c)
+			if t != "-" {
+				var err error
+				if idx, err = index(t, len(c)); err != nil {
+					return nil, notFound(path)
+				}
+			}
+			c = append(c, nil)
+			copy(c[idx+1:], c[idx:])
+			c[idx] = value
+			return c, nil
+		}
+		return nil, notFound(path)
+	})
+}
+
+// remove deletes value at path, it must exist
+func remove(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, &PatchError{Msg: "cannot remove the whole document"}
+	}
+	return update(doc, path, func(parent interface{}, t string) (interface{}, error) {
+		switch c := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := c[t]; !ok {
+				return nil, notFound(path)
+			}
+			delete(c, t)
+			return c, nil
+		case []interface{}:
+			idx, err := index(t, len(c)-1)
+			if err != nil {
+				return nil, notFound(path)
+			}
+			return append(c[:idx], c[idx+1:]...), nil
+		}
+		return nil, notFound(path)
+	})
+}
+
+// update calls f with container holding the last token of path and stores container it returns
+// Containers are changed in place, arrays are stored back as they may be reallocated
+func update(doc interface{}, path []string, f func(parent interface{}, t string) (interface{}, error)) (interface{}, error) {
+	parent, err := lookup(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	changed, err := f(parent, path[len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	if len(path) == 1 {
+		return changed, nil
+	}
+	grand, _ := lookup(doc, path[:len(path)-2])
+	t := path[len(path)-2]
+	switch c := grand.(type) {
+	case map[string]interface{}:
+		c[t] = changed
+	case []interface{}:
+		idx, _ := index(t, len(c)-1)
+		c[idx] = changed
+	}
+	return doc, nil
+}
+
+// index parses array index not greater than max, leading zeros are not allowed
+func index(t string, max int) (int, error) {
+	if t == "" || len(t) > 1 && t[0] == '0' || strings.TrimLeft(t, "0123456789") != "" {
+		return 0, errors.New("invalid index")
+	}
+	idx, err := strconv.Atoi(t)
+	if err != nil || idx > max {
+		return 0, errors.New("index out of range")
+	}
+	return idx, nil
+}
+
+// deepCopy returns copy of decoded JSON value sharing nothing with v
+func deepCopy(v interface{}) interface{} {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(c))
+		for k, e := range c {
+			m[k] = deepCopy(e)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(c))
+		for i, e := range c {
+			a[i] = deepCopy(e)
+		}
+		return a
+	}
+	return v
+}
+
+func notFound(path []string) error {
+	return &PatchError{Msg: fmt.Sprintf("path %q does not exist", pointer(path))}
+}
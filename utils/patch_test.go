@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// jsonPatchTests start with examples of RFC 6902 Appendix A in their order
+var jsonPatchTests = []struct {
+	number      int
+	doc         string
+	patch       string
+	expected    string
+	expectedErr bool
+}{
+	{0, `{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux"}]`, `{"baz": "qux", "foo": "bar"}`, false},
+	{1, `{"foo": ["bar", "baz"]}`, `[{"op": "add", "path": "/foo/1", "value": "qux"}]`, `{"foo": ["bar", "qux", "baz"]}`, false},
+	{2, `{"baz": "qux", "foo": "bar"}`, `[{"op": "remove", "path": "/baz"}]`, `{"foo": "bar"}`, false},
+	{3, `{"foo": ["bar", "qux", "baz"]}`, `[{"op": "remove", "path": "/foo/1"}]`, `{"foo": ["bar", "baz"]}`, false},
+	{4, `{"baz": "qux", "foo": "bar"}`, `[{"op": "replace", "path": "/baz", "value": "boo"}]`, `{"baz": "boo", "foo": "bar"}`, false},
+	{5, `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`, `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`, `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`, false},
+	{6, `{"foo": ["all", "grass", "cows", "eat"]}`, `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`, `{"foo": ["all", "cows", "eat", "grass"]}`, false},
+	{7, `{"baz": "qux", "foo": ["a", 2, "c"]}`, `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`, `{"baz": "qux", "foo": ["a", 2, "c"]}`, false},
+	{8, `{"baz": "qux"}`, `[{"op": "test", "path": "/baz", "value": "bar"}]`, "", true},
+	{9, `{"foo": "bar"}`, `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`, `{"foo": "bar", "child": {"grandchild": {}}}`, false},
+	{10, `{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`, `{"foo": "bar", "baz": "qux"}`, false},
+	{11, `{"foo": "bar"}`, `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`, "", true},
+	{12, `{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux", "op": "remove"}]`, "", true},
+	{13, `{"/": 9, "~1": 10}`, `[{"op": "test", "path": "/~01", "value": 10}]`, `{"/": 9, "~1": 10}`, false},
+	{14, `{"/": 9, "~1": 10}`, `[{"op": "test", "path": "/~01", "value": "10"}]`, "", true},
+	{15, `{"foo": ["bar"]}`, `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`, `{"foo": ["bar", ["abc", "def"]]}`, false},
+	// nested arrays are stored back into their containers
+	{16, `{"a": [[1], [2]]}`, `[{"op": "add", "path": "/a/1/0", "value": 3}, {"op": "remove", "path": "/a/0/0"}]`, `{"a": [[], [3, 2]]}`, false},
+	// copy does not alias its source
+	{17, `{"a": {"b": 1}}`, `[{"op": "copy", "from": "/a", "path": "/c"}, {"op": "replace", "path": "/c/b", "value": 2}]`, `{"a": {"b": 1}, "c": {"b": 2}}`, false},
+	{18, `{"a": {"b": 1}}`, `[{"op": "move", "from": "/a", "path": "/a/c"}]`, "", true},
+	{19, `{"a": 1}`, `[{"op": "replace", "path": "", "value": [1]}]`, `[1]`, false},
+	{20, `{"a": 1}`, `[{"op": "remove", "path": ""}]`, "", true},
+	{21, `{"a": [1, 2]}`, `[{"op": "remove", "path": "/a/-"}]`, "", true},
+	{22, `{"a": [1, 2]}`, `[{"op": "replace", "path": "/a/01", "value": 3}]`, "", true},
+	{23, `{"a": [1, 2]}`, `[{"op": "add", "path": "/a/3", "value": 3}]`, "", true},
+	{24, `{"a/b": {"m~n": 1}}`, `[{"op": "replace", "path": "/a~1b/m~0n", "value": 2}]`, `{"a/b": {"m~n": 2}}`, false},
+}
+
+// jsonEqual reports whether a and b are the same JSON value
+func jsonEqual(t *testing.T, a, b string) bool {
+	t.Helper()
+	var x, y interface{}
+	if err := json.Unmarshal([]byte(a), &x); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(b), &y); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// TestJSONPatch tests JSONPatch against examples of RFC 6902 and edge cases of pointers and arrays
+func TestJSONPatch(t *testing.T) {
+	for _, testCase := range jsonPatchTests {
+		got, err := JSONPatch([]byte(testCase.doc), []byte(testCase.patch))
+		if testCase.expectedErr {
+			var pe *PatchError
+			if !errors.As(err, &pe) {
+				t.Errorf("for test #%d, expected patch error but got %s, %v", testCase.number, got, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("for test #%d, unexpected error %v", testCase.number, err)
+			continue
+		}
+		if !jsonEqual(t, string(got), testCase.expected) {
+			t.Errorf("for test #%d, expected %s but got %s", testCase.number, testCase.expected, got)
+		}
+	}
+}
+
+// TestJSONPatchAllOrNone tests that failed operation is reported by index and leaves document as it was
+func TestJSONPatchAllOrNone(t *testing.T) {
+	doc := []byte(`{"a": [1, 2]}`)
+	_, err := JSONPatch(doc, []byte(`[{"op": "add", "path": "/a/-", "value": 3}, {"op": "test", "path": "/a/2", "value": 4}]`))
+	var pe *PatchError
+	if !errors.As(err, &pe) || pe.Op != 1 {
+		t.Errorf("expected error of operation %d but got %v", 1, err)
+	}
+	if string(doc) != `{"a": [1, 2]}` {
+		t.Errorf("expected document to stay unchanged but got %s", doc)
+	}
+	for _, patch := range []string{`{"op": "add"}`, `[{"op": "jump", "path": "/a"}]`, `[{"op": "add", "path": "a", "value": 1}]`, `[{"op": "add", "path": "/b"}]`} {
+		if _, err := JSONPatch(doc, []byte(patch)); err == nil || errors.As(err, &pe) {
+			t.Errorf("for patch %s, expected malformed patch error but got %v", patch, err)
+		}
+	}
+}
+
+// mergePatchTests are examples of RFC 7396 Appendix A
+var mergePatchTests = []struct {
+	number   int
+	doc      string
+	patch    string
+	expected string
+}{
+	{0, `{"a": "b"}`, `{"a": "c"}`, `{"a": "c"}`},
+	{1, `{"a": "b"}`, `{"b": "c"}`, `{"a": "b", "b": "c"}`},
+	{2, `{"a": "b"}`, `{"a": null}`, `{}`},
+	{3, `{"a": "b", "b": "c"}`, `{"a": null}`, `{"b": "c"}`},
+	{4, `{"a": ["b"]}`, `{"a": "c"}`, `{"a": "c"}`},
+	{5, `{"a": "c"}`, `{"a": ["b"]}`, `{"a": ["b"]}`},
+	{6, `{"a": {"b": "c"}}`, `{"a": {"b": "d", "c": null}}`, `{"a": {"b": "d"}}`},
+	{7, `{"a": [{"b": "c"}]}`, `{"a": [1]}`, `{"a": [1]}`},
+	{8, `["a", "b"]`, `["c", "d"]`, `["c", "d"]`},
+	{9, `{"a": "b"}`, `["c"]`, `["c"]`},
+	{10, `{"a": "foo"}`, `null`, `null`},
+	{11, `{"a": "foo"}`, `"bar"`, `"bar"`},
+	{12, `{"e": null}`, `{"a": 1}`, `{"e": null, "a": 1}`},
+	{13, `[1, 2]`, `{"a": "b", "c": null}`, `{"a": "b"}`},
+	{14, `{}`, `{"a": {"bb": {"ccc": null}}}`, `{"a": {"bb": {}}}`},
+}
+
+// TestMergePatch tests MergePatch against examples of RFC 7396
+func TestMergePatch(t *testing.T) {
+	for _, testCase := range mergePatchTests {
+		got, err := MergePatch([]byte(testCase.doc), []byte(testCase.patch))
+		if err != nil {
+			t.Errorf("for test #%d, unexpected error %v", testCase.number, err)
+			continue
+		}
+		if !jsonEqual(t, string(got), testCase.expected) {
+			t.Errorf("for test #%d, expected %s but got %s", testCase.number, testCase.expected, got)
+		}
+	}
+	if _, err := MergePatch([]byte(`{}`), []byte(`{`)); err == nil {
+		t.Error("expected error for malformed patch")
+	}
+}
@@ -0,0 +1,127 @@
+package viewmodels
+
+import (
+	"rest/models"
+	"time"
+)
+
+// Substring is the result of /rest/substr/find
+type Substring struct {
+	Substring string `json:"substring"`
+}
+
+// Emails is the result of /rest/email/check
+type Emails struct {
+	Emails []string `json:"emails"`
+}
+
+// IINs is the result of /rest/iin/check
+type IINs struct {
+	IINs []string `json:"iins"`
+}
+
+// Counter holds current value of counter
+type Counter struct {
+	Counter int64 `json:"counter"`
+}
+
+// NamedCounter is counter with its bounds, absent bound is null
+// TTL is how long counter is kept after its last change, it is omitted for counters kept forever
+type NamedCounter struct {
+	Name  string `json:"name"`
+	Value int64  `json:"value"`
+	Min   *int64 `json:"min"`
+	Max   *int64 `json:"max"`
+	TTL   string `json:"ttl,omitempty"`
+}
+
+// Counters is the result of GET /rest/counter
+type Counters struct {
+	Counters []NamedCounter `json:"counters"`
+}
+
+// CounterName identifies counter affected by request
+type CounterName struct {
+	Name string `json:"name"`
+}
+
+// UserID identifies user affected by request
+type UserID struct {
+	ID int64 `json:"id"`
+}
+
+// Users is a page of users
+// NextCursor is empty on the last page
+type Users struct {
+	Users      []models.User `json:"users"`
+	Total      int           `json:"total"`
+	Offset     int           `json:"offset"`
+	Limit      int           `json:"limit"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// Import reports result of bulk import of users
+// Rows are numbered from 1 in order of input, CSV header is not counted
+type Import struct {
+	Imported int           `json:"imported"`
+	Failed   int           `json:"failed"`
+	Errors   []ImportError `json:"errors"`
+}
+
+// ImportError describes row that was not imported
+type ImportError struct {
+	Row     int    `json:"row"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Jobs is a page of hash jobs
+type Jobs struct {
+	Jobs   []models.Job `json:"jobs"`
+	Total  int          `json:"total"`
+	Offset int          `json:"offset"`
+	Limit  int          `json:"limit"`
+}
+
+// Batch reports progress of hash jobs submitted together
+// Jobs expired from the store are counted in Total only
+type Batch struct {
+	ID        string       `json:"id"`
+	CreatedAt time.Time    `json:"created_at"`
+	Total     int          `json:"total"`
+	Queued    int          `json:"queued"`
+	Running   int          `json:"running"`
+	Done      int          `json:"done"`
+	Failed    int          `json:"failed"`
+	Cancelled int          `json:"cancelled"`
+	Finished  bool         `json:"finished"`
+	Jobs      []models.Job `json:"jobs"`
+}
+
+// Deliveries lists attempts to deliver callback of hash job
+type Deliveries struct {
+	Deliveries []models.Delivery `json:"deliveries"`
+}
+
+// Workers describes worker pool of hash jobs
+type Workers struct {
+	Size    int      `json:"size"`
+	Max     int      `json:"max"`
+	Busy    int      `json:"busy"`
+	Workers []Worker `json:"workers"`
+}
+
+// Worker describes state of single worker, JobID is set for busy ones
+type Worker struct {
+	ID    int       `json:"id"`
+	State string    `json:"state"`
+	JobID string    `json:"job_id,omitempty"`
+	Since time.Time `json:"since"`
+	// Processed is the number of jobs the worker has finished
+	Processed int `json:"processed"`
+}
+
+// Identifiers is the result of /rest/self/find
+type Identifiers struct {
+	Identifiers []string `json:"identifiers"`
+}
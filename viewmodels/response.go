@@ -2,29 +2,85 @@ package viewmodels
 
 import (
 	"encoding/json"
+	"rest/myerrors"
+	"strings"
 
 	"github.com/valyala/fasthttp"
 )
 
-const serverErrorMsg = "Something went wrong. Please try again later."
+const (
+	serverErrorCode = "internal_error"
+	serverErrorMsg  = "Something went wrong. Please try again later."
+)
+
+// Envelope is the body of every JSON response
+type Envelope struct {
+	Data  interface{} `json:"data,omitempty"`
+	Error *ErrorBody  `json:"error,omitempty"`
+}
+
+// ErrorBody describes failed request
+type ErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
 
 func Message(ctx *fasthttp.RequestCtx, message string) {
+	Result(ctx, message, message)
+}
+
+// Result writes data wrapped in envelope, clients accepting only text receive message instead
+func Result(ctx *fasthttp.RequestCtx, data interface{}, message string) {
 	ctx.SetStatusCode(fasthttp.StatusOK)
-	ctx.WriteString(message)
+	if AcceptsText(ctx) {
+		ctx.WriteString(message)
+		return
+	}
+	writeJSON(ctx, Envelope{Data: data})
 }
 
-func ClientError(ctx *fasthttp.RequestCtx, status int, err error) {
-	ctx.SetStatusCode(status)
-	ctx.WriteString(err.Error())
+// Error writes status, code and public message of err.
+// Errors of internal kind are reported without details.
+func Error(ctx *fasthttp.RequestCtx, err error) {
+	e := myerrors.As(err)
+	if e.Kind == myerrors.KindInternal {
+		ServerError(ctx)
+		return
+	}
+	ctx.SetStatusCode(e.Status())
+	if AcceptsText(ctx) {
+		ctx.WriteString(e.Message)
+		return
+	}
+	writeJSON(ctx, Envelope{Error: &ErrorBody{Code: e.Code, Message: e.Message}})
 }
 
 func ServerError(ctx *fasthttp.RequestCtx) {
 	ctx.SetStatusCode(fasthttp.StatusInternalServerError)
-	ctx.WriteString(serverErrorMsg)
+	if AcceptsText(ctx) {
+		ctx.WriteString(serverErrorMsg)
+		return
+	}
+	writeJSON(ctx, Envelope{Error: &ErrorBody{Code: serverErrorCode, Message: serverErrorMsg}})
 }
 
+// JSON writes data wrapped in envelope, clients accepting only text receive bare data
 func JSON(ctx *fasthttp.RequestCtx, data interface{}) {
-	ctx.SetContentType("application/json")
 	ctx.SetStatusCode(fasthttp.StatusOK)
-	json.NewEncoder(ctx).Encode(data)
+	if AcceptsText(ctx) {
+		writeJSON(ctx, data)
+		return
+	}
+	writeJSON(ctx, Envelope{Data: data})
+}
+
+// AcceptsText reports whether client asked for legacy plain text responses
+func AcceptsText(ctx *fasthttp.RequestCtx) bool {
+	accept := string(ctx.Request.Header.Peek(fasthttp.HeaderAccept))
+	return strings.Contains(accept, "text/plain") && !strings.Contains(accept, "application/json")
+}
+
+func writeJSON(ctx *fasthttp.RequestCtx, v interface{}) {
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(v)
 }
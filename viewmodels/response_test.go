@@ -0,0 +1,39 @@
+package viewmodels
+
+import (
+	"errors"
+	"rest/myerrors"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+var errorTests = []struct {
+	number             int
+	err                error
+	accept             string
+	expectedOutput     string
+	expectedStatusCode int
+}{
+	{0, myerrors.ErrUserNotFound, "", `{"error":{"code":"user_not_found","message":"user not found"}}` + "\n", fasthttp.StatusNotFound},
+	{1, myerrors.ErrUserNotFound, "text/plain", "user not found", fasthttp.StatusNotFound},
+	{2, myerrors.ErrInvalidInput.Wrap(errors.New("secret cause")), "text/plain", "invalid input", fasthttp.StatusBadRequest},
+	{3, errors.New("secret cause"), "text/plain", serverErrorMsg, fasthttp.StatusInternalServerError},
+	{4, myerrors.ErrCtxValue, "", `{"error":{"code":"internal_error","message":"` + serverErrorMsg + `"}}` + "\n", fasthttp.StatusInternalServerError},
+	{5, myerrors.ErrShuttingDown, "application/json, text/plain", `{"error":{"code":"shutting_down","message":"server is shutting down"}}` + "\n", fasthttp.StatusServiceUnavailable},
+}
+
+// TestError tests rendering of errors
+func TestError(t *testing.T) {
+	for _, testCase := range errorTests {
+		var ctx fasthttp.RequestCtx
+		ctx.Request.Header.Set(fasthttp.HeaderAccept, testCase.accept)
+		Error(&ctx, testCase.err)
+		if status := ctx.Response.StatusCode(); status != testCase.expectedStatusCode {
+			t.Errorf("for test #%d, expected %d but got %d", testCase.number, testCase.expectedStatusCode, status)
+		}
+		if body := string(ctx.Response.Body()); body != testCase.expectedOutput {
+			t.Errorf("for test #%d, expected %q but got %q", testCase.number, testCase.expectedOutput, body)
+		}
+	}
+}